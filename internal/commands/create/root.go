@@ -1,10 +1,12 @@
 package create
 
 import (
+	"errors"
 	"fast-wireguard/internal/system"
 	"fast-wireguard/internal/wireguard"
 	"fast-wireguard/pkg/utils"
 	"fmt"
+	"time"
 	"github.com/spf13/cobra"
 )
 
@@ -14,24 +16,49 @@ It sets up the initial configuration and runs the service.
 */
 func CreateCreateCmd() *cobra.Command {
 	opts := &wireguard.ServerOptions{}
+	var family string
 	var createCmd = &cobra.Command{
 		Use:     "create [interface]",
 		Aliases: []string{"install", "setup"},
 		Short:   "Create initial configuration and run the service",
 		Long: `Set up a new WireGuard interface.
 If no interface name is provided, it defaults to 'wg0'.
-You can specify a custom name like 'wg1' or 'myvpn' to create multiple instances.`,
+You can specify a custom name like 'wg1' or 'myvpn' to create multiple instances.
+With --ipv6-prefix, the peers get global IPv6 addresses without NAT66. If the prefix is on-link on the
+uplink, fwg answers the neighbor solicitations for the peers (NDP proxy). Otherwise the upstream router
+must route the prefix to the server: fwg does not announce routes (no BGP, OSPF or RA to the uplink).`,
 		Args: cobra.MaximumNArgs(1),
 		PersistentPreRun: func(cmd *cobra.Command, args []string) {
 			utils.EnsureRoot()
 		},
 		Run: func(cmd *cobra.Command, args []string) {
+			// Choose the address family from the capabilities of the host unless given
+			if family == "" {
+				opts.Family = system.DetectFamily()
+				fmt.Printf("✅ Detected the address family: %s\n", opts.Family)
+			} else {
+				var err error
+				if opts.Family, err = system.ParseFamily(family); err != nil {
+					fmt.Printf("Error in choosing the address family: %v\n", err)
+					return
+				}
+				if opts.Family.HasIPv6() && !system.IPv6Supported() {
+					fmt.Println("Warning: IPv6 is disabled or ip6tables is missing on this host.")
+				}
+			}
+			if !cmd.Flags().Changed("address") {
+				opts.IPAdressLocalServer = opts.Family.FilterAddresses(opts.IPAdressLocalServer)
+			}
+			if !cmd.Flags().Changed("dns") && opts.Family == system.FamilyIPv6 {
+				opts.DNS = "2001:4860:4860::8888, 2606:4700:4700::1111"
+			}
+
 			// Check the package installation and IP forwarding
 			if err := wireguard.InstallWireGuard(); err != nil {
 				fmt.Printf("Error installing WireGuard: %v\n", err)
 				return
 			}
-			if err := system.EnableIPForwarding(); err != nil {
+			if err := system.EnableIPForwarding(opts.Family); err != nil {
 				fmt.Printf("Error enabling IP forwarding: %v\n", err)
 				return
 			}
@@ -41,12 +68,21 @@ You can specify a custom name like 'wg1' or 'myvpn' to create multiple instances
 				return
 			}
 
+			// Let the clients use the DNS service unless other servers are given
+			dnsService := opts.DNSForwarder || opts.DNSZone != ""
+			if dnsService && !cmd.Flags().Changed("dns") {
+				opts.DNS = ""
+			}
+
 			// Setup the server configuration
 			interfaceName := "wg0"
 			if len(args) > 0 {
 				interfaceName = args[0]
 			}
-			if err := wireguard.CreateServer(interfaceName, opts); err != nil {
+			if err := wireguard.CreateServer(interfaceName, opts); errors.Is(err, wireguard.ErrOverwriteDeclined) {
+				fmt.Printf("Skipping the creation of %s, %v.\n", interfaceName, err)
+				return
+			} else if err != nil {
 				fmt.Printf("Error in creating the WireGuard server: %v\n", err)
 				return
 			}
@@ -58,6 +94,14 @@ You can specify a custom name like 'wg1' or 'myvpn' to create multiple instances
 			}
 			if err := wireguard.StartService(interfaceName); err != nil {
 				fmt.Printf("Error in starting the service %s: %v\n", interfaceName, err)
+				return
+			}
+
+			// Start the DNS service on the tunnel address if required
+			if dnsService {
+				if err := wireguard.EnableDNSService(interfaceName); err != nil {
+					fmt.Printf("Error in enabling the DNS service of %s: %v\n", interfaceName, err)
+				}
 			}
 		},
 	}
@@ -65,10 +109,32 @@ You can specify a custom name like 'wg1' or 'myvpn' to create multiple instances
 	createCmd.Flags().BoolVar(&opts.DryRun, "dry-run", false, "initial the configuration for WireGuard without creating interface")
 	createCmd.Flags().IntVarP(&opts.ListenPort, "port", "p", 51820, "listening port for WireGuard server")
 	createCmd.Flags().StringVarP(&opts.IPAdressLocalServer, "address", "a", "10.0.0.1/24, fd00::1/64", "local IP address assigned to WireGuard server")
-	createCmd.Flags().IntVarP(&opts.MTU, "mtu", "m", 1420, "the length of MTU")
+	createCmd.Flags().StringVar(&family, "family", "", "address family of the tunnel: ipv4, ipv6 or dual (detected from the host if empty)")
+	createCmd.Flags().StringVar(&opts.IPv6Prefix, "ipv6-prefix", "", "global IPv6 prefix routed to the peers without NAT66 (e.g. 2001:db8:1::/64)")
+	createCmd.Flags().IntVar(&opts.IPv6Delegate, "ipv6-delegate", 0, "length of the prefix delegated to each peer (e.g. 64), a single address if 0")
+	createCmd.Flags().StringVar(&opts.NDPProxy, "ndp-proxy", "auto", "answer the neighbor solicitations for the peers on the uplink: auto, on or off")
+	createCmd.Flags().IntVarP(&opts.MTU, "mtu", "m", 0, "the length of MTU (computed from the physical interface if 0)")
+	createCmd.Flags().StringVar(&opts.MTUProbe, "mtu-probe", "", "host used to verify the path MTU when computing the MTU")
+	createCmd.Flags().IntVar(&opts.ClientMTU, "client-mtu", 0, "the length of MTU recommended to the clients (computed if 0)")
 	createCmd.Flags().StringVarP(&opts.PeerName, "peer-name", "n", "default-peer", "name of the WireGuard client peer")
 	createCmd.Flags().StringVarP(&opts.IPAdressLocalClient, "address-client", "c", "10.0.0.[auto-ipv4]/32, fd00::[auto-ipv6]/128", "local IP address assigned to WireGuard client")
+	createCmd.Flags().StringVarP(&opts.Endpoint, "endpoint", "e", "", "host[:port] the clients connect to, DNS names allowed (the public IP is detected if empty)")
+	createCmd.Flags().BoolVar(&opts.PreferIPv6, "prefer-ipv6", false, "prefer the public IPv6 address over the IPv4 one when detecting the endpoint")
+	createCmd.Flags().StringVar(&opts.STUNServers, "stun", "", "STUN servers used to discover the public IP (\"none\" to disable)")
+	createCmd.Flags().StringVar(&opts.IPProviders, "ip-provider", "", "HTTP providers used to discover the public IP (\"none\" to disable)")
+	createCmd.Flags().IntVar(&opts.IPConsensus, "ip-consensus", 2, "number of sources which must agree on the public IP")
+	createCmd.Flags().BoolVar(&opts.Offline, "offline", false, "use the local outbound IP without contacting any third party")
+	createCmd.Flags().DurationVar(&opts.IPCacheTTL, "ip-cache-ttl", 10*time.Minute, "how long a discovered public IP is reused")
+	createCmd.Flags().StringVar(&opts.OutInterface, "out-interface", "", "physical interface used for the outbound traffic (detected from the default routes if empty)")
+	createCmd.Flags().BoolVar(&opts.PortMapping, "port-mapping", false, "map the port on the gateway with PCP, NAT-PMP or UPnP if the server is behind NAT, and keep it mapped with a service")
+	createCmd.Flags().StringVar(&opts.LANs, "lan", "auto", "LANs of the server routed to the site-to-site peers (\"auto\" detects them, \"none\" disables them)")
+	createCmd.Flags().StringVar(&opts.PeerToPeer, "peer-to-peer", "allow", "whether the peers can reach each other through the server: allow or deny")
 	createCmd.Flags().BoolVarP(&opts.Force, "force", "f", false, "force re-setup even if already configured")
+	createCmd.Flags().StringVar(&opts.DNS, "dns", "8.8.8.8, 1.1.1.1", "DNS servers pushed to the clients (empty to omit)")
+	createCmd.Flags().StringVar(&opts.DNSSearch, "dns-search", "", "DNS search domains pushed to the clients")
+	createCmd.Flags().BoolVar(&opts.DNSForwarder, "dns-forwarder", false, "run a DNS forwarder on the tunnel address and point the clients to it")
+	createCmd.Flags().StringVar(&opts.DNSUpstream, "dns-upstream", "", "upstream resolvers of the DNS forwarder (defaults to /etc/resolv.conf)")
+	createCmd.Flags().StringVar(&opts.DNSZone, "dns-zone", "", "zone in which the DNS service resolves the peer names (e.g. wg.internal)")
 
 	return createCmd
 }
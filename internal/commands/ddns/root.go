@@ -0,0 +1,27 @@
+package ddns
+
+import (
+	"fast-wireguard/pkg/utils"
+	"github.com/spf13/cobra"
+)
+
+/*
+CreateDDNSCmd represents the ddns command to keep the name of the server pointing to its public IP.
+*/
+func CreateDDNSCmd() *cobra.Command {
+	var ddnsCmd = &cobra.Command{
+		Use:   "ddns",
+		Short: "Update a DNS name with the public IP of the server",
+		PersistentPreRun: func(cmd *cobra.Command, args []string) {
+			utils.EnsureRoot()
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			cmd.Help()
+		},
+	}
+
+	ddnsCmd.AddCommand(createSetCmd())
+	ddnsCmd.AddCommand(createRunCmd())
+
+	return ddnsCmd
+}
@@ -0,0 +1,28 @@
+package ddns
+
+import (
+	"fast-wireguard/internal/wireguard"
+	"fmt"
+	"os"
+	"github.com/spf13/cobra"
+)
+
+// createRunCmd represents the command run by the fwg-ddns@ systemd unit.
+func createRunCmd() *cobra.Command {
+	var once bool
+	var runCmd = &cobra.Command{
+		Use:   "run <interface>",
+		Short: "Run the dynamic DNS updater (used by systemd)",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := wireguard.RunDDNS(args[0], once); err != nil {
+				fmt.Printf("Error in updating the dynamic DNS of %s: %v\n", args[0], err)
+				os.Exit(1)
+			}
+		},
+	}
+
+	runCmd.Flags().BoolVar(&once, "once", false, "update the name once and exit")
+
+	return runCmd
+}
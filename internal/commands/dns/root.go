@@ -0,0 +1,27 @@
+package dns
+
+import (
+	"fast-wireguard/pkg/utils"
+	"github.com/spf13/cobra"
+)
+
+/*
+CreateDNSCmd represents the dns command to manage the DNS settings of an interface.
+*/
+func CreateDNSCmd() *cobra.Command {
+	var dnsCmd = &cobra.Command{
+		Use:   "dns",
+		Short: "Manage the DNS settings and the DNS service of an interface",
+		PersistentPreRun: func(cmd *cobra.Command, args []string) {
+			utils.EnsureRoot()
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			cmd.Help()
+		},
+	}
+
+	dnsCmd.AddCommand(createSetCmd())
+	dnsCmd.AddCommand(createServeCmd())
+
+	return dnsCmd
+}
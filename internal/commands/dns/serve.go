@@ -0,0 +1,28 @@
+package dns
+
+import (
+	"fast-wireguard/internal/wireguard"
+	"fmt"
+	"os"
+	"github.com/spf13/cobra"
+)
+
+// createServeCmd represents the command run by the fwg-dns@ systemd unit.
+func createServeCmd() *cobra.Command {
+	var serveCmd = &cobra.Command{
+		Use:   "serve [interface]",
+		Short: "Run the DNS service on the tunnel address (used by systemd)",
+		Args:  cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			interfaceName := "wg0"
+			if len(args) > 0 {
+				interfaceName = args[0]
+			}
+			if err := wireguard.RunDNSService(interfaceName); err != nil {
+				fmt.Printf("Error in running the DNS service of %s: %v\n", interfaceName, err)
+				os.Exit(1)
+			}
+		},
+	}
+	return serveCmd
+}
@@ -0,0 +1,58 @@
+package dns
+
+import (
+	"fast-wireguard/internal/tracker"
+	"fast-wireguard/internal/wireguard"
+	"fast-wireguard/pkg/utils"
+	"fmt"
+	"github.com/spf13/cobra"
+)
+
+// createSetCmd represents the command to change the DNS settings of an interface.
+func createSetCmd() *cobra.Command {
+	var servers, searchDomains, upstreams, zone string
+	var forwarder bool
+	var setCmd = &cobra.Command{
+		Use:   "set [interface]",
+		Short: "Change the DNS settings of the interface",
+		Long: `Change the DNS settings of an existing interface.
+Only the given flags are changed. The new servers and search domains are used by
+the client configurations generated afterwards.`,
+		Args: cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			interfaceName := "wg0"
+			if len(args) > 0 {
+				interfaceName = args[0]
+			}
+
+			err := wireguard.SetDNS(interfaceName, func(dnsSettings *tracker.DNSSettings) {
+				if cmd.Flags().Changed("servers") {
+					dnsSettings.Servers = utils.SplitList(servers)
+				}
+				if cmd.Flags().Changed("search") {
+					dnsSettings.SearchDomains = utils.SplitList(searchDomains)
+				}
+				if cmd.Flags().Changed("upstream") {
+					dnsSettings.Upstreams = utils.SplitList(upstreams)
+				}
+				if cmd.Flags().Changed("zone") {
+					dnsSettings.Zone = zone
+				}
+				if cmd.Flags().Changed("forwarder") {
+					dnsSettings.Forwarder = forwarder
+				}
+			})
+			if err != nil {
+				fmt.Printf("Error in changing the DNS settings of %s: %v\n", interfaceName, err)
+			}
+		},
+	}
+
+	setCmd.Flags().StringVar(&servers, "servers", "", "DNS servers pushed to the clients (empty to use the DNS service or omit)")
+	setCmd.Flags().StringVar(&searchDomains, "search", "", "DNS search domains pushed to the clients")
+	setCmd.Flags().StringVar(&upstreams, "upstream", "", "upstream resolvers of the DNS forwarder (empty for /etc/resolv.conf)")
+	setCmd.Flags().StringVar(&zone, "zone", "", "zone in which the DNS service resolves the peer names (empty to disable)")
+	setCmd.Flags().BoolVar(&forwarder, "forwarder", false, "run a DNS forwarder on the tunnel address")
+
+	return setCmd
+}
@@ -0,0 +1,42 @@
+package forward
+
+import (
+	"fast-wireguard/internal/wireguard"
+	"fmt"
+	"github.com/spf13/cobra"
+)
+
+// createAddCmd represents the command to forward a public port to a peer.
+func createAddCmd() *cobra.Command {
+	opts := &wireguard.ForwardOptions{}
+	var addCmd = &cobra.Command{
+		Use:   "add <interface>",
+		Short: "Forward a port of the public interface to a peer",
+		Long: `Forward a port of the public interface to a port of a peer.
+The rules are kept in the configuration file of the interface, so they survive restarts,
+and are removed with the peer. The site-to-site peers and the peers with the split route
+profile reply through their own uplink, so their forwarded connections are masqueraded
+and come from the tunnel address of the server.`,
+		Example: "  fwg forward add wg0 --peer laptop --proto tcp --public-port 8080 --peer-port 80",
+		Args:    cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := wireguard.AddPortForward(args[0], opts); err != nil {
+				fmt.Printf("Error in forwarding the port: %v\n", err)
+			}
+		},
+	}
+
+	addCmd.Flags().StringVar(&opts.PeerName, "peer", "", "name or public key of the peer")
+	addCmd.Flags().StringVar(&opts.Proto, "proto", "tcp", "protocol of the port (tcp or udp)")
+	addCmd.Flags().IntVar(&opts.PublicPort, "public-port", 0, "port on the public interface")
+	addCmd.Flags().IntVar(&opts.PeerPort, "peer-port", 0, "port on the peer (defaults to the public port)")
+	addCmd.MarkFlagRequired("peer")
+	addCmd.MarkFlagRequired("public-port")
+	addCmd.PreRun = func(cmd *cobra.Command, args []string) {
+		if opts.PeerPort == 0 {
+			opts.PeerPort = opts.PublicPort
+		}
+	}
+
+	return addCmd
+}
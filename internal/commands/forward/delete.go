@@ -0,0 +1,33 @@
+package forward
+
+import (
+	"fast-wireguard/internal/wireguard"
+	"fmt"
+	"strconv"
+	"github.com/spf13/cobra"
+)
+
+// createDeleteCmd represents the command to stop forwarding a public port.
+func createDeleteCmd() *cobra.Command {
+	var proto string
+	var deleteCmd = &cobra.Command{
+		Use:     "delete <interface> <public-port>",
+		Aliases: []string{"remove"},
+		Short:   "Stop forwarding a port of the public interface",
+		Args:    cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			publicPort, err := strconv.Atoi(args[1])
+			if err != nil {
+				fmt.Printf("Invalid port %s\n", args[1])
+				return
+			}
+			if err := wireguard.DeletePortForward(args[0], proto, publicPort); err != nil {
+				fmt.Printf("Error in removing the forwarded port: %v\n", err)
+			}
+		},
+	}
+
+	deleteCmd.Flags().StringVar(&proto, "proto", "tcp", "protocol of the port (tcp or udp)")
+
+	return deleteCmd
+}
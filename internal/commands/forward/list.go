@@ -0,0 +1,32 @@
+package forward
+
+import (
+	"fast-wireguard/internal/wireguard"
+	"fmt"
+	"github.com/spf13/cobra"
+)
+
+// createListCmd represents the command to list the forwarded ports of an interface.
+func createListCmd() *cobra.Command {
+	var listCmd = &cobra.Command{
+		Use:     "list <interface>",
+		Aliases: []string{"ls"},
+		Short:   "List the forwarded ports of the interface",
+		Args:    cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			rules, err := wireguard.ListPortForwards(args[0])
+			if err != nil {
+				fmt.Printf("Error in listing the forwarded ports: %v\n", err)
+				return
+			}
+			if len(rules) == 0 {
+				fmt.Printf("No port is forwarded on %s.\n", args[0])
+				return
+			}
+			for _, rule := range rules {
+				fmt.Println(wireguard.FormatPortForward(rule))
+			}
+		},
+	}
+	return listCmd
+}
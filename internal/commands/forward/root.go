@@ -0,0 +1,28 @@
+package forward
+
+import (
+	"fast-wireguard/pkg/utils"
+	"github.com/spf13/cobra"
+)
+
+/*
+CreateForwardCmd represents the forward command to publish the ports of the peers on the server.
+*/
+func CreateForwardCmd() *cobra.Command {
+	var forwardCmd = &cobra.Command{
+		Use:   "forward",
+		Short: "Forward ports of the public interface to the peers",
+		PersistentPreRun: func(cmd *cobra.Command, args []string) {
+			utils.EnsureRoot()
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			cmd.Help()
+		},
+	}
+
+	forwardCmd.AddCommand(createAddCmd())
+	forwardCmd.AddCommand(createListCmd())
+	forwardCmd.AddCommand(createDeleteCmd())
+
+	return forwardCmd
+}
@@ -0,0 +1,47 @@
+package mesh
+
+import (
+	"fast-wireguard/internal/wireguard"
+	"fmt"
+	"os"
+	"github.com/spf13/cobra"
+)
+
+// createAddCmd represents the command to add a node to a mesh.
+func createAddCmd() *cobra.Command {
+	node := wireguard.MeshNodeSpec{}
+	var addCmd = &cobra.Command{
+		Use:   "add <mesh> <node>",
+		Short: "Add a node to the mesh and update the configurations of the others",
+		Args:  cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			node.Name = args[1]
+			if err := wireguard.AddMeshNode(args[0], node); err != nil {
+				fmt.Printf("Error in adding the node: %v\n", err)
+				os.Exit(1)
+			}
+		},
+	}
+
+	addCmd.Flags().StringVarP(&node.Endpoint, "endpoint", "e", "", "host[:port] the other nodes connect to (empty if not reachable)")
+	addCmd.Flags().StringVarP(&node.Address, "address", "a", "", "address of the node in the mesh network (allocated if empty)")
+
+	return addCmd
+}
+
+// createRemoveCmd represents the command to remove a node from a mesh.
+func createRemoveCmd() *cobra.Command {
+	var removeCmd = &cobra.Command{
+		Use:     "remove <mesh> <node>",
+		Aliases: []string{"rm"},
+		Short:   "Remove a node from the mesh and update the configurations of the others",
+		Args:    cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := wireguard.RemoveMeshNode(args[0], args[1]); err != nil {
+				fmt.Printf("Error in removing the node: %v\n", err)
+				os.Exit(1)
+			}
+		},
+	}
+	return removeCmd
+}
@@ -0,0 +1,47 @@
+package mesh
+
+import (
+	"fast-wireguard/internal/wireguard"
+	"fmt"
+	"os"
+	"github.com/spf13/cobra"
+)
+
+// createCreateCmd represents the command to create a mesh from a list of nodes.
+func createCreateCmd() *cobra.Command {
+	var network string
+	var listenPort, mtu int
+	var nodeSpecs []string
+	var createCmd = &cobra.Command{
+		Use:   "create <mesh>",
+		Short: "Create a mesh and generate the configuration of its nodes",
+		Long: `Create a full-mesh network, whose name is also the interface name on the nodes.
+Each node is given as "name,endpoint,address": the endpoint (host[:port]) may be empty
+for a node behind NAT, and the address is allocated from the network if empty.`,
+		Example: `  fwg mesh create mesh0 --network 10.10.0.0/24 \
+    --node paris,paris.example.com --node berlin,203.0.113.7:51900 --node laptop`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			var nodes []wireguard.MeshNodeSpec
+			for _, spec := range nodeSpecs {
+				node, err := wireguard.ParseMeshNode(spec)
+				if err != nil {
+					fmt.Printf("Error in creating the mesh: %v\n", err)
+					os.Exit(1)
+				}
+				nodes = append(nodes, node)
+			}
+			if err := wireguard.CreateMesh(args[0], network, listenPort, mtu, nodes); err != nil {
+				fmt.Printf("Error in creating the mesh: %v\n", err)
+				os.Exit(1)
+			}
+		},
+	}
+
+	createCmd.Flags().StringVar(&network, "network", "10.10.0.0/24", "network of the addresses of the nodes")
+	createCmd.Flags().IntVarP(&listenPort, "port", "p", 51820, "listening port of the nodes whose endpoint has no port")
+	createCmd.Flags().IntVarP(&mtu, "mtu", "m", 0, "the length of MTU (left to wg-quick if 0)")
+	createCmd.Flags().StringArrayVar(&nodeSpecs, "node", nil, "node given as name,endpoint,address (repeatable)")
+
+	return createCmd
+}
@@ -0,0 +1,40 @@
+package mesh
+
+import (
+	"fast-wireguard/internal/wireguard"
+	"fmt"
+	"os"
+	"github.com/spf13/cobra"
+)
+
+// createExportCmd represents the command to export an installable bundle per node.
+func createExportCmd() *cobra.Command {
+	var exportCmd = &cobra.Command{
+		Use:   "export <mesh> <directory>",
+		Short: "Write a bundle per node: its configuration and an install script",
+		Args:  cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := wireguard.ExportMesh(args[0], args[1]); err != nil {
+				fmt.Printf("Error in exporting the mesh: %v\n", err)
+				os.Exit(1)
+			}
+		},
+	}
+	return exportCmd
+}
+
+// createDeleteCmd represents the command to forget a mesh.
+func createDeleteCmd() *cobra.Command {
+	var deleteCmd = &cobra.Command{
+		Use:   "delete <mesh>",
+		Short: "Forget the mesh and remove the configurations of its nodes",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := wireguard.DeleteMesh(args[0]); err != nil {
+				fmt.Printf("Error in deleting the mesh: %v\n", err)
+				os.Exit(1)
+			}
+		},
+	}
+	return deleteCmd
+}
@@ -0,0 +1,34 @@
+package mesh
+
+import (
+	"fast-wireguard/internal/wireguard"
+	"fmt"
+	"os"
+	"github.com/spf13/cobra"
+)
+
+// createListCmd represents the command to list the nodes of a mesh.
+func createListCmd() *cobra.Command {
+	var listCmd = &cobra.Command{
+		Use:     "list <mesh>",
+		Aliases: []string{"ls"},
+		Short:   "List the nodes of the mesh",
+		Args:    cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			mesh, err := wireguard.GetMesh(args[0])
+			if err != nil {
+				fmt.Printf("Error in listing the nodes: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Printf("Mesh %s (%s)\n", mesh.Name, mesh.Network)
+			for _, node := range mesh.Nodes {
+				endpoint := node.Endpoint
+				if endpoint == "" {
+					endpoint = "<not reachable>"
+				}
+				fmt.Printf("  %-16s %-16s %s\n", node.Name, node.Address, endpoint)
+			}
+		},
+	}
+	return listCmd
+}
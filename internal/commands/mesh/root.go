@@ -0,0 +1,34 @@
+package mesh
+
+import (
+	"fast-wireguard/pkg/utils"
+	"github.com/spf13/cobra"
+)
+
+/*
+CreateMeshCmd represents the mesh command to generate full-mesh networks across several nodes.
+*/
+func CreateMeshCmd() *cobra.Command {
+	var meshCmd = &cobra.Command{
+		Use:   "mesh",
+		Short: "Generate a full-mesh network where every node is a peer of all the others",
+		Long: `Generate the WireGuard configuration of every node of a full-mesh network.
+The configurations are written to /etc/wireguard/mesh/<mesh>/, use "mesh export"
+to get an installable bundle per node.`,
+		PersistentPreRun: func(cmd *cobra.Command, args []string) {
+			utils.EnsureRoot()
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			cmd.Help()
+		},
+	}
+
+	meshCmd.AddCommand(createCreateCmd())
+	meshCmd.AddCommand(createAddCmd())
+	meshCmd.AddCommand(createRemoveCmd())
+	meshCmd.AddCommand(createListCmd())
+	meshCmd.AddCommand(createExportCmd())
+	meshCmd.AddCommand(createDeleteCmd())
+
+	return meshCmd
+}
@@ -0,0 +1,55 @@
+package peer
+
+import (
+	"fast-wireguard/internal/wireguard"
+	"fmt"
+	"github.com/spf13/cobra"
+)
+
+// createAddCmd represents the command to add a peer to an existing interface.
+func createAddCmd() *cobra.Command {
+	opts := &wireguard.PeerOptions{}
+	var addCmd = &cobra.Command{
+		Use:   "add [interface]",
+		Short: "Add a peer to the interface and print its client configuration",
+		Long: `Add a peer to an existing WireGuard interface.
+If no public key is provided, a new key pair is generated for the peer.
+Client addresses of a family not carried by the interface (see "create --family") are ignored.
+With --subnets, the peer is a site-to-site router: the server routes the subnets to it, and its
+configuration routes the tunnel, the LANs of the server and the other sites.`,
+		Args: cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			interfaceName := "wg0"
+			if len(args) > 0 {
+				interfaceName = args[0]
+			}
+
+			clientConfString, err := wireguard.AddPeer(interfaceName, opts)
+			if err != nil {
+				fmt.Printf("Error in adding the peer: %v\n", err)
+				return
+			}
+			if err := wireguard.ReloadService(interfaceName); err != nil {
+				fmt.Printf("Error in reloading the service %s: %v\n", interfaceName, err)
+				return
+			}
+
+			fmt.Println("\nClient configuration:")
+			fmt.Println("------------------------------------------------")
+			fmt.Println(clientConfString)
+			fmt.Println("------------------------------------------------")
+		},
+	}
+
+	addCmd.Flags().StringVarP(&opts.PeerName, "peer-name", "n", "default-peer", "name of the WireGuard client peer")
+	addCmd.Flags().StringVarP(&opts.IPAdressLocalClient, "address-client", "c", "10.0.0.[auto-ipv4]/32, fd00::[auto-ipv6]/128", "local IP address assigned to WireGuard client")
+	addCmd.Flags().StringVar(&opts.PubKeyClient, "public-key", "", "public key of the peer (generated if empty)")
+	addCmd.Flags().StringVar(&opts.PriKeyClient, "private-key", "", "private key of the peer, only used in the client configuration")
+	addCmd.Flags().StringVar(&opts.Subnets, "subnets", "", "LAN subnets behind the peer, making it a site-to-site router")
+	addCmd.Flags().StringVar(&opts.Routes, "routes", "full", "route profile of the peer: full (all its traffic) or split (only the networks behind the server)")
+	addCmd.Flags().StringVar(&opts.Owner, "owner", "", "owner of the peer, e.g. a user or a team")
+	addCmd.Flags().StringVar(&opts.DNS, "dns", "", "DNS servers of this peer (overrides the interface setting)")
+	addCmd.Flags().StringVar(&opts.DNSSearch, "dns-search", "", "DNS search domains of this peer (overrides the interface setting)")
+
+	return addCmd
+}
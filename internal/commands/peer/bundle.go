@@ -0,0 +1,62 @@
+package peer
+
+import (
+	"fast-wireguard/internal/wireguard"
+	"fast-wireguard/pkg/utils"
+	"fmt"
+	"os"
+	"strings"
+	"github.com/spf13/cobra"
+)
+
+// createBundleCmd represents the command to bundle the client configurations of peers into a ZIP archive.
+func createBundleCmd() *cobra.Command {
+	var output, passphraseFile string
+	var encrypt bool
+	var bundleCmd = &cobra.Command{
+		Use:   "bundle <interface> [peer...]",
+		Short: "Write the client configurations and QR codes of peers (all if none given) into a ZIP archive",
+		Long: `Write a ZIP archive with one .conf file and one PNG QR code per peer, and a README listing them.
+With --encrypt or --passphrase-file, the files are encrypted with the traditional ZIP encryption,
+which every archiver opens but which only protects against casual access.`,
+		Args: cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			interfaceName := args[0]
+			if output == "" {
+				output = fmt.Sprintf("%s-peers.zip", interfaceName)
+			}
+
+			var passphrase string
+			switch {
+			case passphraseFile != "":
+				content, err := os.ReadFile(passphraseFile)
+				if err != nil {
+					fmt.Printf("Error in reading the passphrase: %v\n", err)
+					os.Exit(1)
+				}
+				passphrase = strings.TrimRight(string(content), "\r\n")
+				if passphrase == "" {
+					fmt.Printf("Error in reading the passphrase: the file %s is empty\n", passphraseFile)
+					os.Exit(1)
+				}
+			case encrypt:
+				passphrase = utils.PromptPassword("Passphrase of the archive:")
+				if passphrase == "" || utils.PromptPassword("Passphrase again:") != passphrase {
+					fmt.Println("Error in reading the passphrase: the passphrases do not match")
+					os.Exit(1)
+				}
+			}
+
+			if err := wireguard.BundlePeers(interfaceName, args[1:], output, passphrase); err != nil {
+				fmt.Printf("Error in bundling the peers: %v\n", err)
+				os.Exit(1)
+			}
+		},
+	}
+
+	bundleCmd.Flags().StringVarP(&output, "output", "o", "", "path of the archive (<interface>-peers.zip by default)")
+	bundleCmd.Flags().BoolVar(&encrypt, "encrypt", false, "encrypt the archive with a passphrase asked for")
+	bundleCmd.Flags().StringVar(&passphraseFile, "passphrase-file", "", "encrypt the archive with the passphrase read from the file")
+
+	return bundleCmd
+}
@@ -0,0 +1,33 @@
+package peer
+
+import (
+	"fast-wireguard/internal/wireguard"
+	"fmt"
+	"os"
+	"github.com/spf13/cobra"
+)
+
+// createConfigCmd represents the command to print the current client configuration of a peer.
+func createConfigCmd() *cobra.Command {
+	var configCmd = &cobra.Command{
+		Use:   "config <interface> <peer>",
+		Short: "Print the client configuration of a peer (given by name or public key)",
+		Long: `Print the current client configuration of a peer.
+Use it to update the site-to-site peers after adding or removing another site.`,
+		Args: cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			data, err := wireguard.BuildClientConfig(args[0], args[1])
+			if err != nil {
+				fmt.Printf("Error in building the client configuration: %v\n", err)
+				os.Exit(1)
+			}
+			clientConfString, err := wireguard.RenderClientConfig(data)
+			if err != nil {
+				fmt.Printf("Error in building the client configuration: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Print(clientConfString)
+		},
+	}
+	return configCmd
+}
@@ -0,0 +1,28 @@
+package peer
+
+import (
+	"fast-wireguard/internal/wireguard"
+	"fmt"
+	"github.com/spf13/cobra"
+)
+
+// createDeleteCmd represents the command to remove a peer from an interface.
+func createDeleteCmd() *cobra.Command {
+	var deleteCmd = &cobra.Command{
+		Use:     "delete <interface> <peer>",
+		Aliases: []string{"remove"},
+		Short:   "Remove a peer (given by name or public key) from the interface",
+		Args:    cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			interfaceName, peerName := args[0], args[1]
+			if err := wireguard.DeletePeer(interfaceName, peerName); err != nil {
+				fmt.Printf("Error in deleting the peer: %v\n", err)
+				return
+			}
+			if err := wireguard.ReloadService(interfaceName); err != nil {
+				fmt.Printf("Error in reloading the service %s: %v\n", interfaceName, err)
+			}
+		},
+	}
+	return deleteCmd
+}
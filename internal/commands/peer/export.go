@@ -0,0 +1,150 @@
+package peer
+
+import (
+	"fast-wireguard/internal/wireguard"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"github.com/spf13/cobra"
+)
+
+// createExportCmd represents the command to export the client configuration of peers for other network managers.
+func createExportCmd() *cobra.Command {
+	opts := &wireguard.ExportOptions{}
+	var output string
+	var csvOutput bool
+	var exportCmd = &cobra.Command{
+		Use:   "export <interface> [peer]...",
+		Short: "Export the client configuration of peers (given by name or public key) for a network manager",
+		Long: fmt.Sprintf(`Export the client configuration of peers in the format of a network manager of the client.
+The files are printed, or written to the directory given with --output.
+Formats: %s.
+With --csv, the peers (all if none given) are exported as a CSV file for "fwg peer import"
+instead, without their private keys.`, strings.Join(wireguard.ExportFormats(), ", ")),
+		Example: `  fwg peer export wg0 alice --format networkmanager -o /tmp/alice
+  fwg peer export wg0 sidecar-a sidecar-b --format k8s-secret --k8s-namespace vpn --k8s-label app=proxy
+  fwg peer export wg0 --csv > peers.csv`,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if csvOutput {
+				return cobra.MinimumNArgs(1)(cmd, args)
+			}
+			return cobra.MinimumNArgs(2)(cmd, args)
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			if csvOutput {
+				exportCSV(args[0], args[1:], output)
+				return
+			}
+
+			files, err := wireguard.ExportClientConfigs(args[0], args[1:], opts)
+			if err != nil {
+				fmt.Printf("Error in exporting the client configuration: %v\n", err)
+				os.Exit(1)
+			}
+
+			if output == "" {
+				for i, file := range files {
+					switch {
+					case strings.HasSuffix(file.Name, ".yaml"):
+						// The manifests are printed as one YAML stream
+						if i > 0 {
+							fmt.Println("---")
+						}
+					case len(files) > 1:
+						fmt.Printf("==> %s (mode %04o%s) <==\n", file.Name, file.Mode, ownerNote(file.Owner))
+					}
+					fmt.Print(file.Content)
+				}
+				return
+			}
+			for _, file := range files {
+				path := filepath.Join(output, file.Name)
+				if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+					fmt.Printf("Error in exporting the client configuration: %v\n", err)
+					os.Exit(1)
+				}
+				f, err := createPrivateFile(path, os.FileMode(file.Mode))
+				if err == nil {
+					_, err = f.WriteString(file.Content)
+					if closeErr := f.Close(); err == nil {
+						err = closeErr
+					}
+				}
+				if err != nil {
+					fmt.Printf("Error in exporting the client configuration: %v\n", err)
+					os.Exit(1)
+				}
+				fmt.Printf("✅ %s written (install it with mode %04o%s).\n", path, file.Mode, ownerNote(file.Owner))
+			}
+		},
+	}
+
+	exportCmd.Flags().StringVarP(&opts.Format, "format", "f", "wg-quick", "export format: "+strings.Join(wireguard.ExportFormats(), ", "))
+	exportCmd.Flags().StringVarP(&output, "output", "o", "", "directory the files are written to (printed if empty)")
+	exportCmd.Flags().BoolVar(&csvOutput, "csv", false, "export the peers as a CSV file for \"fwg peer import\"")
+	exportCmd.Flags().StringVar(&opts.Name, "k8s-name", "", "name of the Secret (<interface>-<peer> if empty, the prefix of the names with several peers)")
+	exportCmd.Flags().StringVar(&opts.Namespace, "k8s-namespace", "", "namespace of the Secret")
+	exportCmd.Flags().StringToStringVar(&opts.Labels, "k8s-label", nil, "labels of the Secret (key=value, repeatable)")
+	exportCmd.Flags().BoolVar(&opts.ConfigMap, "k8s-configmap", false, "also emit a ConfigMap with the parts of the configuration which are not secret")
+	exportCmd.Flags().StringVar(&opts.DisplayName, "display-name", "", "name of the Apple profile and of the tunnel (\"<peer> (<interface>)\" if empty)")
+	exportCmd.Flags().StringVar(&opts.Platform, "apple-platform", "ios", "platform of the Apple profile: ios or macos")
+	exportCmd.Flags().StringVar(&opts.OnDemand, "on-demand", "off", "connect the Apple profile on demand: off, always or untrusted")
+	exportCmd.Flags().StringSliceVar(&opts.TrustedSSIDs, "trusted-ssids", nil, "Wi-Fi networks on which the untrusted on-demand mode disconnects")
+
+	return exportCmd
+}
+
+// ownerNote describes the owner of an exported file other than root.
+func ownerNote(owner string) string {
+	if owner == "" {
+		return ""
+	}
+	return ", owned by " + owner
+}
+
+// exportCSV prints the peers as CSV, or writes them to "<interface>-peers.csv" in the output directory.
+func exportCSV(interfaceName string, peerNames []string, output string) {
+	records, err := wireguard.ExportPeerRecords(interfaceName, peerNames)
+	if err != nil {
+		fmt.Printf("Error in exporting the peers: %v\n", err)
+		os.Exit(1)
+	}
+	if output == "" {
+		if err := wireguard.WritePeerRecords(os.Stdout, records, "csv"); err != nil {
+			fmt.Printf("Error in exporting the peers: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	path := filepath.Join(output, fmt.Sprintf("%s-peers.csv", interfaceName))
+	if err := os.MkdirAll(output, 0700); err != nil {
+		fmt.Printf("Error in exporting the peers: %v\n", err)
+		os.Exit(1)
+	}
+	f, err := createPrivateFile(path, 0600)
+	if err != nil {
+		fmt.Printf("Error in exporting the peers: %v\n", err)
+		os.Exit(1)
+	}
+	defer f.Close()
+	if err := wireguard.WritePeerRecords(f, records, "csv"); err != nil {
+		fmt.Printf("Error in exporting the peers: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("✅ %d peers written to %s\n", len(records), path)
+}
+
+// createPrivateFile creates or truncates the file, and gives the mode to an existing file before anything is written to it.
+func createPrivateFile(path string, mode os.FileMode) (*os.File, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.Chmod(mode); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
@@ -0,0 +1,77 @@
+package peer
+
+import (
+	"fast-wireguard/internal/wireguard"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"github.com/spf13/cobra"
+)
+
+// createImportCmd represents the command to add the peers of a CSV or YAML file to an existing interface.
+func createImportCmd() *cobra.Command {
+	var defaultAddress, results string
+	var importCmd = &cobra.Command{
+		Use:   "import <interface> <peers.csv|peers.yaml>",
+		Short: "Add the peers of a CSV or YAML file to the interface, with a single reload",
+		Long: `Add the peers of a CSV file (with a header row) or a YAML list to an existing WireGuard interface.
+Columns (or keys): name, address, public_key, private_key, subnets, routes (full or split) and owner.
+Only the name is required: the peers without an address get --address-client, and a key pair is
+generated for the peers without a public key.
+The entries are all checked first, no peer is added if one is invalid. The peers are then written to
+the configuration file at once and the service is reloaded once.
+The peers as added, with their addresses and private keys, are written to the results file.`,
+		Example: `  fwg peer import wg0 peers.csv
+  # peers.csv
+  name,address,public_key,routes,owner
+  alice,,,full,alice@example.com
+  build-agent,10.0.0.50/32,,split,ci-team`,
+		Args: cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			interfaceName, path := args[0], args[1]
+			format, err := wireguard.PeerRecordFormat(path)
+			if err != nil {
+				fmt.Printf("Error in importing the peers: %v\n", err)
+				os.Exit(1)
+			}
+			if results == "" {
+				results = strings.TrimSuffix(path, filepath.Ext(path)) + "-results" + filepath.Ext(path)
+			}
+
+			records, err := wireguard.ReadPeerRecords(path)
+			if err != nil {
+				fmt.Printf("Error in importing the peers: %v\n", err)
+				os.Exit(1)
+			}
+			added, err := wireguard.ImportPeers(interfaceName, records, defaultAddress)
+			if err != nil {
+				fmt.Printf("Error in importing the peers: %v\n", err)
+				os.Exit(1)
+			}
+
+			// The results hold the private keys of the peers
+			f, err := createPrivateFile(results, 0600)
+			if err != nil {
+				fmt.Printf("Error in writing the results: %v\n", err)
+			} else {
+				if err := wireguard.WritePeerRecords(f, added, format); err != nil {
+					fmt.Printf("Error in writing the results: %v\n", err)
+				} else {
+					fmt.Printf("✅ Results written to %s (it contains the private keys of the peers).\n", results)
+				}
+				f.Close()
+			}
+
+			if err := wireguard.ReloadService(interfaceName); err != nil {
+				fmt.Printf("Error in reloading the service %s: %v\n", interfaceName, err)
+				os.Exit(1)
+			}
+		},
+	}
+
+	importCmd.Flags().StringVarP(&defaultAddress, "address-client", "c", "10.0.0.[auto-ipv4]/32, fd00::[auto-ipv6]/128", "local IP address of the peers without an address")
+	importCmd.Flags().StringVar(&results, "results", "", "file the peers as added are written to (<file>-results.<ext> by default)")
+
+	return importCmd
+}
@@ -0,0 +1,80 @@
+package peer
+
+import (
+	"fast-wireguard/internal/wireguard"
+	"fast-wireguard/pkg/utils"
+	"fmt"
+	"os"
+	"github.com/spf13/cobra"
+)
+
+// createQRCmd represents the command to render the client configuration of a peer as a QR code.
+func createQRCmd() *cobra.Command {
+	var format, output, level string
+	var size int
+	var qrCmd = &cobra.Command{
+		Use:   "qr <interface> <peer>",
+		Short: "Render the client configuration of a peer (given by name or public key) as a QR code",
+		Long: `Render the client configuration of a peer as a QR code, to scan with the WireGuard app.
+The format is "terminal" (printed), "png" or "svg" (written to --output, <peer>.<format> by default).
+A higher error correction level makes the code more robust but holds a shorter configuration.`,
+		Args: cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			qrLevel, err := utils.ParseQRLevel(level)
+			if err == nil && size <= 0 {
+				err = fmt.Errorf("invalid size %d", size)
+			}
+			if err != nil {
+				fmt.Printf("Error in rendering the QR code: %v\n", err)
+				os.Exit(1)
+			}
+			data, err := wireguard.BuildClientConfig(args[0], args[1])
+			if err != nil {
+				fmt.Printf("Error in building the client configuration: %v\n", err)
+				os.Exit(1)
+			}
+			clientConfString, err := wireguard.RenderClientConfig(data)
+			if err != nil {
+				fmt.Printf("Error in building the client configuration: %v\n", err)
+				os.Exit(1)
+			}
+			if err := utils.CheckQRCapacity(clientConfString, qrLevel); err != nil {
+				fmt.Printf("Error in rendering the QR code: %v\n", err)
+				os.Exit(1)
+			}
+
+			if output == "" {
+				// The peer may be given by its public key, which is not a file name
+				peer, err := wireguard.FindPeer(args[0], args[1])
+				if err != nil {
+					fmt.Printf("Error in rendering the QR code: %v\n", err)
+					os.Exit(1)
+				}
+				output = fmt.Sprintf("%s.%s", peer.PeerName, format)
+			}
+			switch format {
+			case "terminal":
+				utils.PrintQRCode("Scan this QR code with your WireGuard App:\n", clientConfString, qrLevel)
+				return
+			case "png":
+				err = utils.WriteQRCodePNG(output, clientConfString, qrLevel, size)
+			case "svg":
+				err = utils.WriteQRCodeSVG(output, clientConfString, qrLevel, size)
+			default:
+				err = fmt.Errorf("invalid format %s, use terminal, png or svg", format)
+			}
+			if err != nil {
+				fmt.Printf("Error in rendering the QR code: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Printf("✅ QR code written to %s\n", output)
+		},
+	}
+
+	qrCmd.Flags().StringVarP(&format, "format", "f", "terminal", "output format: terminal, png or svg")
+	qrCmd.Flags().StringVarP(&output, "output", "o", "", "file written for png and svg (<peer>.<format> by default)")
+	qrCmd.Flags().IntVarP(&size, "size", "s", 512, "width of the png or svg image in pixels")
+	qrCmd.Flags().StringVarP(&level, "level", "l", "L", "error correction level: L, M, Q or H")
+
+	return qrCmd
+}
@@ -0,0 +1,32 @@
+package peer
+
+import (
+	"fast-wireguard/pkg/utils"
+	"github.com/spf13/cobra"
+)
+
+/*
+CreatePeerCmd represents the peer command to manage the peers of an interface.
+*/
+func CreatePeerCmd() *cobra.Command {
+	var peerCmd = &cobra.Command{
+		Use:   "peer",
+		Short: "Manage the peers of a WireGuard interface",
+		PersistentPreRun: func(cmd *cobra.Command, args []string) {
+			utils.EnsureRoot()
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			cmd.Help()
+		},
+	}
+
+	peerCmd.AddCommand(createAddCmd())
+	peerCmd.AddCommand(createDeleteCmd())
+	peerCmd.AddCommand(createConfigCmd())
+	peerCmd.AddCommand(createQRCmd())
+	peerCmd.AddCommand(createExportCmd())
+	peerCmd.AddCommand(createBundleCmd())
+	peerCmd.AddCommand(createImportCmd())
+
+	return peerCmd
+}
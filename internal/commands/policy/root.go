@@ -0,0 +1,27 @@
+package policy
+
+import (
+	"fast-wireguard/pkg/utils"
+	"github.com/spf13/cobra"
+)
+
+/*
+CreatePolicyCmd represents the policy command to manage the traffic allowed through an interface.
+*/
+func CreatePolicyCmd() *cobra.Command {
+	var policyCmd = &cobra.Command{
+		Use:   "policy",
+		Short: "Manage the traffic allowed between the peers of an interface",
+		PersistentPreRun: func(cmd *cobra.Command, args []string) {
+			utils.EnsureRoot()
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			cmd.Help()
+		},
+	}
+
+	policyCmd.AddCommand(createSetCmd())
+	policyCmd.AddCommand(createShowCmd())
+
+	return policyCmd
+}
@@ -0,0 +1,61 @@
+package policy
+
+import (
+	"fast-wireguard/internal/wireguard"
+	"fmt"
+	"os"
+	"github.com/spf13/cobra"
+)
+
+// createSetCmd represents the command to change the policy of an interface.
+func createSetCmd() *cobra.Command {
+	var peerToPeer string
+	var setCmd = &cobra.Command{
+		Use:   "set [interface]",
+		Short: "Change the policy of the interface",
+		Long: `Change the policy of an existing interface.
+With --peer-to-peer deny, the server drops the traffic between its peers (including the
+subnets of the site-to-site peers) and the site-to-site peers only route the server and its LANs.`,
+		Args: cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			interfaceName := "wg0"
+			if len(args) > 0 {
+				interfaceName = args[0]
+			}
+			if !cmd.Flags().Changed("peer-to-peer") {
+				cmd.Help()
+				return
+			}
+			if err := wireguard.SetPeerToPeer(interfaceName, peerToPeer); err != nil {
+				fmt.Printf("Error in changing the policy of %s: %v\n", interfaceName, err)
+				os.Exit(1)
+			}
+		},
+	}
+
+	setCmd.Flags().StringVar(&peerToPeer, "peer-to-peer", "", "whether the peers can reach each other through the server: allow or deny")
+
+	return setCmd
+}
+
+// createShowCmd represents the command to print the policy of an interface.
+func createShowCmd() *cobra.Command {
+	var showCmd = &cobra.Command{
+		Use:   "show [interface]",
+		Short: "Print the policy of the interface",
+		Args:  cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			interfaceName := "wg0"
+			if len(args) > 0 {
+				interfaceName = args[0]
+			}
+			peerToPeer, err := wireguard.GetPeerToPeer(interfaceName)
+			if err != nil {
+				fmt.Printf("Error in loading the settings of %s: %v\n", interfaceName, err)
+				return
+			}
+			fmt.Printf("Peer-to-peer traffic: %s\n", peerToPeer)
+		},
+	}
+	return showCmd
+}
@@ -0,0 +1,45 @@
+package portmap
+
+import (
+	"fast-wireguard/internal/wireguard"
+	"fmt"
+	"os"
+	"github.com/spf13/cobra"
+)
+
+// createEnableCmd represents the command to map the port and start the renewal service.
+func createEnableCmd() *cobra.Command {
+	var enableCmd = &cobra.Command{
+		Use:   "enable <interface>",
+		Short: "Map the listen port on the gateway and keep renewing it",
+		Long: `Map the listen port on the gateway if the server is behind NAT, trying PCP,
+NAT-PMP and UPnP IGD in turn, and start the service renewing the lease.
+If the gateway supports none of them, the port has to be forwarded manually.`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := wireguard.EnablePortMapping(args[0]); err != nil {
+				fmt.Printf("Error in enabling the port mapping of %s: %v\n", args[0], err)
+				os.Exit(1)
+			}
+		},
+	}
+
+	return enableCmd
+}
+
+// createDisableCmd represents the command to stop the renewal service.
+func createDisableCmd() *cobra.Command {
+	var disableCmd = &cobra.Command{
+		Use:   "disable <interface>",
+		Short: "Stop renewing the port mapping and remove it from the gateway",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := wireguard.DisablePortMapping(args[0]); err != nil {
+				fmt.Printf("Error in disabling the port mapping of %s: %v\n", args[0], err)
+				os.Exit(1)
+			}
+		},
+	}
+
+	return disableCmd
+}
@@ -0,0 +1,29 @@
+package portmap
+
+import (
+	"fast-wireguard/pkg/utils"
+	"github.com/spf13/cobra"
+)
+
+/*
+CreatePortMapCmd represents the portmap command to forward the listen port on the NAT gateway.
+*/
+func CreatePortMapCmd() *cobra.Command {
+	var portmapCmd = &cobra.Command{
+		Use:   "portmap",
+		Short: "Map the listen port on the NAT gateway with PCP, NAT-PMP or UPnP",
+		PersistentPreRun: func(cmd *cobra.Command, args []string) {
+			utils.EnsureRoot()
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			cmd.Help()
+		},
+	}
+
+	portmapCmd.AddCommand(createStatusCmd())
+	portmapCmd.AddCommand(createEnableCmd())
+	portmapCmd.AddCommand(createDisableCmd())
+	portmapCmd.AddCommand(createRunCmd())
+
+	return portmapCmd
+}
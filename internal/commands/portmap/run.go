@@ -0,0 +1,25 @@
+package portmap
+
+import (
+	"fast-wireguard/internal/wireguard"
+	"fmt"
+	"os"
+	"github.com/spf13/cobra"
+)
+
+// createRunCmd represents the command run by the fwg-portmap@ systemd unit.
+func createRunCmd() *cobra.Command {
+	var runCmd = &cobra.Command{
+		Use:   "run <interface>",
+		Short: "Keep the port mapped on the gateway (used by systemd)",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := wireguard.RunPortMapping(args[0]); err != nil {
+				fmt.Printf("Error in running the port mapping of %s: %v\n", args[0], err)
+				os.Exit(1)
+			}
+		},
+	}
+
+	return runCmd
+}
@@ -0,0 +1,34 @@
+package portmap
+
+import (
+	"fast-wireguard/internal/wireguard"
+	"fmt"
+	"os"
+	"github.com/spf13/cobra"
+)
+
+// createStatusCmd represents the command to check whether the server is behind NAT.
+func createStatusCmd() *cobra.Command {
+	var statusCmd = &cobra.Command{
+		Use:   "status <interface>",
+		Short: "Check whether the server is behind NAT",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			status, err := wireguard.DetectNAT(args[0])
+			if err != nil {
+				fmt.Printf("Error in detecting the NAT of %s: %v\n", args[0], err)
+				os.Exit(1)
+			}
+			fmt.Printf("Local IP:   %s\n", status.LocalIP)
+			if status.PublicIP != nil {
+				fmt.Printf("Public IP:  %s\n", status.PublicIP)
+			}
+			if status.Gateway != nil {
+				fmt.Printf("Gateway:    %s\n", status.Gateway)
+			}
+			fmt.Printf("Behind NAT: %t\n", status.BehindNAT)
+		},
+	}
+
+	return statusCmd
+}
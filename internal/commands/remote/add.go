@@ -0,0 +1,59 @@
+package remote
+
+import (
+	"fast-wireguard/internal/wireguard"
+	"fmt"
+	"os"
+	"github.com/spf13/cobra"
+)
+
+// createAddCmd represents the command to register a remote server.
+func createAddCmd() *cobra.Command {
+	opts := &wireguard.RemoteServerOptions{}
+	var addCmd = &cobra.Command{
+		Use:   "add <name>",
+		Short: "Register a remote fwg server",
+		Long: `Register a remote fwg server, whose name is also the interface name of the client configurations.
+The pool is a part of the tunnel network of the server reserved for the bundles, so that the
+addresses given to the users do not collide with the peers added on the server itself.`,
+		Example: `  fwg remote add eu-west --public-key <key> --endpoint vpn-eu.example.com \
+    --pool "10.0.0.128/25, fd00::8000/113" --dns 10.0.0.1`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			opts.Name = args[0]
+			if err := wireguard.AddRemoteServer(opts); err != nil {
+				fmt.Printf("Error in registering the remote server: %v\n", err)
+				os.Exit(1)
+			}
+		},
+	}
+
+	addCmd.Flags().StringVar(&opts.PublicKey, "public-key", "", "public key of the remote server")
+	addCmd.Flags().StringVarP(&opts.Endpoint, "endpoint", "e", "", "host[:port] of the remote server (port 51820 if omitted)")
+	addCmd.Flags().StringVarP(&opts.Interface, "interface", "i", "wg0", "interface of the remote server")
+	addCmd.Flags().StringVar(&opts.Pool, "pool", "", "networks of the remote tunnel reserved for the bundles (e.g. 10.0.0.128/25)")
+	addCmd.Flags().StringVar(&opts.DNS, "dns", "", "DNS servers pushed to the clients of the remote server")
+	addCmd.Flags().IntVarP(&opts.MTU, "mtu", "m", 1420, "MTU recommended to the clients of the remote server")
+	addCmd.MarkFlagRequired("public-key")
+	addCmd.MarkFlagRequired("endpoint")
+	addCmd.MarkFlagRequired("pool")
+
+	return addCmd
+}
+
+// createRemoveCmd represents the command to forget a remote server.
+func createRemoveCmd() *cobra.Command {
+	var removeCmd = &cobra.Command{
+		Use:     "remove <name>",
+		Aliases: []string{"rm"},
+		Short:   "Forget a remote server and the clients given access to it",
+		Args:    cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := wireguard.RemoveRemoteServer(args[0]); err != nil {
+				fmt.Printf("Error in removing the remote server: %v\n", err)
+				os.Exit(1)
+			}
+		},
+	}
+	return removeCmd
+}
@@ -0,0 +1,36 @@
+package remote
+
+import (
+	"fast-wireguard/internal/wireguard"
+	"fast-wireguard/pkg/utils"
+	"fmt"
+	"os"
+	"github.com/spf13/cobra"
+)
+
+// createBundleCmd represents the command to generate the client bundle of a user.
+func createBundleCmd() *cobra.Command {
+	opts := &wireguard.BundleOptions{}
+	var servers string
+	var bundleCmd = &cobra.Command{
+		Use:   "bundle <user> <directory>",
+		Short: "Generate one client configuration per remote server for a user",
+		Long: `Generate for a user one client configuration per remote server, named after the server
+(e.g. eu-west.conf), and the "fwg peer add" commands to run on each server.
+The keys and addresses of the user are kept, running it again gives the same bundle.`,
+		Args: cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			opts.User, opts.OutDir = args[0], args[1]
+			opts.Servers = utils.SplitList(servers)
+			if err := wireguard.GenerateBundle(opts); err != nil {
+				fmt.Printf("Error in generating the bundle: %v\n", err)
+				os.Exit(1)
+			}
+		},
+	}
+
+	bundleCmd.Flags().StringVar(&servers, "servers", "", "remote servers of the bundle (all of them if empty)")
+	bundleCmd.Flags().BoolVar(&opts.SharedKey, "shared-key", false, "use the same key pair for all the servers")
+
+	return bundleCmd
+}
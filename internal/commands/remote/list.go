@@ -0,0 +1,35 @@
+package remote
+
+import (
+	"fast-wireguard/internal/wireguard"
+	"fmt"
+	"os"
+	"strings"
+	"github.com/spf13/cobra"
+)
+
+// createListCmd represents the command to list the remote servers.
+func createListCmd() *cobra.Command {
+	var listCmd = &cobra.Command{
+		Use:     "list",
+		Aliases: []string{"ls"},
+		Short:   "List the remote servers",
+		Args:    cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			servers, err := wireguard.ListRemoteServers()
+			if err != nil {
+				fmt.Printf("Error in listing the remote servers: %v\n", err)
+				os.Exit(1)
+			}
+			if len(servers) == 0 {
+				fmt.Println("No remote server registered.")
+				return
+			}
+			for _, server := range servers {
+				fmt.Printf("%-16s %-32s %-24s %d clients\n",
+					server.Name, server.Endpoint, strings.Join(server.Pool, ", "), len(server.Clients))
+			}
+		},
+	}
+	return listCmd
+}
@@ -0,0 +1,31 @@
+package remote
+
+import (
+	"fast-wireguard/pkg/utils"
+	"github.com/spf13/cobra"
+)
+
+/*
+CreateRemoteCmd represents the remote command to generate client bundles for the fwg servers of other hosts.
+*/
+func CreateRemoteCmd() *cobra.Command {
+	var remoteCmd = &cobra.Command{
+		Use:   "remote",
+		Short: "Register the fwg servers of other regions and generate multi-exit client bundles",
+		Long: `Register the fwg servers of other hosts (e.g. one per region) with their public key and endpoint,
+then generate for a user one client configuration per server with "remote bundle".`,
+		PersistentPreRun: func(cmd *cobra.Command, args []string) {
+			utils.EnsureRoot()
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			cmd.Help()
+		},
+	}
+
+	remoteCmd.AddCommand(createAddCmd())
+	remoteCmd.AddCommand(createRemoveCmd())
+	remoteCmd.AddCommand(createListCmd())
+	remoteCmd.AddCommand(createBundleCmd())
+
+	return remoteCmd
+}
@@ -2,7 +2,15 @@ package commands
 
 import (
 	"fast-wireguard/internal/commands/create"
+	"fast-wireguard/internal/commands/ddns"
 	"fast-wireguard/internal/commands/delete"
+	"fast-wireguard/internal/commands/dns"
+	"fast-wireguard/internal/commands/forward"
+	"fast-wireguard/internal/commands/mesh"
+	"fast-wireguard/internal/commands/peer"
+	"fast-wireguard/internal/commands/policy"
+	"fast-wireguard/internal/commands/portmap"
+	"fast-wireguard/internal/commands/remote"
 	"fast-wireguard/internal/commands/uninstall"
 	"github.com/spf13/cobra"
 )
@@ -23,6 +31,14 @@ func CreateRootCmd(version string) *cobra.Command {
 	rootCmd.AddCommand(create.CreateCreateCmd())
 	rootCmd.AddCommand(delete.CreateDeleteCmd())
 	rootCmd.AddCommand(uninstall.CreateUninstallCmd())
+	rootCmd.AddCommand(peer.CreatePeerCmd())
+	rootCmd.AddCommand(dns.CreateDNSCmd())
+	rootCmd.AddCommand(forward.CreateForwardCmd())
+	rootCmd.AddCommand(ddns.CreateDDNSCmd())
+	rootCmd.AddCommand(portmap.CreatePortMapCmd())
+	rootCmd.AddCommand(mesh.CreateMeshCmd())
+	rootCmd.AddCommand(policy.CreatePolicyCmd())
+	rootCmd.AddCommand(remote.CreateRemoteCmd())
 
 
 	rootCmd.Flags().BoolP("version", "v", false, "the version of fast-wireguard")
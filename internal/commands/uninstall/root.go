@@ -45,6 +45,11 @@ func CreateUninstallCmd() *cobra.Command {
 				fmt.Println("Skipping deletion of WireGuard configuration files.")
 			}
 
+			// 3. Remove the systemd units of the fwg services
+			if err := wireguard.RemoveServiceUnits(); err != nil {
+				fmt.Println("Error removing the service units:", err)
+			}
+
 			// 4. Remove the binary file
 			binaryPath := "/usr/local/bin/fwg"
 			if err := os.Remove(binaryPath); err != nil {
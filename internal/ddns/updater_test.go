@@ -0,0 +1,148 @@
+package ddns
+
+import (
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+const (
+	testKeyName = "fwg-key."
+	testSecret  = "c2VjcmV0c2VjcmV0c2VjcmV0c2VjcmV0c2VjcmV0MTI="
+)
+
+// updateServer runs a local DNS server over TCP which verifies the TSIG of the updates with the secret,
+// records the valid ones and refuses the others with NOTAUTH.
+func updateServer(t *testing.T, secret string) (string, func() []*dns.Msg) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	var updates []*dns.Msg
+	handler := dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		if r.IsTsig() == nil || w.TsigStatus() != nil {
+			m.Rcode = dns.RcodeNotAuth
+			w.WriteMsg(m)
+			return
+		}
+		mu.Lock()
+		updates = append(updates, r.Copy())
+		mu.Unlock()
+		m.SetTsig(testKeyName, dns.HmacSHA256, 300, time.Now().Unix())
+		w.WriteMsg(m)
+	})
+	started := make(chan struct{})
+	server := &dns.Server{
+		Listener:          listener,
+		Net:               "tcp",
+		Handler:           handler,
+		TsigSecret:        map[string]string{testKeyName: secret},
+		NotifyStartedFunc: func() { close(started) },
+		// The default function refuses the UPDATE opcode
+		MsgAcceptFunc: func(dh dns.Header) dns.MsgAcceptAction { return dns.MsgAccept },
+	}
+	go server.ActivateAndServe()
+	<-started
+	t.Cleanup(func() { server.Shutdown() })
+
+	return listener.Addr().String(), func() []*dns.Msg {
+		mu.Lock()
+		defer mu.Unlock()
+		return updates
+	}
+}
+
+func TestRFC2136UpdaterUpdate(t *testing.T) {
+	tests := []struct {
+		name   string
+		ip     string
+		rrtype uint16
+	}{
+		{"ipv4", "203.0.113.7", dns.TypeA},
+		{"ipv6", "2001:db8::7", dns.TypeAAAA},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server, updates := updateServer(t, testSecret)
+			updater := &RFC2136Updater{
+				Server:        server,
+				Zone:          "example.com",
+				TSIGKeyName:   "fwg-key",
+				TSIGSecret:    testSecret,
+				TSIGAlgorithm: "hmac-sha256",
+				TTL:           60,
+			}
+			if err := updater.Update("vpn.example.com", net.ParseIP(test.ip)); err != nil {
+				t.Fatalf("Update() error = %v", err)
+			}
+
+			received := updates()
+			if len(received) != 1 {
+				t.Fatalf("the server received %d updates, want 1", len(received))
+			}
+			m := received[0]
+			// The zone section names the zone
+			if m.Opcode != dns.OpcodeUpdate || len(m.Question) != 1 ||
+				m.Question[0].Name != "example.com." || m.Question[0].Qtype != dns.TypeSOA {
+				t.Fatalf("unexpected zone section %v (opcode %d)", m.Question, m.Opcode)
+			}
+			// The update section deletes the RRset, then adds the record
+			if len(m.Ns) != 2 {
+				t.Fatalf("the update section has %d records, want 2: %v", len(m.Ns), m.Ns)
+			}
+			remove := m.Ns[0].Header()
+			if remove.Name != "vpn.example.com." || remove.Rrtype != test.rrtype || remove.Class != dns.ClassANY || remove.Ttl != 0 {
+				t.Errorf("first update %v, want the deletion of the %s RRset", m.Ns[0], dns.TypeToString[test.rrtype])
+			}
+			add := m.Ns[1]
+			if add.Header().Name != "vpn.example.com." || add.Header().Class != dns.ClassINET || add.Header().Ttl != 60 {
+				t.Errorf("second update %v, want a record of vpn.example.com. with the TTL 60", add)
+			}
+			var ip net.IP
+			switch rr := add.(type) {
+			case *dns.A:
+				ip = rr.A
+			case *dns.AAAA:
+				ip = rr.AAAA
+			}
+			if !ip.Equal(net.ParseIP(test.ip)) {
+				t.Errorf("second update %v, want the address %s", add, test.ip)
+			}
+		})
+	}
+}
+
+func TestRFC2136UpdaterTSIGFailure(t *testing.T) {
+	server, updates := updateServer(t, "b3RoZXJvdGhlcm90aGVyb3RoZXJvdGhlcm90aGVyMTI=")
+	updater := &RFC2136Updater{
+		Server:        server,
+		Zone:          "example.com",
+		TSIGKeyName:   "fwg-key",
+		TSIGSecret:    testSecret,
+		TSIGAlgorithm: "hmac-sha256",
+		TTL:           60,
+	}
+	err := updater.Update("vpn.example.com", net.ParseIP("203.0.113.7"))
+	if err == nil || !strings.Contains(err.Error(), "NOTAUTH") {
+		t.Errorf("Update() error = %v, want a NOTAUTH refusal", err)
+	}
+	if len(updates()) != 0 {
+		t.Errorf("the server accepted an update signed with another secret")
+	}
+}
+
+func TestRFC2136UpdaterOutOfZone(t *testing.T) {
+	updater := &RFC2136Updater{Server: "127.0.0.1:1", Zone: "example.com"}
+	if err := updater.Update("vpn.example.org", net.ParseIP("203.0.113.7")); err == nil {
+		t.Error("Update() accepted a name outside of the zone")
+	}
+}
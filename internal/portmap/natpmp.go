@@ -0,0 +1,90 @@
+package portmap
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+)
+
+const (
+	natpmpPort       = 5351
+	natpmpVersion    = 0
+	natpmpOpMapUDP   = 1
+	natpmpResultBase = 128
+)
+
+var natpmpResults = map[uint16]string{
+	1: "unsupported version",
+	2: "not authorized or refused",
+	3: "network failure",
+	4: "out of resources",
+	5: "unsupported opcode",
+}
+
+/*
+NATPMPMapper maps a UDP port with NAT-PMP (RFC 6886), sent to port 5351 of the gateway.
+*/
+type NATPMPMapper struct {
+	Gateway net.IP
+}
+
+func (m *NATPMPMapper) Name() string {
+	return "NAT-PMP"
+}
+
+func (m *NATPMPMapper) Map(internalPort int, externalPort int, lifetime time.Duration) (*Mapping, error) {
+	if m.Gateway == nil || m.Gateway.To4() == nil {
+		return nil, fmt.Errorf("no IPv4 gateway")
+	}
+
+	addr := net.JoinHostPort(m.Gateway.String(), strconv.Itoa(natpmpPort))
+	response, err := exchangeUDP(addr, natpmpMapRequest(internalPort, externalPort, lifetime), func(response []byte) bool {
+		return isNATPMPMapResponse(response, internalPort)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return parseNATPMPMapResponse(response, internalPort)
+}
+
+// natpmpMapRequest encodes the request mapping the UDP port, a lifetime of 0 deletes the mapping.
+func natpmpMapRequest(internalPort int, externalPort int, lifetime time.Duration) []byte {
+	// Request: version, opcode, reserved, internal port, suggested external port and lifetime
+	request := make([]byte, 12)
+	request[0] = natpmpVersion
+	request[1] = natpmpOpMapUDP
+	binary.BigEndian.PutUint16(request[4:6], uint16(internalPort))
+	binary.BigEndian.PutUint16(request[6:8], uint16(externalPort))
+	binary.BigEndian.PutUint32(request[8:12], uint32(lifetime/time.Second))
+	return request
+}
+
+// isNATPMPMapResponse reports whether the response answers the mapping request of the internal port.
+func isNATPMPMapResponse(response []byte, internalPort int) bool {
+	return len(response) >= 16 && response[0] == natpmpVersion && response[1] == natpmpResultBase+natpmpOpMapUDP &&
+		int(binary.BigEndian.Uint16(response[8:10])) == internalPort
+}
+
+// parseNATPMPMapResponse decodes the mapping granted by a response, or the refusal of the gateway.
+func parseNATPMPMapResponse(response []byte, internalPort int) (*Mapping, error) {
+	// Response: version, opcode, result code, epoch, internal port, external port and lifetime
+	if result := binary.BigEndian.Uint16(response[2:4]); result != 0 {
+		return nil, fmt.Errorf("gateway refused the mapping: %s", natpmpResultText(result))
+	}
+	return &Mapping{
+		Method:       "NAT-PMP",
+		ExternalPort: int(binary.BigEndian.Uint16(response[10:12])),
+		InternalPort: internalPort,
+		Lifetime:     time.Duration(binary.BigEndian.Uint32(response[12:16])) * time.Second,
+	}, nil
+}
+
+// natpmpResultText describes a result code of NAT-PMP.
+func natpmpResultText(result uint16) string {
+	if text, ok := natpmpResults[result]; ok {
+		return text
+	}
+	return fmt.Sprintf("result code %d", result)
+}
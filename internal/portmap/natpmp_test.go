@@ -0,0 +1,119 @@
+package portmap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNATPMPMapRequest(t *testing.T) {
+	tests := []struct {
+		name     string
+		internal int
+		external int
+		lifetime time.Duration
+		want     []byte
+	}{
+		{
+			name:     "map",
+			internal: 51820,
+			external: 51820,
+			lifetime: 2 * time.Hour,
+			want:     []byte{0, 1, 0, 0, 0xca, 0x6c, 0xca, 0x6c, 0, 0, 0x1c, 0x20},
+		},
+		{
+			name:     "delete",
+			internal: 51820,
+			external: 0,
+			lifetime: 0,
+			want:     []byte{0, 1, 0, 0, 0xca, 0x6c, 0, 0, 0, 0, 0, 0},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := natpmpMapRequest(test.internal, test.external, test.lifetime); !bytes.Equal(got, test.want) {
+				t.Errorf("natpmpMapRequest() = % x, want % x", got, test.want)
+			}
+		})
+	}
+}
+
+// natpmpMapResponse builds a NAT-PMP mapping response.
+func natpmpMapResponse(opcode byte, result uint16, internal int, external int, lifetime uint32) []byte {
+	response := make([]byte, 16)
+	response[1] = opcode
+	binary.BigEndian.PutUint16(response[2:4], result)
+	binary.BigEndian.PutUint32(response[4:8], 1234)
+	binary.BigEndian.PutUint16(response[8:10], uint16(internal))
+	binary.BigEndian.PutUint16(response[10:12], uint16(external))
+	binary.BigEndian.PutUint32(response[12:16], lifetime)
+	return response
+}
+
+func TestParseNATPMPMapResponse(t *testing.T) {
+	tests := []struct {
+		name         string
+		response     []byte
+		wantValid    bool
+		wantExternal int
+		wantLifetime time.Duration
+		wantErr      string
+	}{
+		{
+			name:         "success",
+			response:     natpmpMapResponse(129, 0, 51820, 40000, 7200),
+			wantValid:    true,
+			wantExternal: 40000,
+			wantLifetime: 2 * time.Hour,
+		},
+		{
+			name:      "refused",
+			response:  natpmpMapResponse(129, 2, 51820, 0, 0),
+			wantValid: true,
+			wantErr:   "not authorized or refused",
+		},
+		{
+			name:      "unknown result code",
+			response:  natpmpMapResponse(129, 42, 51820, 0, 0),
+			wantValid: true,
+			wantErr:   "42",
+		},
+		{
+			name:     "other internal port",
+			response: natpmpMapResponse(129, 0, 51821, 40000, 7200),
+		},
+		{
+			name:     "TCP mapping response",
+			response: natpmpMapResponse(130, 0, 51820, 40000, 7200),
+		},
+		{
+			name:     "truncated",
+			response: natpmpMapResponse(129, 0, 51820, 40000, 7200)[:12],
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if valid := isNATPMPMapResponse(test.response, 51820); valid != test.wantValid {
+				t.Fatalf("isNATPMPMapResponse() = %t, want %t", valid, test.wantValid)
+			}
+			if !test.wantValid {
+				return
+			}
+			mapping, err := parseNATPMPMapResponse(test.response, 51820)
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Errorf("parseNATPMPMapResponse() error = %v, want %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseNATPMPMapResponse() error = %v", err)
+			}
+			if mapping.ExternalPort != test.wantExternal || mapping.InternalPort != 51820 || mapping.Lifetime != test.wantLifetime {
+				t.Errorf("parseNATPMPMapResponse() = %+v, want the external port %d for %s", mapping, test.wantExternal, test.wantLifetime)
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package portmap
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+)
+
+const (
+	pcpVersion    = 2
+	pcpOpMap      = 1
+	pcpResponse   = 0x80
+	pcpHeaderSize = 24
+	pcpMapSize    = 36
+	protocolUDP   = 17
+)
+
+var pcpResults = map[byte]string{
+	1:  "unsupported version",
+	2:  "not authorized",
+	3:  "malformed request",
+	4:  "unsupported opcode",
+	5:  "unsupported option",
+	6:  "malformed option",
+	7:  "network failure",
+	8:  "no resources",
+	9:  "unsupported protocol",
+	10: "user exceeded quota",
+	11: "cannot provide external",
+	12: "address mismatch",
+	13: "excessive remote peers",
+}
+
+/*
+PCPMapper maps a UDP port with the Port Control Protocol (RFC 6887), the successor of NAT-PMP on the same port.
+
+LocalIP is the address of this host as seen by the gateway, a NAT between them makes the request fail.
+*/
+type PCPMapper struct {
+	Gateway net.IP
+	LocalIP net.IP
+
+	// The nonce identifies the mapping, so renewals must reuse it
+	nonce []byte
+}
+
+func (m *PCPMapper) Name() string {
+	return "PCP"
+}
+
+func (m *PCPMapper) Map(internalPort int, externalPort int, lifetime time.Duration) (*Mapping, error) {
+	if m.Gateway == nil || m.Gateway.To4() == nil {
+		return nil, fmt.Errorf("no IPv4 gateway")
+	}
+	if m.LocalIP == nil {
+		return nil, fmt.Errorf("unknown local address")
+	}
+	if m.nonce == nil {
+		m.nonce = make([]byte, 12)
+		if _, err := rand.Read(m.nonce); err != nil {
+			return nil, err
+		}
+	}
+
+	addr := net.JoinHostPort(m.Gateway.String(), strconv.Itoa(natpmpPort))
+	request := pcpMapRequest(m.LocalIP, m.nonce, internalPort, externalPort, lifetime)
+	response, err := exchangeUDP(addr, request, func(response []byte) bool {
+		return isPCPMapResponse(response, m.nonce)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return parsePCPMapResponse(response, internalPort)
+}
+
+// pcpMapRequest encodes the MAP request of the UDP port identified by the nonce, a lifetime of 0 deletes the mapping.
+func pcpMapRequest(localIP net.IP, nonce []byte, internalPort int, externalPort int, lifetime time.Duration) []byte {
+	// Header: version, opcode, reserved, lifetime and client address (IPv4-mapped)
+	request := make([]byte, pcpHeaderSize+pcpMapSize)
+	request[0] = pcpVersion
+	request[1] = pcpOpMap
+	binary.BigEndian.PutUint32(request[4:8], uint32(lifetime/time.Second))
+	copy(request[8:24], localIP.To16())
+	// MAP: nonce, protocol, reserved, internal port, suggested external port and address (any)
+	body := request[pcpHeaderSize:]
+	copy(body[0:12], nonce)
+	body[12] = protocolUDP
+	binary.BigEndian.PutUint16(body[16:18], uint16(internalPort))
+	binary.BigEndian.PutUint16(body[18:20], uint16(externalPort))
+	copy(body[20:36], net.IPv4zero.To16())
+	return request
+}
+
+// isPCPMapResponse reports whether the response answers the MAP request with the nonce, or comes from a
+// NAT-PMP only gateway, which answers with its version 0 and "unsupported version".
+func isPCPMapResponse(response []byte, nonce []byte) bool {
+	if len(response) >= 4 && response[0] == natpmpVersion {
+		return true
+	}
+	return len(response) >= pcpHeaderSize+pcpMapSize && response[0] == pcpVersion &&
+		response[1] == pcpResponse|pcpOpMap && bytes.Equal(response[pcpHeaderSize:pcpHeaderSize+12], nonce)
+}
+
+// parsePCPMapResponse decodes the mapping granted by a MAP response, or the refusal of the gateway.
+func parsePCPMapResponse(response []byte, internalPort int) (*Mapping, error) {
+	if response[0] == natpmpVersion {
+		return nil, fmt.Errorf("gateway only supports NAT-PMP")
+	}
+
+	// Response: version, opcode, reserved, result code, lifetime, epoch, reserved, then the MAP data
+	if result := response[3]; result != 0 {
+		return nil, fmt.Errorf("gateway refused the mapping: %s", pcpResultText(result))
+	}
+	body := response[pcpHeaderSize:]
+	externalIP := net.IP(append([]byte(nil), body[20:36]...))
+	if ip4 := externalIP.To4(); ip4 != nil {
+		externalIP = ip4
+	}
+	return &Mapping{
+		Method:       "PCP",
+		ExternalIP:   externalIP,
+		ExternalPort: int(binary.BigEndian.Uint16(body[18:20])),
+		InternalPort: internalPort,
+		Lifetime:     time.Duration(binary.BigEndian.Uint32(response[4:8])) * time.Second,
+	}, nil
+}
+
+// pcpResultText describes a result code of PCP.
+func pcpResultText(result byte) string {
+	if text, ok := pcpResults[result]; ok {
+		return text
+	}
+	return fmt.Sprintf("result code %d", result)
+}
@@ -0,0 +1,127 @@
+package portmap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testNonce = []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12}
+
+func TestPCPMapRequest(t *testing.T) {
+	request := pcpMapRequest(net.ParseIP("192.168.1.10"), testNonce, 51820, 51821, 2*time.Hour)
+	if len(request) != pcpHeaderSize+pcpMapSize {
+		t.Fatalf("pcpMapRequest() is %d bytes long, want %d", len(request), pcpHeaderSize+pcpMapSize)
+	}
+
+	tests := []struct {
+		name  string
+		field []byte
+		want  []byte
+	}{
+		{"version and opcode", request[0:2], []byte{2, 1}},
+		{"lifetime", request[4:8], []byte{0, 0, 0x1c, 0x20}},
+		{"client address", request[8:24], net.ParseIP("192.168.1.10").To16()},
+		{"nonce", request[24:36], testNonce},
+		{"protocol", request[36:37], []byte{17}},
+		{"internal port", request[40:42], []byte{0xca, 0x6c}},
+		{"suggested external port", request[42:44], []byte{0xca, 0x6d}},
+		{"suggested external address", request[44:60], net.IPv4zero.To16()},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if !bytes.Equal(test.field, test.want) {
+				t.Errorf("%s = % x, want % x", test.name, test.field, test.want)
+			}
+		})
+	}
+}
+
+// pcpMapResponse builds a PCP MAP response.
+func pcpMapResponse(result byte, nonce []byte, external int, externalIP string, lifetime uint32) []byte {
+	response := make([]byte, pcpHeaderSize+pcpMapSize)
+	response[0] = pcpVersion
+	response[1] = pcpResponse | pcpOpMap
+	response[3] = result
+	binary.BigEndian.PutUint32(response[4:8], lifetime)
+	body := response[pcpHeaderSize:]
+	copy(body[0:12], nonce)
+	body[12] = protocolUDP
+	binary.BigEndian.PutUint16(body[16:18], 51820)
+	binary.BigEndian.PutUint16(body[18:20], uint16(external))
+	copy(body[20:36], net.ParseIP(externalIP).To16())
+	return response
+}
+
+func TestParsePCPMapResponse(t *testing.T) {
+	otherNonce := bytes.Repeat([]byte{0xff}, 12)
+	tests := []struct {
+		name         string
+		response     []byte
+		wantValid    bool
+		wantExternal int
+		wantIP       string
+		wantErr      string
+	}{
+		{
+			name:         "success",
+			response:     pcpMapResponse(0, testNonce, 40000, "203.0.113.7", 7200),
+			wantValid:    true,
+			wantExternal: 40000,
+			wantIP:       "203.0.113.7",
+		},
+		{
+			name:      "not authorized",
+			response:  pcpMapResponse(2, testNonce, 0, "::", 0),
+			wantValid: true,
+			wantErr:   "not authorized",
+		},
+		{
+			name:      "no resources",
+			response:  pcpMapResponse(8, testNonce, 0, "::", 0),
+			wantValid: true,
+			wantErr:   "no resources",
+		},
+		{
+			name:     "other nonce",
+			response: pcpMapResponse(0, otherNonce, 40000, "203.0.113.7", 7200),
+		},
+		{
+			name:     "request echoed",
+			response: pcpMapRequest(net.ParseIP("192.168.1.10"), testNonce, 51820, 51820, time.Hour),
+		},
+		{
+			name:      "NAT-PMP only gateway",
+			response:  []byte{0, 129, 0, 1, 0, 0, 0, 0},
+			wantValid: true,
+			wantErr:   "only supports NAT-PMP",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if valid := isPCPMapResponse(test.response, testNonce); valid != test.wantValid {
+				t.Fatalf("isPCPMapResponse() = %t, want %t", valid, test.wantValid)
+			}
+			if !test.wantValid {
+				return
+			}
+			mapping, err := parsePCPMapResponse(test.response, 51820)
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Errorf("parsePCPMapResponse() error = %v, want %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parsePCPMapResponse() error = %v", err)
+			}
+			if mapping.ExternalPort != test.wantExternal || !mapping.ExternalIP.Equal(net.ParseIP(test.wantIP)) ||
+				len(mapping.ExternalIP) != net.IPv4len || mapping.Lifetime != 2*time.Hour {
+				t.Errorf("parsePCPMapResponse() = %+v, want %s:%d for 2h", mapping, test.wantIP, test.wantExternal)
+			}
+		})
+	}
+}
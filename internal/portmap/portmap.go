@@ -0,0 +1,90 @@
+package portmap
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+/*
+Mapping is a UDP port of the gateway forwarded to a port of this host.
+*/
+type Mapping struct {
+	Method       string
+	ExternalIP   net.IP
+	ExternalPort int
+	InternalPort int
+	Lifetime     time.Duration
+}
+
+/*
+Mapper asks a gateway to forward one of its UDP ports to this host.
+*/
+type Mapper interface {
+	Name() string
+	// Map creates or renews the mapping, a lifetime of 0 deletes it.
+	Map(internalPort int, externalPort int, lifetime time.Duration) (*Mapping, error)
+}
+
+/*
+Mappers returns the mappers of every supported protocol for the gateway, in the order they are tried.
+*/
+func Mappers(gateway net.IP, localIP net.IP) []Mapper {
+	return []Mapper{
+		&PCPMapper{Gateway: gateway, LocalIP: localIP},
+		&NATPMPMapper{Gateway: gateway},
+		&UPnPMapper{LocalIP: localIP},
+	}
+}
+
+/*
+MapPort tries every mapper until one of them forwards the port.
+
+Returns the mapping and the mapper to renew it with.
+*/
+func MapPort(mappers []Mapper, port int, lifetime time.Duration) (*Mapping, Mapper, error) {
+	var errs []error
+	for _, mapper := range mappers {
+		mapping, err := mapper.Map(port, port, lifetime)
+		if err == nil {
+			return mapping, mapper, nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", mapper.Name(), err))
+	}
+	return nil, nil, errors.Join(errs...)
+}
+
+// exchangeUDP sends the request to the address and waits for a response, retrying with a doubling timeout.
+func exchangeUDP(addr string, request []byte, valid func([]byte) bool) ([]byte, error) {
+	conn, err := net.Dial("udp4", addr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	response := make([]byte, 1100)
+	timeout := 250 * time.Millisecond
+	for range 4 {
+		if _, err := conn.Write(request); err != nil {
+			return nil, err
+		}
+		conn.SetReadDeadline(time.Now().Add(timeout))
+		for {
+			n, err := conn.Read(response)
+			if err != nil {
+				// An ICMP error (e.g. port unreachable) means there is no server at all
+				var netErr net.Error
+				if errors.As(err, &netErr) && netErr.Timeout() {
+					break
+				}
+				return nil, err
+			}
+			if valid(response[:n]) {
+				return response[:n], nil
+			}
+		}
+		timeout *= 2
+	}
+	return nil, fmt.Errorf("no response from %s", addr)
+}
@@ -0,0 +1,277 @@
+package portmap
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	ssdpAddr = "239.255.255.250:1900"
+
+	// UPnP error returned by gateways which only accept permanent mappings
+	upnpOnlyPermanentLeases = "725"
+)
+
+var (
+	upnpSearchTargets = []string{
+		"urn:schemas-upnp-org:device:InternetGatewayDevice:2",
+		"urn:schemas-upnp-org:device:InternetGatewayDevice:1",
+	}
+	upnpServiceTypes = []string{
+		"urn:schemas-upnp-org:service:WANIPConnection:2",
+		"urn:schemas-upnp-org:service:WANIPConnection:1",
+		"urn:schemas-upnp-org:service:WANPPPConnection:1",
+	}
+	upnpClient = &http.Client{Timeout: 5 * time.Second}
+)
+
+/*
+UPnPMapper maps a UDP port with the WANIPConnection service of a UPnP Internet Gateway Device.
+
+The gateway is discovered with SSDP, LocalIP is the address the port is forwarded to.
+*/
+type UPnPMapper struct {
+	LocalIP net.IP
+
+	// The control URL and service type found by the discovery, kept for renewals
+	controlURL  string
+	serviceType string
+}
+
+type upnpDevice struct {
+	Services []upnpService `xml:"serviceList>service"`
+	Devices  []upnpDevice  `xml:"deviceList>device"`
+}
+
+type upnpService struct {
+	ServiceType string `xml:"serviceType"`
+	ControlURL  string `xml:"controlURL"`
+}
+
+type upnpDescription struct {
+	URLBase string     `xml:"URLBase"`
+	Device  upnpDevice `xml:"device"`
+}
+
+type upnpFault struct {
+	ErrorCode        string `xml:"Body>Fault>detail>UPnPError>errorCode"`
+	ErrorDescription string `xml:"Body>Fault>detail>UPnPError>errorDescription"`
+}
+
+func (m *UPnPMapper) Name() string {
+	return "UPnP IGD"
+}
+
+func (m *UPnPMapper) Map(internalPort int, externalPort int, lifetime time.Duration) (*Mapping, error) {
+	if m.LocalIP == nil {
+		return nil, fmt.Errorf("unknown local address")
+	}
+	if m.controlURL == "" {
+		if err := m.discover(); err != nil {
+			return nil, err
+		}
+	}
+
+	if lifetime == 0 {
+		_, err := m.call("DeletePortMapping", [][2]string{
+			{"NewRemoteHost", ""},
+			{"NewExternalPort", fmt.Sprint(externalPort)},
+			{"NewProtocol", "UDP"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		return &Mapping{Method: m.Name(), ExternalPort: externalPort, InternalPort: internalPort}, nil
+	}
+
+	args := [][2]string{
+		{"NewRemoteHost", ""},
+		{"NewExternalPort", fmt.Sprint(externalPort)},
+		{"NewProtocol", "UDP"},
+		{"NewInternalPort", fmt.Sprint(internalPort)},
+		{"NewInternalClient", m.LocalIP.String()},
+		{"NewEnabled", "1"},
+		{"NewPortMappingDescription", "fwg"},
+		{"NewLeaseDuration", fmt.Sprint(int(lifetime / time.Second))},
+	}
+	if _, err := m.call("AddPortMapping", args); err != nil {
+		// Retry with a permanent mapping, which is simply added again on renewals
+		if !strings.HasPrefix(err.Error(), upnpOnlyPermanentLeases+" ") {
+			return nil, err
+		}
+		args[len(args)-1][1] = "0"
+		if _, err := m.call("AddPortMapping", args); err != nil {
+			return nil, err
+		}
+	}
+
+	mapping := &Mapping{
+		Method:       m.Name(),
+		ExternalPort: externalPort,
+		InternalPort: internalPort,
+		Lifetime:     lifetime,
+	}
+	if response, err := m.call("GetExternalIPAddress", nil); err == nil {
+		mapping.ExternalIP = net.ParseIP(upnpValue(response, "NewExternalIPAddress"))
+	}
+	return mapping, nil
+}
+
+// discover finds the WAN connection service of the gateway with a SSDP M-SEARCH.
+func (m *UPnPMapper) discover() error {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: m.LocalIP})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	multicast, err := net.ResolveUDPAddr("udp4", ssdpAddr)
+	if err != nil {
+		return err
+	}
+
+	for _, target := range upnpSearchTargets {
+		request := "M-SEARCH * HTTP/1.1\r\n" +
+			"HOST: " + ssdpAddr + "\r\n" +
+			"MAN: \"ssdp:discover\"\r\n" +
+			"MX: 2\r\n" +
+			"ST: " + target + "\r\n\r\n"
+		if _, err := conn.WriteTo([]byte(request), multicast); err != nil {
+			return err
+		}
+	}
+
+	buffer := make([]byte, 2048)
+	conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	for {
+		n, _, err := conn.ReadFrom(buffer)
+		if err != nil {
+			return fmt.Errorf("no Internet Gateway Device found")
+		}
+		location := ssdpHeader(string(buffer[:n]), "LOCATION")
+		if location == "" {
+			continue
+		}
+		if err := m.readDescription(location); err == nil {
+			return nil
+		}
+	}
+}
+
+// readDescription fetches the device description and keeps the control URL of the WAN connection service.
+func (m *UPnPMapper) readDescription(location string) error {
+	response, err := upnpClient.Get(location)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	var description upnpDescription
+	if err := xml.NewDecoder(response.Body).Decode(&description); err != nil {
+		return err
+	}
+	base, err := url.Parse(location)
+	if err != nil {
+		return err
+	}
+	if description.URLBase != "" {
+		if urlBase, err := url.Parse(description.URLBase); err == nil {
+			base = urlBase
+		}
+	}
+
+	for _, serviceType := range upnpServiceTypes {
+		if service := findUPnPService(description.Device, serviceType); service != nil {
+			controlURL, err := base.Parse(service.ControlURL)
+			if err != nil {
+				return err
+			}
+			m.controlURL, m.serviceType = controlURL.String(), serviceType
+			return nil
+		}
+	}
+	return fmt.Errorf("%s has no WAN connection service", location)
+}
+
+// findUPnPService searches the device and its embedded devices for the service type.
+func findUPnPService(device upnpDevice, serviceType string) *upnpService {
+	for i := range device.Services {
+		if device.Services[i].ServiceType == serviceType {
+			return &device.Services[i]
+		}
+	}
+	for _, embedded := range device.Devices {
+		if service := findUPnPService(embedded, serviceType); service != nil {
+			return service
+		}
+	}
+	return nil
+}
+
+// call invokes an action of the WAN connection service with SOAP and returns the response body.
+//
+// UPnP errors are returned as "<code> <description>".
+func (m *UPnPMapper) call(action string, args [][2]string) (string, error) {
+	var body strings.Builder
+	body.WriteString(`<?xml version="1.0"?>` +
+		`<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/">` +
+		`<s:Body><u:` + action + ` xmlns:u="` + m.serviceType + `">`)
+	for _, arg := range args {
+		fmt.Fprintf(&body, "<%s>%s</%s>", arg[0], html.EscapeString(arg[1]), arg[0])
+	}
+	body.WriteString(`</u:` + action + `></s:Body></s:Envelope>`)
+
+	request, err := http.NewRequest(http.MethodPost, m.controlURL, strings.NewReader(body.String()))
+	if err != nil {
+		return "", err
+	}
+	request.Header.Set("Content-Type", `text/xml; charset="utf-8"`)
+	request.Header.Set("SOAPAction", fmt.Sprintf(`"%s#%s"`, m.serviceType, action))
+
+	response, err := upnpClient.Do(request)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+	content, err := io.ReadAll(io.LimitReader(response.Body, 64*1024))
+	if err != nil {
+		return "", err
+	}
+
+	if response.StatusCode != http.StatusOK {
+		var fault upnpFault
+		if xml.NewDecoder(bytes.NewReader(content)).Decode(&fault) == nil && fault.ErrorCode != "" {
+			return "", fmt.Errorf("%s %s", fault.ErrorCode, fault.ErrorDescription)
+		}
+		return "", fmt.Errorf("%s failed with HTTP %d", action, response.StatusCode)
+	}
+	return string(content), nil
+}
+
+// ssdpHeader returns the value of a header of a SSDP response.
+func ssdpHeader(response string, name string) string {
+	for _, line := range strings.Split(response, "\r\n") {
+		key, value, found := strings.Cut(line, ":")
+		if found && strings.EqualFold(strings.TrimSpace(key), name) {
+			return strings.TrimSpace(value)
+		}
+	}
+	return ""
+}
+
+// upnpValue extracts the value of an output argument of a SOAP response.
+func upnpValue(response string, name string) string {
+	_, value, found := strings.Cut(response, "<"+name+">")
+	if !found {
+		return ""
+	}
+	value, _, _ = strings.Cut(value, "</"+name+">")
+	return strings.TrimSpace(value)
+}
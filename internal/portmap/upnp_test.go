@@ -0,0 +1,189 @@
+package portmap
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSSDPHeader(t *testing.T) {
+	response := "HTTP/1.1 200 OK\r\n" +
+		"CACHE-CONTROL: max-age=120\r\n" +
+		"ST: urn:schemas-upnp-org:device:InternetGatewayDevice:1\r\n" +
+		"Location:  http://192.168.1.1:5000/rootDesc.xml \r\n" +
+		"SERVER: OpenWRT/OpenWrt UPnP/1.1 MiniUPnPd/2.2.1\r\n\r\n"
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"LOCATION", "http://192.168.1.1:5000/rootDesc.xml"},
+		{"st", "urn:schemas-upnp-org:device:InternetGatewayDevice:1"},
+		{"USN", ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := ssdpHeader(response, test.name); got != test.want {
+				t.Errorf("ssdpHeader(%q) = %q, want %q", test.name, got, test.want)
+			}
+		})
+	}
+}
+
+func TestUPnPValue(t *testing.T) {
+	response := `<?xml version="1.0"?><s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/"><s:Body>` +
+		`<u:GetExternalIPAddressResponse xmlns:u="urn:schemas-upnp-org:service:WANIPConnection:1">` +
+		`<NewExternalIPAddress> 203.0.113.7 </NewExternalIPAddress>` +
+		`</u:GetExternalIPAddressResponse></s:Body></s:Envelope>`
+	if got := upnpValue(response, "NewExternalIPAddress"); got != "203.0.113.7" {
+		t.Errorf("upnpValue() = %q, want 203.0.113.7", got)
+	}
+	if got := upnpValue(response, "NewLeaseDuration"); got != "" {
+		t.Errorf("upnpValue() = %q for a missing argument", got)
+	}
+}
+
+const testDescription = `<?xml version="1.0"?>
+<root xmlns="urn:schemas-upnp-org:device-1-0">
+<device>
+<deviceType>urn:schemas-upnp-org:device:InternetGatewayDevice:1</deviceType>
+<serviceList><service><serviceType>urn:schemas-upnp-org:service:Layer3Forwarding:1</serviceType><controlURL>/ctl/L3F</controlURL></service></serviceList>
+<deviceList><device>
+<deviceType>urn:schemas-upnp-org:device:WANDevice:1</deviceType>
+<deviceList><device>
+<deviceType>urn:schemas-upnp-org:device:WANConnectionDevice:1</deviceType>
+<serviceList><service><serviceType>urn:schemas-upnp-org:service:WANIPConnection:1</serviceType><controlURL>/ctl/IPConn</controlURL></service></serviceList>
+</device></deviceList>
+</device></deviceList>
+</device>
+</root>`
+
+// soapFault is the body of the UPnP error of the code.
+func soapFault(code string, description string) string {
+	return `<?xml version="1.0"?><s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/"><s:Body><s:Fault>` +
+		`<faultcode>s:Client</faultcode><faultstring>UPnPError</faultstring><detail>` +
+		`<UPnPError xmlns="urn:schemas-upnp-org:control-1-0"><errorCode>` + code + `</errorCode>` +
+		`<errorDescription>` + description + `</errorDescription></UPnPError>` +
+		`</detail></s:Fault></s:Body></s:Envelope>`
+}
+
+// igdServer serves the description and the control URL of a gateway, which refuses the leases which are not
+// permanent if onlyPermanent is set, and records the actions with their body.
+func igdServer(t *testing.T, onlyPermanent bool) (*httptest.Server, func() []string) {
+	t.Helper()
+	var mu sync.Mutex
+	var calls []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/rootDesc.xml", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, testDescription)
+	})
+	mux.HandleFunc("/ctl/IPConn", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		calls = append(calls, r.Header.Get("SOAPAction")+" "+string(body))
+		mu.Unlock()
+
+		switch {
+		case strings.HasSuffix(r.Header.Get("SOAPAction"), `#GetExternalIPAddress"`):
+			io.WriteString(w, `<?xml version="1.0"?><s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/"><s:Body>`+
+				`<u:GetExternalIPAddressResponse xmlns:u="urn:schemas-upnp-org:service:WANIPConnection:1">`+
+				`<NewExternalIPAddress>203.0.113.7</NewExternalIPAddress>`+
+				`</u:GetExternalIPAddressResponse></s:Body></s:Envelope>`)
+		case onlyPermanent && !strings.Contains(string(body), "<NewLeaseDuration>0</NewLeaseDuration>"):
+			w.WriteHeader(http.StatusInternalServerError)
+			io.WriteString(w, soapFault("725", "OnlyPermanentLeasesSupported"))
+		default:
+			io.WriteString(w, `<?xml version="1.0"?><s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/"><s:Body>`+
+				`<u:AddPortMappingResponse xmlns:u="urn:schemas-upnp-org:service:WANIPConnection:1"/></s:Body></s:Envelope>`)
+		}
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	return server, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return calls
+	}
+}
+
+func TestUPnPMapperReadDescription(t *testing.T) {
+	server, _ := igdServer(t, false)
+	mapper := &UPnPMapper{LocalIP: net.ParseIP("192.168.1.10")}
+	if err := mapper.readDescription(server.URL + "/rootDesc.xml"); err != nil {
+		t.Fatalf("readDescription() error = %v", err)
+	}
+	if mapper.controlURL != server.URL+"/ctl/IPConn" || mapper.serviceType != "urn:schemas-upnp-org:service:WANIPConnection:1" {
+		t.Errorf("readDescription() found %s at %s, want the embedded WANIPConnection:1 service", mapper.serviceType, mapper.controlURL)
+	}
+}
+
+func TestUPnPMapperMap(t *testing.T) {
+	tests := []struct {
+		name          string
+		onlyPermanent bool
+		wantLeases    []string
+	}{
+		{"lease", false, []string{"7200"}},
+		{"only permanent leases", true, []string{"7200", "0"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server, calls := igdServer(t, test.onlyPermanent)
+			mapper := &UPnPMapper{LocalIP: net.ParseIP("192.168.1.10")}
+			if err := mapper.readDescription(server.URL + "/rootDesc.xml"); err != nil {
+				t.Fatal(err)
+			}
+
+			mapping, err := mapper.Map(51820, 51820, 2*time.Hour)
+			if err != nil {
+				t.Fatalf("Map() error = %v", err)
+			}
+			if mapping.ExternalPort != 51820 || !mapping.ExternalIP.Equal(net.ParseIP("203.0.113.7")) {
+				t.Errorf("Map() = %+v, want 203.0.113.7:51820", mapping)
+			}
+
+			var leases []string
+			for _, call := range calls() {
+				if !strings.Contains(call, "#AddPortMapping") {
+					continue
+				}
+				for _, arg := range []string{
+					"<NewExternalPort>51820</NewExternalPort>",
+					"<NewProtocol>UDP</NewProtocol>",
+					"<NewInternalPort>51820</NewInternalPort>",
+					"<NewInternalClient>192.168.1.10</NewInternalClient>",
+				} {
+					if !strings.Contains(call, arg) {
+						t.Errorf("AddPortMapping misses %s: %s", arg, call)
+					}
+				}
+				leases = append(leases, upnpValue(call, "NewLeaseDuration"))
+			}
+			if strings.Join(leases, ",") != strings.Join(test.wantLeases, ",") {
+				t.Errorf("AddPortMapping called with the leases %v, want %v", leases, test.wantLeases)
+			}
+		})
+	}
+}
+
+func TestUPnPMapperFault(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		io.WriteString(w, soapFault("718", "ConflictInMappingEntry"))
+	}))
+	t.Cleanup(server.Close)
+
+	mapper := &UPnPMapper{
+		LocalIP:     net.ParseIP("192.168.1.10"),
+		controlURL:  server.URL,
+		serviceType: "urn:schemas-upnp-org:service:WANIPConnection:1",
+	}
+	if _, err := mapper.Map(51820, 51820, 2*time.Hour); err == nil || err.Error() != "718 ConflictInMappingEntry" {
+		t.Errorf("Map() error = %v, want the UPnP error 718", err)
+	}
+}
@@ -0,0 +1,91 @@
+package resolver
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+/*
+Forwarder relays the DNS queries of the clients to the upstream resolvers.
+*/
+type Forwarder struct {
+	Upstreams []string
+	client    *dns.Client
+	tcpClient *dns.Client
+}
+
+/*
+NewForwarder creates a forwarder for the given upstream resolvers ("host" or "host:port").
+*/
+func NewForwarder(upstreams []string) *Forwarder {
+	f := &Forwarder{
+		client:    &dns.Client{Net: "udp", Timeout: 3 * time.Second},
+		tcpClient: &dns.Client{Net: "tcp", Timeout: 3 * time.Second},
+	}
+	for _, upstream := range upstreams {
+		if _, _, err := net.SplitHostPort(upstream); err != nil {
+			upstream = net.JoinHostPort(upstream, "53")
+		}
+		f.Upstreams = append(f.Upstreams, upstream)
+	}
+	return f
+}
+
+/*
+ServeDNS implements dns.Handler by trying the upstream resolvers in order.
+*/
+func (f *Forwarder) ServeDNS(w dns.ResponseWriter, r *dns.Msg) {
+	client := f.client
+	if _, ok := w.RemoteAddr().(*net.TCPAddr); ok {
+		client = f.tcpClient
+	}
+
+	for _, upstream := range f.Upstreams {
+		resp, _, err := client.Exchange(r, upstream)
+		if err != nil {
+			continue
+		}
+		// Retry over TCP if the answer does not fit into one UDP packet
+		if resp.Truncated && client != f.tcpClient {
+			if tcpResp, _, err := f.tcpClient.Exchange(r, upstream); err == nil {
+				resp = tcpResp
+			}
+		}
+		w.WriteMsg(resp)
+		return
+	}
+
+	// All the upstream resolvers failed
+	m := new(dns.Msg)
+	m.SetRcode(r, dns.RcodeServerFailure)
+	w.WriteMsg(m)
+}
+
+/*
+ListenAndServe serves the handler over UDP and TCP on port 53 of every given IP.
+
+It blocks until one of the servers fails.
+*/
+func ListenAndServe(ips []net.IP, handler dns.Handler) error {
+	if len(ips) == 0 {
+		return fmt.Errorf("no address to listen on")
+	}
+
+	errs := make(chan error)
+	for _, ip := range ips {
+		addr := net.JoinHostPort(ip.String(), "53")
+		for _, network := range []string{"udp", "tcp"} {
+			server := &dns.Server{Addr: addr, Net: network, Handler: handler}
+			server.NotifyStartedFunc = func() {
+				fmt.Printf("✅ DNS service listening on %s/%s\n", addr, network)
+			}
+			go func() {
+				errs <- fmt.Errorf("DNS server on %s/%s stopped: %w", addr, network, server.ListenAndServe())
+			}()
+		}
+	}
+	return <-errs
+}
@@ -0,0 +1,239 @@
+package resolver
+
+import (
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/miekg/dns"
+)
+
+/*
+Zone answers authoritatively the A, AAAA and PTR records of the hosts of one zone (e.g. "wg.internal").
+
+The reverse names of the Networks (the tunnel networks, set before serving) which are not hosts of the zone
+are answered with NXDOMAIN, so the internal addresses are not looked up upstream. The other queries outside
+of the zone are passed to the fallback handler.
+*/
+type Zone struct {
+	Origin   string
+	Fallback dns.Handler
+	Networks []*net.IPNet
+
+	mu      sync.RWMutex
+	hosts   map[string][]net.IP
+	reverse map[string]string
+}
+
+/*
+NewZone creates an empty zone for the given origin.
+*/
+func NewZone(origin string, fallback dns.Handler) *Zone {
+	return &Zone{
+		Origin:   dns.Fqdn(strings.ToLower(origin)),
+		Fallback: fallback,
+		hosts:    make(map[string][]net.IP),
+		reverse:  make(map[string]string),
+	}
+}
+
+/*
+Update replaces the hosts of the zone, indexed by their labels (e.g. "laptop-alice").
+*/
+func (z *Zone) Update(hosts map[string][]net.IP) {
+	newHosts := make(map[string][]net.IP)
+	newReverse := make(map[string]string)
+	for label, ips := range hosts {
+		name := strings.ToLower(label) + "." + z.Origin
+		newHosts[name] = ips
+		for _, ip := range ips {
+			if arpa, err := dns.ReverseAddr(ip.String()); err == nil {
+				newReverse[arpa] = name
+			}
+		}
+	}
+
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	z.hosts = newHosts
+	z.reverse = newReverse
+}
+
+/*
+ServeDNS implements dns.Handler.
+*/
+func (z *Zone) ServeDNS(w dns.ResponseWriter, r *dns.Msg) {
+	if len(r.Question) != 1 {
+		z.fallback(w, r)
+		return
+	}
+	q := r.Question[0]
+	name := strings.ToLower(q.Name)
+
+	// The lock is not held while the fallback handler waits for the upstream resolvers
+	z.mu.RLock()
+	target, isReverse := z.reverse[name]
+	ips, ok := z.hosts[name]
+	z.mu.RUnlock()
+
+	m := new(dns.Msg)
+	m.SetReply(r)
+	m.Authoritative = true
+
+	// Reverse lookups of the hosts of the zone
+	if isReverse {
+		if q.Qtype == dns.TypePTR || q.Qtype == dns.TypeANY {
+			m.Answer = append(m.Answer, &dns.PTR{
+				Hdr: dns.RR_Header{Name: q.Name, Rrtype: dns.TypePTR, Class: dns.ClassINET, Ttl: 60},
+				Ptr: target,
+			})
+		}
+		w.WriteMsg(m)
+		return
+	}
+
+	if network := z.reverseNetwork(name); network != nil {
+		m.Rcode = dns.RcodeNameError
+		m.Ns = append(m.Ns, z.soaOf(reverseZone(network)))
+		w.WriteMsg(m)
+		return
+	}
+
+	if !dns.IsSubDomain(z.Origin, name) {
+		z.fallback(w, r)
+		return
+	}
+
+	switch {
+	case name == z.Origin && q.Qtype == dns.TypeSOA:
+		m.Answer = append(m.Answer, z.soa())
+	case !ok && name != z.Origin:
+		m.Rcode = dns.RcodeNameError
+	default:
+		for _, ip := range ips {
+			if ipv4 := ip.To4(); ipv4 != nil && (q.Qtype == dns.TypeA || q.Qtype == dns.TypeANY) {
+				m.Answer = append(m.Answer, &dns.A{
+					Hdr: dns.RR_Header{Name: q.Name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 60},
+					A:   ipv4,
+				})
+			} else if ipv4 == nil && (q.Qtype == dns.TypeAAAA || q.Qtype == dns.TypeANY) {
+				m.Answer = append(m.Answer, &dns.AAAA{
+					Hdr:  dns.RR_Header{Name: q.Name, Rrtype: dns.TypeAAAA, Class: dns.ClassINET, Ttl: 60},
+					AAAA: ip,
+				})
+			}
+		}
+	}
+	if len(m.Answer) == 0 {
+		// NXDOMAIN or NODATA, the SOA tells the resolvers how long to cache it
+		m.Ns = append(m.Ns, z.soa())
+	}
+	w.WriteMsg(m)
+}
+
+// fallback passes the query to the fallback handler, or refuses it.
+func (z *Zone) fallback(w dns.ResponseWriter, r *dns.Msg) {
+	if z.Fallback != nil {
+		z.Fallback.ServeDNS(w, r)
+		return
+	}
+	m := new(dns.Msg)
+	m.SetRcode(r, dns.RcodeRefused)
+	w.WriteMsg(m)
+}
+
+// soa returns the SOA record of the zone.
+func (z *Zone) soa() dns.RR {
+	return z.soaOf(z.Origin)
+}
+
+// soaOf returns a SOA record of the name, served by the server of the zone.
+func (z *Zone) soaOf(name string) dns.RR {
+	return &dns.SOA{
+		Hdr:     dns.RR_Header{Name: name, Rrtype: dns.TypeSOA, Class: dns.ClassINET, Ttl: 60},
+		Ns:      "ns." + z.Origin,
+		Mbox:    "hostmaster." + z.Origin,
+		Serial:  1,
+		Refresh: 3600,
+		Retry:   600,
+		Expire:  86400,
+		Minttl:  60,
+	}
+}
+
+// reverseNetwork returns the network containing the addresses of the reverse name, or nil if the name is not
+// under the reverse zone of one of the networks.
+func (z *Zone) reverseNetwork(name string) *net.IPNet {
+	prefix, ok := parseReverseName(name)
+	if !ok {
+		return nil
+	}
+	prefixLength, _ := prefix.Mask.Size()
+	for _, network := range z.Networks {
+		ones, bits := network.Mask.Size()
+		if _, prefixBits := prefix.Mask.Size(); prefixBits == bits && prefixLength >= ones && network.Contains(prefix.IP) {
+			return network
+		}
+	}
+	return nil
+}
+
+/*
+parseReverseName converts a name under in-addr.arpa or ip6.arpa into the prefix of the addresses below it,
+e.g. "8.10.in-addr.arpa." into 10.8.0.0/16.
+*/
+func parseReverseName(name string) (*net.IPNet, bool) {
+	var labels []string
+	var ip net.IP
+	var width int
+	switch {
+	case dns.IsSubDomain("in-addr.arpa.", name):
+		labels = dns.SplitDomainName(strings.TrimSuffix(name, "in-addr.arpa."))
+		ip, width = make(net.IP, net.IPv4len), 8
+		if len(labels) > net.IPv4len {
+			return nil, false
+		}
+		for i, label := range labels {
+			value, err := strconv.ParseUint(label, 10, 8)
+			if err != nil {
+				return nil, false
+			}
+			ip[len(labels)-1-i] = byte(value)
+		}
+	case dns.IsSubDomain("ip6.arpa.", name):
+		labels = dns.SplitDomainName(strings.TrimSuffix(name, "ip6.arpa."))
+		ip, width = make(net.IP, net.IPv6len), 4
+		if len(labels) > 2*net.IPv6len {
+			return nil, false
+		}
+		for i, label := range labels {
+			value, err := strconv.ParseUint(label, 16, 4)
+			if err != nil || len(label) != 1 {
+				return nil, false
+			}
+			nibble := len(labels) - 1 - i
+			ip[nibble/2] |= byte(value) << (4 * (1 - nibble%2))
+		}
+	default:
+		return nil, false
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(len(labels)*width, len(ip)*8)}, true
+}
+
+// reverseZone returns the reverse name of the network, shortened to the labels fully inside of it.
+func reverseZone(network *net.IPNet) string {
+	ones, bits := network.Mask.Size()
+	arpa, err := dns.ReverseAddr(network.IP.String())
+	if err != nil {
+		return "arpa."
+	}
+	labels := dns.SplitDomainName(arpa)
+	width := 8
+	if bits == 8*net.IPv6len {
+		width = 4
+	}
+	// The address labels come first, followed by "in-addr.arpa" or "ip6.arpa"
+	keep := ones/width + 2
+	return dns.Fqdn(strings.Join(labels[len(labels)-keep:], "."))
+}
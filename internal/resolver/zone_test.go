@@ -0,0 +1,206 @@
+package resolver
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// recorder is a dns.ResponseWriter keeping the written message.
+type recorder struct {
+	msg *dns.Msg
+}
+
+func (w *recorder) LocalAddr() net.Addr { return &net.UDPAddr{IP: net.ParseIP("10.8.0.1"), Port: 53} }
+func (w *recorder) RemoteAddr() net.Addr {
+	return &net.UDPAddr{IP: net.ParseIP("10.8.0.2"), Port: 40000}
+}
+func (w *recorder) WriteMsg(m *dns.Msg) error   { w.msg = m; return nil }
+func (w *recorder) Write(b []byte) (int, error) { return len(b), nil }
+func (w *recorder) Close() error                { return nil }
+func (w *recorder) TsigStatus() error           { return nil }
+func (w *recorder) TsigTimersOnly(bool)         {}
+func (w *recorder) Hijack()                     {}
+
+// fallbackHandler records the queries and answers them with SERVFAIL, once release is closed if it is set.
+type fallbackHandler struct {
+	queries chan string
+	release chan struct{}
+}
+
+func (h *fallbackHandler) ServeDNS(w dns.ResponseWriter, r *dns.Msg) {
+	h.queries <- r.Question[0].Name
+	if h.release != nil {
+		<-h.release
+	}
+	m := new(dns.Msg)
+	m.SetRcode(r, dns.RcodeServerFailure)
+	w.WriteMsg(m)
+}
+
+func testZone(fallback dns.Handler) *Zone {
+	zone := NewZone("wg.internal", fallback)
+	_, ipv4, _ := net.ParseCIDR("10.8.0.1/24")
+	_, ipv6, _ := net.ParseCIDR("fd00::1/64")
+	zone.Networks = []*net.IPNet{ipv4, ipv6}
+	zone.Update(map[string][]net.IP{
+		"server":  {net.ParseIP("10.8.0.1"), net.ParseIP("fd00::1")},
+		"Laptop":  {net.ParseIP("10.8.0.2")},
+		"phone-6": {net.ParseIP("fd00::3")},
+	})
+	return zone
+}
+
+func TestZoneServeDNS(t *testing.T) {
+	tests := []struct {
+		name       string
+		qname      string
+		qtype      uint16
+		wantRcode  int
+		wantAnswer []string
+		wantSOA    string
+		fallback   bool
+	}{
+		{name: "A", qname: "laptop.wg.internal.", qtype: dns.TypeA, wantAnswer: []string{"10.8.0.2"}},
+		{name: "A case insensitive", qname: "Laptop.WG.internal.", qtype: dns.TypeA, wantAnswer: []string{"10.8.0.2"}},
+		{name: "AAAA", qname: "phone-6.wg.internal.", qtype: dns.TypeAAAA, wantAnswer: []string{"fd00::3"}},
+		{name: "ANY", qname: "server.wg.internal.", qtype: dns.TypeANY, wantAnswer: []string{"10.8.0.1", "fd00::1"}},
+		{name: "PTR IPv4", qname: "2.0.8.10.in-addr.arpa.", qtype: dns.TypePTR, wantAnswer: []string{"laptop.wg.internal."}},
+		{
+			name:       "PTR IPv6",
+			qname:      "3.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.d.f.ip6.arpa.",
+			qtype:      dns.TypePTR,
+			wantAnswer: []string{"phone-6.wg.internal."},
+		},
+		{name: "NODATA", qname: "laptop.wg.internal.", qtype: dns.TypeAAAA, wantSOA: "wg.internal."},
+		{name: "NXDOMAIN", qname: "tablet.wg.internal.", qtype: dns.TypeA, wantRcode: dns.RcodeNameError, wantSOA: "wg.internal."},
+		{name: "SOA of the origin", qname: "wg.internal.", qtype: dns.TypeSOA, wantAnswer: []string{"wg.internal."}},
+		{name: "NODATA of the origin", qname: "wg.internal.", qtype: dns.TypeA, wantSOA: "wg.internal."},
+		{
+			name:      "PTR of an unknown tunnel address",
+			qname:     "9.0.8.10.in-addr.arpa.",
+			qtype:     dns.TypePTR,
+			wantRcode: dns.RcodeNameError,
+			wantSOA:   "0.8.10.in-addr.arpa.",
+		},
+		{
+			name:      "PTR of an unknown tunnel IPv6 address",
+			qname:     "9.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.d.f.ip6.arpa.",
+			qtype:     dns.TypePTR,
+			wantRcode: dns.RcodeNameError,
+			wantSOA:   "0.0.0.0.0.0.0.0.0.0.0.0.0.0.d.f.ip6.arpa.",
+		},
+		{name: "reverse zone of the tunnel", qname: "0.8.10.in-addr.arpa.", qtype: dns.TypeNS, wantRcode: dns.RcodeNameError, wantSOA: "0.8.10.in-addr.arpa."},
+		{name: "PTR of a public address", qname: "8.8.8.8.in-addr.arpa.", qtype: dns.TypePTR, fallback: true},
+		{name: "PTR above the tunnel network", qname: "8.10.in-addr.arpa.", qtype: dns.TypePTR, fallback: true},
+		{name: "outside of the zone", qname: "example.com.", qtype: dns.TypeA, fallback: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fallback := &fallbackHandler{queries: make(chan string, 1)}
+			zone := testZone(fallback)
+			r := new(dns.Msg)
+			r.SetQuestion(test.qname, test.qtype)
+			w := &recorder{}
+			zone.ServeDNS(w, r)
+
+			if test.fallback {
+				select {
+				case <-fallback.queries:
+				default:
+					t.Fatalf("the query was answered with %v instead of the fallback handler", w.msg)
+				}
+				return
+			}
+			if len(fallback.queries) != 0 {
+				t.Fatal("the query was passed to the fallback handler")
+			}
+			m := w.msg
+			if m == nil || m.Rcode != test.wantRcode || !m.Authoritative {
+				t.Fatalf("ServeDNS() = %v, want the authoritative rcode %s", m, dns.RcodeToString[test.wantRcode])
+			}
+
+			var answers []string
+			for _, rr := range m.Answer {
+				switch rr := rr.(type) {
+				case *dns.A:
+					answers = append(answers, rr.A.String())
+				case *dns.AAAA:
+					answers = append(answers, rr.AAAA.String())
+				case *dns.PTR:
+					answers = append(answers, rr.Ptr)
+				case *dns.SOA:
+					answers = append(answers, rr.Hdr.Name)
+				}
+			}
+			if len(answers) != len(test.wantAnswer) {
+				t.Fatalf("ServeDNS() answered %v, want %v", answers, test.wantAnswer)
+			}
+			for i := range answers {
+				if answers[i] != test.wantAnswer[i] {
+					t.Errorf("ServeDNS() answered %v, want %v", answers, test.wantAnswer)
+				}
+			}
+
+			if test.wantSOA == "" {
+				if len(m.Ns) != 0 {
+					t.Errorf("ServeDNS() has the authority section %v, want none", m.Ns)
+				}
+				return
+			}
+			if len(m.Ns) != 1 || m.Ns[0].Header().Rrtype != dns.TypeSOA || m.Ns[0].Header().Name != test.wantSOA {
+				t.Errorf("ServeDNS() has the authority section %v, want the SOA of %s", m.Ns, test.wantSOA)
+			}
+		})
+	}
+}
+
+func TestZoneSlowFallback(t *testing.T) {
+	fallback := &fallbackHandler{queries: make(chan string, 1), release: make(chan struct{})}
+	zone := testZone(fallback)
+
+	// 1. A query waits for the upstream resolvers
+	done := make(chan struct{})
+	go func() {
+		r := new(dns.Msg)
+		r.SetQuestion("example.com.", dns.TypeA)
+		zone.ServeDNS(&recorder{}, r)
+		close(done)
+	}()
+	<-fallback.queries
+
+	// 2. The reload and the authoritative queries do not wait for it
+	answered := make(chan struct{})
+	go func() {
+		zone.Update(map[string][]net.IP{"tablet": {net.ParseIP("10.8.0.4")}})
+		r := new(dns.Msg)
+		r.SetQuestion("tablet.wg.internal.", dns.TypeA)
+		w := &recorder{}
+		zone.ServeDNS(w, r)
+		if w.msg == nil || len(w.msg.Answer) != 1 {
+			t.Errorf("ServeDNS() = %v after the reload, want the address of tablet", w.msg)
+		}
+		close(answered)
+	}()
+	select {
+	case <-answered:
+	case <-time.After(time.Second):
+		t.Error("the reload and the authoritative queries are blocked by a slow fallback")
+	}
+
+	close(fallback.release)
+	<-done
+}
+
+func TestZoneWithoutFallback(t *testing.T) {
+	zone := NewZone("wg.internal", nil)
+	r := new(dns.Msg)
+	r.SetQuestion("example.com.", dns.TypeA)
+	w := &recorder{}
+	zone.ServeDNS(w, r)
+	if w.msg == nil || w.msg.Rcode != dns.RcodeRefused {
+		t.Errorf("ServeDNS() = %v, want REFUSED without fallback handler", w.msg)
+	}
+}
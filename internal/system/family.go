@@ -0,0 +1,106 @@
+package system
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+/*
+Family is the IP address family carried by a tunnel: "ipv4", "ipv6" or "dual".
+
+The empty family means "dual", as for the interfaces created before the family was configurable.
+*/
+type Family string
+
+const (
+	FamilyIPv4 Family = "ipv4"
+	FamilyIPv6 Family = "ipv6"
+	FamilyDual Family = "dual"
+)
+
+var (
+	ipv6DisabledPath = "/proc/sys/net/ipv6/conf/all/disable_ipv6"
+)
+
+/*
+ParseFamily validates the name of a family.
+*/
+func ParseFamily(name string) (Family, error) {
+	switch family := Family(strings.ToLower(name)); family {
+	case FamilyIPv4, FamilyIPv6, FamilyDual:
+		return family, nil
+	case "":
+		return FamilyDual, nil
+	default:
+		return "", fmt.Errorf("unsupported address family %s, use ipv4, ipv6 or dual", name)
+	}
+}
+
+func (f Family) HasIPv4() bool {
+	return f != FamilyIPv6
+}
+
+func (f Family) HasIPv6() bool {
+	return f != FamilyIPv4
+}
+
+/*
+FilterAddresses keeps the entries of a comma-separated list of addresses (or templates such as
+"fd00::[auto-ipv6]/128") belonging to the family.
+*/
+func (f Family) FilterAddresses(addresses string) string {
+	var kept []string
+	for _, address := range strings.Split(addresses, ",") {
+		address = strings.TrimSpace(address)
+		if address == "" {
+			continue
+		}
+		if strings.Contains(address, ":") && !f.HasIPv6() || !strings.Contains(address, ":") && !f.HasIPv4() {
+			continue
+		}
+		kept = append(kept, address)
+	}
+	return strings.Join(kept, ", ")
+}
+
+/*
+IPv6Supported reports whether IPv6 is enabled in the kernel and ip6tables is installed.
+*/
+func IPv6Supported() bool {
+	// The file is missing if the kernel is built or booted without IPv6
+	content, err := os.ReadFile(ipv6DisabledPath)
+	if err != nil || strings.TrimSpace(string(content)) != "0" {
+		return false
+	}
+	_, err = exec.LookPath("ip6tables")
+	return err == nil
+}
+
+/*
+DetectFamily guesses the family of a new tunnel from the capabilities of the host.
+
+IPv6 is only carried if the host supports it and has an IPv6 default route, and IPv4 is
+dropped only if the host has no IPv4 default route at all.
+*/
+func DetectFamily() Family {
+	hasIPv4Route, hasIPv6Route := false, false
+	for _, route := range GetDefaultRoutes() {
+		if route.IPv6 {
+			hasIPv6Route = true
+		} else {
+			hasIPv4Route = true
+		}
+	}
+	ipv6 := hasIPv6Route && IPv6Supported()
+
+	switch {
+	case ipv6 && !hasIPv4Route:
+		return FamilyIPv6
+	case ipv6:
+		return FamilyDual
+	default:
+		return FamilyIPv4
+	}
+}
@@ -11,30 +11,26 @@ import (
 /*
 GetPhysicalInterface attempts to find the default physical network interface.
 
-It uses a "dial" trick to find the outbound interface, then filters it against
-loopback and managed interfaces.
+It takes the default route (IPv4 or IPv6) with the lowest metric from the kernel routing table,
+ignoring the interfaces managed by us, then falls back to the first suitable interface.
 */
 func GetPhysicalInterface() (string, error) {
-	// Strategy 1: Try to set up one UDP connection (won't send data) to search for the route list
-	// It is more accuary than purely enumerating the interface list.
-	conn, err := net.Dial("udp", "8.8.8.8:80")
-	if err == nil {
-		defer conn.Close()
-		localAddr := conn.LocalAddr().(*net.UDPAddr)
-
-		// Search the name of the interface based on the IP adress
-		iface, err := getInterfaceByIP(localAddr.IP)
-		if err == nil {
-			// Validate the name (low probability, for safety)
-			isManaged, _ := tracker.IsManagedByUs(iface.Name)
-			if !isManaged {
-				fmt.Printf("✅ Get the physical interface: %s\n", iface.Name)
-				return iface.Name, nil
-			}
+	// Strategy 1: Follow the default routes, as the kernel does for the outbound traffic
+	for _, route := range GetDefaultRoutes() {
+		// Ignore the default routes through our own tunnels
+		isManaged, _ := tracker.IsManagedByUs(route.Interface)
+		if isManaged {
+			continue
+		}
+		iface, err := net.InterfaceByName(route.Interface)
+		if err != nil || iface.Flags&net.FlagUp == 0 {
+			continue
 		}
+		fmt.Printf("✅ Get the physical interface: %s\n", iface.Name)
+		return iface.Name, nil
 	}
 
-	// Strategy 2: If there is no network or the method above does not work, fall back to search all the interfaces
+	// Strategy 2: If there is no default route, fall back to search all the interfaces
 	// Search for the first interface Up, not Loopback and not managed by us
 	ifaces, err := net.Interfaces()
 	if err != nil {
@@ -68,7 +64,7 @@ func GetPhysicalInterface() (string, error) {
 		}
 
 		// Found a interface that meets the requirements
-		fmt.Printf("✅ Get the physical interface: %s\n", i.Name)
+		fmt.Printf("Warning: no default route found, using the interface %s\n", i.Name)
 		return i.Name, nil
 	}
 
@@ -76,30 +72,47 @@ func GetPhysicalInterface() (string, error) {
 }
 
 /*
-getInterfaceByIP finds the network interface object corresponding to the IP address.
+GetInterfaceIP returns the first global unicast address of the given interface.
+
+IPv4 addresses are preferred unless preferIPv6 is set.
 */
-func getInterfaceByIP(ip net.IP) (*net.Interface, error) {
-	ifaces, err := net.Interfaces()
+func GetInterfaceIP(interfaceName string, preferIPv6 bool) (net.IP, error) {
+	iface, err := net.InterfaceByName(interfaceName)
 	if err != nil {
 		return nil, err
 	}
-	for _, i := range ifaces {
-		addrs, err := i.Addrs()
-		if err != nil {
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return nil, err
+	}
+
+	var ipv4, ipv6 net.IP
+	for _, addr := range addrs {
+		var currentIP net.IP
+		switch v := addr.(type) {
+		case *net.IPNet:
+			currentIP = v.IP
+		case *net.IPAddr:
+			currentIP = v.IP
+		}
+		if !currentIP.IsGlobalUnicast() {
 			continue
 		}
-		for _, addr := range addrs {
-			var currentIP net.IP
-			switch v := addr.(type) {
-			case *net.IPNet:
-				currentIP = v.IP
-			case *net.IPAddr:
-				currentIP = v.IP
-			}
-			if currentIP.Equal(ip) {
-				return &i, nil
-			}
+		if currentIP.To4() != nil && ipv4 == nil {
+			ipv4 = currentIP
+		} else if currentIP.To4() == nil && ipv6 == nil {
+			ipv6 = currentIP
 		}
 	}
-	return nil, errors.New("interface not found for IP")
+
+	if preferIPv6 && ipv6 != nil {
+		return ipv6, nil
+	}
+	if ipv4 != nil {
+		return ipv4, nil
+	}
+	if ipv6 != nil {
+		return ipv6, nil
+	}
+	return nil, fmt.Errorf("no global address on interface %s", interfaceName)
 }
@@ -1,6 +1,8 @@
 package system
 
 import (
+	"context"
+	"fast-wireguard/internal/tracker"
 	"fmt"
 	"io"
 	"net"
@@ -9,38 +11,211 @@ import (
 	"time"
 )
 
-// Define reliable providers for fetching public IPs.
-var ipProviders = []string{
+// Define reliable providers for fetching public IPs, all of them over HTTPS.
+var DefaultHTTPProviders = []string{
 	"https://api.ipify.org?format=text",
 	"https://ifconfig.me/ip",
-	"http://checkip.amazonaws.com",
+	"https://checkip.amazonaws.com",
 	"https://icanhazip.com",
 }
 
+// Define public STUN servers answering Binding requests.
+var DefaultSTUNServers = []string{
+	"stun.l.google.com:19302",
+	"stun.cloudflare.com:3478",
+}
+
+/*
+IPSource is one way of discovering the public IP of the server.
+*/
+type IPSource interface {
+	Name() string
+	// Discover returns the public IP seen over the given network ("ip4" or "ip6").
+	Discover(network string) (net.IP, error)
+}
+
+/*
+DiscoveryOptions configures the chain of sources used to discover the public IP.
+
+A nil list of STUN servers or HTTP providers means the default one, "none" disables it.
+*/
+type DiscoveryOptions struct {
+	STUNServers   []string
+	HTTPProviders []string
+	// Number of sources which must agree on the IP
+	Consensus int
+	// Only use the local outbound IP, without contacting any third party
+	Offline    bool
+	PreferIPv6 bool
+	// How long a discovered IP is reused, 0 to always discover it again
+	CacheTTL time.Duration
+}
+
+/*
+Sources returns the sources of the chain in order: the STUN servers first, then the HTTP providers.
+*/
+func (o *DiscoveryOptions) Sources() []IPSource {
+	var sources []IPSource
+	for _, server := range listOrDefault(o.STUNServers, DefaultSTUNServers) {
+		sources = append(sources, &STUNSource{Server: server})
+	}
+	for _, url := range listOrDefault(o.HTTPProviders, DefaultHTTPProviders) {
+		sources = append(sources, &HTTPSource{URL: url})
+	}
+	return sources
+}
+
+// listOrDefault returns the default list if the list is nil, and nothing if it is "none".
+func listOrDefault(list []string, defaultList []string) []string {
+	if list == nil {
+		return defaultList
+	}
+	if len(list) == 1 && list[0] == "none" {
+		return nil
+	}
+	return list
+}
+
 /*
 GetPublicIP attempts to fetch the public IP address of the server.
-It sequentially requests external APIs and falls back to the local outbound IP if all fail.
+It discovers the IP over the preferred IP family, then over the other one, using the cached IP if it is recent enough,
+and falls back to the local outbound IP if all fail or in offline mode.
 */
-func GetPublicIP() (string, error) {
-	// 1. Attempt to fetch real public IP via external APIs.
-	// Set a 3-second timeout to prevent blocking.
-	client := &http.Client{
-		Timeout: 3 * time.Second,
+func GetPublicIP(opts *DiscoveryOptions) (string, error) {
+	if opts.Offline {
+		return GetLocalOutboundIP()
 	}
 
-	for _, url := range ipProviders {
-		ip, err := fetchIP(client, url)
-		if err == nil && isValidIP(ip) {
+	networks := []string{"ip4", "ip6"}
+	if opts.PreferIPv6 {
+		networks = []string{"ip6", "ip4"}
+	}
+	for _, network := range networks {
+		ip, cached, err := LookupPublicIP(opts, network)
+		if err != nil {
+			continue
+		}
+		if cached {
+			fmt.Printf("✅ Get the server IP (cached): %s\n", ip)
+		} else {
 			fmt.Printf("✅ Get the server IP: %s\n", ip)
-			return ip, nil
 		}
+		return ip.String(), nil
 	}
 
-	// 2. If all external APIs fail, fallback to local outbound IP (LAN IP).
-	fmt.Println("Warning: Could not fetch public IP from external APIs, falling back to local IP.")
+	// If all sources fail, fallback to local outbound IP (LAN IP).
+	fmt.Println("Warning: Could not discover the public IP, falling back to local IP.")
 	return GetLocalOutboundIP()
 }
 
+/*
+LookupPublicIP returns the public IP of the server over the given network ("ip4" or "ip6"), reusing the
+cached IP if it is recent enough, and caching a newly discovered one. Reports whether the IP was cached.
+*/
+func LookupPublicIP(opts *DiscoveryOptions, network string) (net.IP, bool, error) {
+	// 1. Reuse the IP discovered recently
+	cache := tracker.LoadPublicIPCache()
+	if cached, ok := cache[network]; ok && opts.CacheTTL > 0 && time.Since(cached.UpdatedAt) < opts.CacheTTL {
+		if ip := net.ParseIP(cached.IP); ip != nil {
+			return ip, true, nil
+		}
+	}
+
+	// 2. Attempt to discover the real public IP via the sources
+	ip, err := DiscoverPublicIP(opts.Sources(), network, opts.Consensus)
+	if err != nil {
+		return nil, false, err
+	}
+	cache[network] = tracker.CachedIP{IP: ip.String(), UpdatedAt: time.Now()}
+	if err := tracker.SavePublicIPCache(cache); err != nil {
+		fmt.Printf("Warning: failed to cache the public IP: %v\n", err)
+	}
+	return ip, false, nil
+}
+
+/*
+DiscoverPublicIP queries the sources one after another until `consensus` of them return the same IP.
+
+If the sources disagree and no IP reaches the consensus, the most frequent answer is returned with a warning.
+*/
+func DiscoverPublicIP(sources []IPSource, network string, consensus int) (net.IP, error) {
+	consensus = max(1, min(consensus, len(sources)))
+
+	votes := make(map[string]int)
+	var best string
+	for _, source := range sources {
+		ip, err := source.Discover(network)
+		if err != nil || (ip.To4() != nil) != (network == "ip4") {
+			continue
+		}
+		key := ip.String()
+		votes[key]++
+		if votes[key] > votes[best] {
+			best = key
+		}
+		if votes[key] >= consensus {
+			return ip, nil
+		}
+	}
+
+	if best == "" {
+		return nil, fmt.Errorf("no source returned a public %s address", network)
+	}
+	if len(votes) > 1 {
+		fmt.Printf("Warning: the sources disagree on the public IP (%d answers), using %s.\n", len(votes), best)
+	} else {
+		fmt.Printf("Warning: only %d source(s) returned the public IP %s.\n", votes[best], best)
+	}
+	return net.ParseIP(best), nil
+}
+
+/*
+HTTPSource discovers the public IP with an HTTP request to a provider answering the IP as plain text.
+*/
+type HTTPSource struct {
+	URL     string
+	Timeout time.Duration
+}
+
+func (s *HTTPSource) Name() string {
+	return s.URL
+}
+
+/*
+Discover requests the provider over the given network ("ip4" or "ip6").
+*/
+func (s *HTTPSource) Discover(network string) (net.IP, error) {
+	timeout := s.Timeout
+	if timeout == 0 {
+		// Set a 3-second timeout to prevent blocking.
+		timeout = 3 * time.Second
+	}
+	client := &http.Client{
+		Timeout:   timeout,
+		Transport: familyTransport(strings.Replace(network, "ip", "tcp", 1)),
+	}
+
+	ip, err := fetchIP(client, s.URL)
+	if err != nil {
+		return nil, err
+	}
+	parsedIP := net.ParseIP(ip)
+	if parsedIP == nil {
+		return nil, fmt.Errorf("invalid IP %q from %s", ip, s.URL)
+	}
+	return parsedIP, nil
+}
+
+// familyTransport returns an HTTP transport only connecting over the given network ("tcp4" or "tcp6").
+func familyTransport(network string) *http.Transport {
+	dialer := &net.Dialer{Timeout: 3 * time.Second}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = func(ctx context.Context, _, addr string) (net.Conn, error) {
+		return dialer.DialContext(ctx, network, addr)
+	}
+	return transport
+}
+
 // fetchIP performs an HTTP request to get the IP.
 func fetchIP(client *http.Client, url string) (string, error) {
 	resp, err := client.Get(url)
@@ -48,8 +223,11 @@ func fetchIP(client *http.Client, url string) (string, error) {
 		return "", err
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status %s from %s", resp.Status, url)
+	}
 
-	body, err := io.ReadAll(resp.Body)
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 256))
 	if err != nil {
 		return "", err
 	}
@@ -60,23 +238,19 @@ func fetchIP(client *http.Client, url string) (string, error) {
 /*
 GetLocalOutboundIP obtains the preferred local outbound IP address.
 
-Using the UDP Dial technique (without actually sending packets), this is the most accurate way to get the local LAN IP of the machine.
+It is the address of the interface holding the default route, so no packet is sent and no public host is involved.
 */
 func GetLocalOutboundIP() (string, error) {
-	conn, err := net.Dial("udp", "8.8.8.8:80")
+	physicalInterface, err := GetPhysicalInterface()
+	if err != nil {
+		return "", err
+	}
+	localIP, err := GetInterfaceIP(physicalInterface, false)
 	if err != nil {
 		return "", err
 	}
-	defer conn.Close()
 
-	localAddr := conn.LocalAddr().(*net.UDPAddr)
-	ip := localAddr.IP.String()
+	ip := localIP.String()
 	fmt.Printf("✅ Get the local outbound IP (may not work for WireGuard): %s\n", ip)
 	return ip, nil
 }
-
-// isValidIP validate the form of the ip string.
-func isValidIP(ip string) bool {
-	parsedIP := net.ParseIP(ip)
-	return parsedIP != nil
-}
@@ -9,12 +9,10 @@ import (
 
 var (
 	// sysctlConfigPath is the path to the sysctl configuration file for IP forwarding
-	sysctlConfigPath = "/etc/sysctl.d/99-fast-wireguard.conf"
-	configContent    = `#This file is auto-generated by fast-wireguard.
-#Please do not edit manually.
-net.ipv4.ip_forward=1
-net.ipv6.conf.all.forwarding=1
-`
+	sysctlConfigPath   = "/etc/sysctl.d/99-fast-wireguard.conf"
+	configHeader       = "#This file is auto-generated by fast-wireguard.\n#Please do not edit manually.\n"
+	ipv4ForwardSetting = "net.ipv4.ip_forward=1"
+	ipv6ForwardSetting = "net.ipv6.conf.all.forwarding=1"
 )
 
 func isForwardingEnabled(version string) bool {
@@ -38,32 +36,38 @@ func checkSysctl(s string) bool {
 
 /*
 Write Configuration to enable IP forwarding on Linux systems.
+
+Only the forwarding of the families carried by the tunnel is enabled, the settings written
+for other interfaces are kept.
 Returns an error if the operation fails.
 */
-func EnableIPForwarding() error {
+func EnableIPForwarding(family Family) error {
 	// Write ip_forwarding configuration
-	if _, err := os.Stat(sysctlConfigPath); err == nil {
-		// If the file exists, do nothing
+	content, err := os.ReadFile(sysctlConfigPath)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("Failed to check sysctl config file: %w", err)
+	}
+	ipv4 := family.HasIPv4() || strings.Contains(string(content), ipv4ForwardSetting)
+	ipv6 := family.HasIPv6() || strings.Contains(string(content), ipv6ForwardSetting)
+	newContent := configHeader
+	if ipv4 {
+		newContent += ipv4ForwardSetting + "\n"
+	}
+	if ipv6 {
+		newContent += ipv6ForwardSetting + "\n"
+	}
+	if string(content) == newContent {
+		// If the file already enables the forwarding of the families, do nothing
 		fmt.Println("Configuration file for IP forwarding already exists.")
-		// // Ask the user to confirm overwriting
-		// if utils.PromptConfirm("Do you want to overwrite this file?", false) {
-		// 	fmt.Println("Overwriting configuration file for IP forwarding...")
-		// 	if err := os.WriteFile(sysctlConfigPath, []byte(configContent), 0644); err != nil {
-		// 		return err
-		// 	}
-		// }
-	} else if os.IsNotExist(err) {
-		// If the config file does not exist, create it
-		if err := os.WriteFile(sysctlConfigPath, []byte(configContent), 0644); err != nil {
+	} else {
+		if err := os.WriteFile(sysctlConfigPath, []byte(newContent), 0644); err != nil {
 			return err
 		}
 		fmt.Println("✅ Configuration file for IP forwarding created.")
-	} else {
-		return fmt.Errorf("Failed to check sysctl config file: %w", err)
 	}
 
 	// Detect the ip4 forwarding and ipv6 forwarding
-	if isForwardingEnabled("ipv4") && isForwardingEnabled("ipv6") {
+	if (!ipv4 || isForwardingEnabled("ipv4")) && (!ipv6 || isForwardingEnabled("ipv6")) {
 		fmt.Println("IP forwarding is already enabled.")
 		return nil
 	}
@@ -0,0 +1,53 @@
+package system
+
+import (
+	"fast-wireguard/internal/tracker"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestLookupPublicIPCache(t *testing.T) {
+	settingsDir := tracker.SettingsDir
+	tracker.SettingsDir = t.TempDir()
+	t.Cleanup(func() { tracker.SettingsDir = settingsDir })
+
+	server, requests := stunServer(t, "udp4", "127.0.0.1:0", func(request []byte) []byte {
+		return bindingSuccess(request, stunAttrXorMappedAddress, net.ParseIP("203.0.113.7"))
+	})
+	opts := &DiscoveryOptions{
+		STUNServers:   []string{server},
+		HTTPProviders: []string{"none"},
+		Consensus:     1,
+		CacheTTL:      time.Hour,
+	}
+	lookup := func(wantCached bool, wantRequests int32) {
+		t.Helper()
+		ip, cached, err := LookupPublicIP(opts, "ip4")
+		if err != nil {
+			t.Fatalf("LookupPublicIP() error = %v", err)
+		}
+		if !ip.Equal(net.ParseIP("203.0.113.7")) || cached != wantCached {
+			t.Errorf("LookupPublicIP() = %s, cached %t, want 203.0.113.7, cached %t", ip, cached, wantCached)
+		}
+		if n := requests.Load(); n != wantRequests {
+			t.Errorf("%d STUN requests sent, want %d", n, wantRequests)
+		}
+	}
+
+	// 1. The first lookup discovers the IP, the next ones reuse it within the TTL
+	lookup(false, 1)
+	lookup(true, 1)
+
+	// 2. An expired IP is discovered again
+	cache := tracker.LoadPublicIPCache()
+	cache["ip4"] = tracker.CachedIP{IP: "203.0.113.7", UpdatedAt: time.Now().Add(-2 * time.Hour)}
+	if err := tracker.SavePublicIPCache(cache); err != nil {
+		t.Fatal(err)
+	}
+	lookup(false, 2)
+
+	// 3. A TTL of 0 disables the cache
+	opts.CacheTTL = 0
+	lookup(false, 3)
+}
@@ -0,0 +1,50 @@
+package system
+
+import (
+	"fmt"
+	"net"
+)
+
+const (
+	// WireGuardOverheadIPv4 is the size of the IPv4 (20), UDP (8) and WireGuard (32) headers
+	WireGuardOverheadIPv4 = 60
+	// WireGuardOverheadIPv6 is the size of the IPv6 (40), UDP (8) and WireGuard (32) headers
+	WireGuardOverheadIPv6 = 80
+	// DefaultClientMTU fits the clients behind a 1500 bytes link with IPv6 transport
+	DefaultClientMTU = 1500 - WireGuardOverheadIPv6
+)
+
+/*
+GetTunnelMTU computes the MTU of a WireGuard interface sending its packets through the given physical interface.
+
+The MTU of the path (e.g. from ProbePathMTU) is used instead of the one of the interface if it is smaller, 0 if unknown.
+The overhead of IPv6 is used if the clients may connect over IPv6, i.e. if the physical interface has an IPv6 default route.
+*/
+func GetTunnelMTU(physicalInterface string, pathMTU int) (int, error) {
+	iface, err := net.InterfaceByName(physicalInterface)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get the MTU of %s: %w", physicalInterface, err)
+	}
+
+	overhead := WireGuardOverheadIPv4
+	for _, route := range GetDefaultRoutes() {
+		if route.IPv6 && route.Interface == physicalInterface {
+			overhead = WireGuardOverheadIPv6
+			break
+		}
+	}
+	linkMTU := iface.MTU
+	if pathMTU > 0 && pathMTU < linkMTU {
+		linkMTU = pathMTU
+	}
+	return linkMTU - overhead, nil
+}
+
+/*
+GetClientMTU recommends the MTU of the clients of a tunnel with the given MTU.
+
+The link of the clients is unknown, so a common Ethernet link with IPv6 transport is assumed.
+*/
+func GetClientMTU(tunnelMTU int) int {
+	return min(tunnelMTU, DefaultClientMTU)
+}
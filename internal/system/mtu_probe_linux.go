@@ -0,0 +1,71 @@
+package system
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"syscall"
+	"time"
+)
+
+/*
+ProbePathMTU discovers the path MTU towards the given host.
+
+It sends UDP packets as large as the outbound interface allows with the "Don't Fragment" bit set,
+and lets the kernel lower the MTU of the route with the ICMP "Packet Too Big" messages of the routers.
+The overhead of the tunnel is not subtracted.
+*/
+func ProbePathMTU(host string) (int, error) {
+	conn, err := net.Dial("udp", net.JoinHostPort(host, "33434"))
+	if err != nil {
+		return 0, fmt.Errorf("failed to reach %s: %w", host, err)
+	}
+	defer conn.Close()
+
+	udpConn := conn.(*net.UDPConn)
+	rawConn, err := udpConn.SyscallConn()
+	if err != nil {
+		return 0, err
+	}
+	level, discoverOpt, discoverVal, mtuOpt := syscall.IPPROTO_IP, syscall.IP_MTU_DISCOVER, syscall.IP_PMTUDISC_DO, syscall.IP_MTU
+	headerSize := 28
+	if udpConn.RemoteAddr().(*net.UDPAddr).IP.To4() == nil {
+		level, discoverOpt, discoverVal, mtuOpt = syscall.IPPROTO_IPV6, syscall.IPV6_MTU_DISCOVER, syscall.IPV6_PMTUDISC_DO, syscall.IPV6_MTU
+		headerSize = 48
+	}
+
+	// getMTU reads the MTU of the route known by the kernel
+	getMTU := func() (int, error) {
+		var mtu int
+		var sockErr error
+		err := rawConn.Control(func(fd uintptr) {
+			mtu, sockErr = syscall.GetsockoptInt(int(fd), level, mtuOpt)
+		})
+		if err == nil {
+			err = sockErr
+		}
+		return mtu, err
+	}
+
+	var sockErr error
+	if err := rawConn.Control(func(fd uintptr) {
+		sockErr = syscall.SetsockoptInt(int(fd), level, discoverOpt, discoverVal)
+	}); err != nil || sockErr != nil {
+		return 0, fmt.Errorf("failed to enable path MTU discovery: %v", errors.Join(err, sockErr))
+	}
+
+	for range 3 {
+		mtu, err := getMTU()
+		if err != nil {
+			return 0, err
+		}
+		// Send a packet filling the current MTU, the kernel refuses it once a smaller MTU is learned.
+		// The host is not expected to listen on the port, so "connection refused" is fine.
+		_, err = conn.Write(make([]byte, mtu-headerSize))
+		if err != nil && !errors.Is(err, syscall.EMSGSIZE) && !errors.Is(err, syscall.ECONNREFUSED) {
+			return 0, fmt.Errorf("failed to send the probe: %w", err)
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+	return getMTU()
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+
+package system
+
+import (
+	"errors"
+)
+
+/*
+ProbePathMTU discovers the path MTU towards the given host, which is only supported on Linux.
+*/
+func ProbePathMTU(host string) (int, error) {
+	return 0, errors.New("path MTU discovery is only supported on Linux")
+}
@@ -0,0 +1,37 @@
+package system
+
+import (
+	"bufio"
+	"net"
+	"os"
+	"strings"
+)
+
+var (
+	resolvConfPath = "/etc/resolv.conf"
+)
+
+/*
+GetSystemResolvers returns the nameservers configured in /etc/resolv.conf.
+
+Falls back to public resolvers if no nameserver is configured.
+*/
+func GetSystemResolvers() []string {
+	var servers []string
+	file, err := os.Open(resolvConfPath)
+	if err == nil {
+		defer file.Close()
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			fields := strings.Fields(scanner.Text())
+			if len(fields) >= 2 && fields[0] == "nameserver" && net.ParseIP(fields[1]) != nil {
+				servers = append(servers, fields[1])
+			}
+		}
+	}
+
+	if len(servers) == 0 {
+		return []string{"1.1.1.1", "8.8.8.8"}
+	}
+	return servers
+}
@@ -0,0 +1,114 @@
+package system
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	ipv4RoutePath = "/proc/net/route"
+	ipv6RoutePath = "/proc/net/ipv6_route"
+)
+
+const (
+	routeFlagUp     = 0x0001
+	routeFlagReject = 0x0200
+)
+
+/*
+DefaultRoute is one default route of the kernel routing table.
+*/
+type DefaultRoute struct {
+	Interface string
+	Gateway   net.IP
+	Metric    int
+	IPv6      bool
+}
+
+/*
+GetDefaultRoutes returns the IPv4 and IPv6 default routes of the kernel, sorted by metric.
+
+On equal metrics, IPv4 routes come first.
+*/
+func GetDefaultRoutes() []DefaultRoute {
+	routes := append(readIPv4DefaultRoutes(), readIPv6DefaultRoutes()...)
+	sort.SliceStable(routes, func(i, j int) bool {
+		if routes[i].Metric != routes[j].Metric {
+			return routes[i].Metric < routes[j].Metric
+		}
+		return !routes[i].IPv6 && routes[j].IPv6
+	})
+	return routes
+}
+
+// readIPv4DefaultRoutes parses /proc/net/route:
+// Iface Destination Gateway Flags RefCnt Use Metric Mask MTU Window IRTT
+func readIPv4DefaultRoutes() []DefaultRoute {
+	var routes []DefaultRoute
+	for _, fields := range readRouteTable(ipv4RoutePath) {
+		if len(fields) < 8 || fields[1] != "00000000" || fields[7] != "00000000" {
+			continue
+		}
+		flags, err := strconv.ParseUint(fields[3], 16, 32)
+		if err != nil || flags&routeFlagUp == 0 || flags&routeFlagReject != 0 {
+			continue
+		}
+		metric, _ := strconv.Atoi(fields[6])
+		route := DefaultRoute{Interface: fields[0], Metric: metric}
+		// The addresses are written as a number in the host byte order
+		if gateway, err := hex.DecodeString(fields[2]); err == nil && len(gateway) == 4 {
+			route.Gateway = make(net.IP, 4)
+			binary.NativeEndian.PutUint32(route.Gateway, binary.BigEndian.Uint32(gateway))
+		}
+		routes = append(routes, route)
+	}
+	return routes
+}
+
+// readIPv6DefaultRoutes parses /proc/net/ipv6_route:
+// Destination PrefixLength Source PrefixLength NextHop Metric RefCnt Use Flags Iface
+func readIPv6DefaultRoutes() []DefaultRoute {
+	var routes []DefaultRoute
+	for _, fields := range readRouteTable(ipv6RoutePath) {
+		if len(fields) < 10 || strings.Trim(fields[0], "0") != "" || fields[1] != "00" {
+			continue
+		}
+		flags, err := strconv.ParseUint(fields[8], 16, 32)
+		if err != nil || flags&routeFlagUp == 0 || flags&routeFlagReject != 0 || fields[9] == "lo" {
+			continue
+		}
+		metric, _ := strconv.ParseUint(fields[5], 16, 32)
+		route := DefaultRoute{Interface: fields[9], Metric: int(metric), IPv6: true}
+		if gateway, err := hex.DecodeString(fields[4]); err == nil && len(gateway) == 16 {
+			route.Gateway = net.IP(gateway)
+		}
+		routes = append(routes, route)
+	}
+	return routes
+}
+
+// readRouteTable reads the lines of a routing table of /proc as fields, without the header of IPv4.
+func readRouteTable(path string) [][]string {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	var lines [][]string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || fields[0] == "Iface" {
+			continue
+		}
+		lines = append(lines, fields)
+	}
+	return lines
+}
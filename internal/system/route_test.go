@@ -0,0 +1,89 @@
+package system
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// procAddress formats the IPv4 address like the kernel in /proc/net/route: a number in the host byte order.
+func procAddress(ip string) string {
+	return fmt.Sprintf("%08X", binary.NativeEndian.Uint32(net.ParseIP(ip).To4()))
+}
+
+// useRouteTables points the routing tables of /proc to fixtures with the given lines.
+func useRouteTables(t *testing.T, ipv4 string, ipv6 string) {
+	t.Helper()
+	dir := t.TempDir()
+	oldIPv4, oldIPv6 := ipv4RoutePath, ipv6RoutePath
+	ipv4RoutePath, ipv6RoutePath = filepath.Join(dir, "route"), filepath.Join(dir, "ipv6_route")
+	t.Cleanup(func() { ipv4RoutePath, ipv6RoutePath = oldIPv4, oldIPv6 })
+	if err := os.WriteFile(ipv4RoutePath, []byte(ipv4), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(ipv6RoutePath, []byte(ipv6), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGetDefaultRoutes(t *testing.T) {
+	ipv4 := "Iface\tDestination\tGateway \tFlags\tRefCnt\tUse\tMetric\tMask\t\tMTU\tWindow\tIRTT\n" +
+		// Default route through Wi-Fi, with a higher metric than the Ethernet one
+		fmt.Sprintf("wlan0\t00000000\t%s\t0003\t0\t0\t600\t00000000\t0\t0\t0\n", procAddress("192.168.2.1")) +
+		fmt.Sprintf("eth0\t00000000\t%s\t0003\t0\t0\t100\t00000000\t0\t0\t0\n", procAddress("10.1.0.254")) +
+		// Not a default route
+		fmt.Sprintf("eth0\t%s\t00000000\t0001\t0\t0\t100\t%s\t0\t0\t0\n", procAddress("10.1.0.0"), procAddress("255.255.255.0")) +
+		// Default route which is down, and an unreachable one
+		fmt.Sprintf("eth1\t00000000\t%s\t0002\t0\t0\t50\t00000000\t0\t0\t0\n", procAddress("172.16.0.1")) +
+		"eth2\t00000000\t00000000\t0201\t0\t0\t10\t00000000\t0\t0\t0\n"
+	ipv6 := "" +
+		// Default route through the router
+		"00000000000000000000000000000000 00 00000000000000000000000000000000 00 fe800000000000000000000000000001 00000400 00000001 00000000 00450003     eth0\n" +
+		// Not a default route
+		"20010db8000000000000000000000000 40 00000000000000000000000000000000 00 00000000000000000000000000000000 00000100 00000001 00000000 00000001     eth0\n" +
+		// Unreachable default route of the loopback
+		"00000000000000000000000000000000 00 00000000000000000000000000000000 00 00000000000000000000000000000000 ffffffff 00000001 00000000 00200200       lo\n" +
+		// Default route which is down
+		"00000000000000000000000000000000 00 00000000000000000000000000000000 00 fe800000000000000000000000000002 00000010 00000001 00000000 00450002     eth1\n"
+	useRouteTables(t, ipv4, ipv6)
+
+	want := []DefaultRoute{
+		{Interface: "eth0", Gateway: net.ParseIP("10.1.0.254").To4(), Metric: 100},
+		{Interface: "wlan0", Gateway: net.ParseIP("192.168.2.1").To4(), Metric: 600},
+		{Interface: "eth0", Gateway: net.ParseIP("fe80::1"), Metric: 1024, IPv6: true},
+	}
+	routes := GetDefaultRoutes()
+	if len(routes) != len(want) {
+		t.Fatalf("GetDefaultRoutes() = %v, want %v", routes, want)
+	}
+	for i := range want {
+		if routes[i].Interface != want[i].Interface || !routes[i].Gateway.Equal(want[i].Gateway) ||
+			routes[i].Metric != want[i].Metric || routes[i].IPv6 != want[i].IPv6 {
+			t.Errorf("GetDefaultRoutes()[%d] = %+v, want %+v", i, routes[i], want[i])
+		}
+	}
+}
+
+func TestGetDefaultRoutesEqualMetrics(t *testing.T) {
+	ipv4 := fmt.Sprintf("eth0\t00000000\t%s\t0003\t0\t0\t1024\t00000000\t0\t0\t0\n", procAddress("192.0.2.1"))
+	ipv6 := "00000000000000000000000000000000 00 00000000000000000000000000000000 00 fe800000000000000000000000000001 00000400 00000001 00000000 00450003     eth0\n"
+	useRouteTables(t, ipv4, ipv6)
+
+	routes := GetDefaultRoutes()
+	if len(routes) != 2 || routes[0].IPv6 || !routes[1].IPv6 {
+		t.Errorf("GetDefaultRoutes() = %+v, want the IPv4 route first on equal metrics", routes)
+	}
+}
+
+func TestGetDefaultRoutesWithoutTables(t *testing.T) {
+	oldIPv4, oldIPv6 := ipv4RoutePath, ipv6RoutePath
+	ipv4RoutePath, ipv6RoutePath = filepath.Join(t.TempDir(), "route"), filepath.Join(t.TempDir(), "ipv6_route")
+	t.Cleanup(func() { ipv4RoutePath, ipv6RoutePath = oldIPv4, oldIPv6 })
+
+	if routes := GetDefaultRoutes(); len(routes) != 0 {
+		t.Errorf("GetDefaultRoutes() = %+v without routing tables, want none", routes)
+	}
+}
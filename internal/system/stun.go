@@ -0,0 +1,152 @@
+package system
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+const (
+	stunMagicCookie          = 0x2112A442
+	stunBindingRequest       = 0x0001
+	stunBindingSuccess       = 0x0101
+	stunAttrMappedAddress    = 0x0001
+	stunAttrXorMappedAddress = 0x0020
+	stunHeaderSize           = 20
+)
+
+/*
+STUNSource discovers the public IP with a STUN Binding request (RFC 5389) to the given server ("host:port").
+*/
+type STUNSource struct {
+	Server  string
+	Timeout time.Duration
+}
+
+func (s *STUNSource) Name() string {
+	return "stun:" + s.Server
+}
+
+/*
+Discover sends the Binding request over the given network ("ip4" or "ip6") and returns the mapped address.
+
+The request is sent up to 3 times, as UDP may lose it.
+*/
+func (s *STUNSource) Discover(network string) (net.IP, error) {
+	udpNetwork := "udp4"
+	if network == "ip6" {
+		udpNetwork = "udp6"
+	}
+	conn, err := net.Dial(udpNetwork, s.Server)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	// Header: type, length, magic cookie and transaction ID
+	request := make([]byte, stunHeaderSize)
+	binary.BigEndian.PutUint16(request[0:2], stunBindingRequest)
+	binary.BigEndian.PutUint16(request[2:4], 0)
+	binary.BigEndian.PutUint32(request[4:8], stunMagicCookie)
+	if _, err := rand.Read(request[8:20]); err != nil {
+		return nil, err
+	}
+
+	timeout := s.Timeout
+	if timeout == 0 {
+		timeout = time.Second
+	}
+	response := make([]byte, 1500)
+	for range 3 {
+		if _, err := conn.Write(request); err != nil {
+			return nil, err
+		}
+		conn.SetReadDeadline(time.Now().Add(timeout))
+		n, err := conn.Read(response)
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				continue
+			}
+			return nil, err
+		}
+		return parseSTUNResponse(response[:n], request[8:20])
+	}
+	return nil, fmt.Errorf("no response from %s", s.Server)
+}
+
+// parseSTUNResponse extracts the (XOR-)MAPPED-ADDRESS of a Binding success response.
+func parseSTUNResponse(response []byte, transactionID []byte) (net.IP, error) {
+	if len(response) < stunHeaderSize ||
+		binary.BigEndian.Uint16(response[0:2]) != stunBindingSuccess ||
+		binary.BigEndian.Uint32(response[4:8]) != stunMagicCookie ||
+		!bytes.Equal(response[8:20], transactionID) {
+		return nil, errors.New("invalid STUN response")
+	}
+	length := int(binary.BigEndian.Uint16(response[2:4]))
+	if stunHeaderSize+length > len(response) {
+		return nil, errors.New("truncated STUN response")
+	}
+
+	var mapped net.IP
+	attrs := response[stunHeaderSize : stunHeaderSize+length]
+	for len(attrs) >= 4 {
+		attrType := binary.BigEndian.Uint16(attrs[0:2])
+		attrLength := int(binary.BigEndian.Uint16(attrs[2:4]))
+		if 4+attrLength > len(attrs) {
+			break
+		}
+		value := attrs[4 : 4+attrLength]
+		switch attrType {
+		case stunAttrXorMappedAddress:
+			// The XOR-MAPPED-ADDRESS is preferred, as some NATs rewrite the plain one
+			if ip := parseSTUNAddress(value, response[4:20]); ip != nil {
+				return ip, nil
+			}
+		case stunAttrMappedAddress:
+			mapped = parseSTUNAddress(value, nil)
+		}
+		// Attributes are padded to 4 bytes, a last attribute may lack its padding
+		next := 4 + (attrLength+3)&^3
+		if next > len(attrs) {
+			break
+		}
+		attrs = attrs[next:]
+	}
+	if mapped == nil {
+		return nil, errors.New("no mapped address in STUN response")
+	}
+	return mapped, nil
+}
+
+// parseSTUNAddress decodes an address attribute, XOR-ed with the magic cookie and the transaction ID if given.
+func parseSTUNAddress(value []byte, xorKey []byte) net.IP {
+	if len(value) < 4 {
+		return nil
+	}
+	var size int
+	switch value[1] {
+	case 0x01:
+		size = net.IPv4len
+	case 0x02:
+		size = net.IPv6len
+	default:
+		return nil
+	}
+	if len(value) < 4+size {
+		return nil
+	}
+
+	ip := make(net.IP, size)
+	copy(ip, value[4:4+size])
+	if xorKey != nil {
+		for i := range ip {
+			ip[i] ^= xorKey[i]
+		}
+	}
+	return ip
+}
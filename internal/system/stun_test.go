@@ -0,0 +1,265 @@
+package system
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// stunServer answers the Binding requests received on a local UDP socket with respond.
+func stunServer(t *testing.T, network string, address string, respond func(request []byte) []byte) (string, *atomic.Int32) {
+	t.Helper()
+	conn, err := net.ListenPacket(network, address)
+	if err != nil {
+		t.Skipf("cannot listen on %s %s: %v", network, address, err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	requests := new(atomic.Int32)
+	go func() {
+		buffer := make([]byte, 1500)
+		for {
+			n, peer, err := conn.ReadFrom(buffer)
+			if err != nil {
+				return
+			}
+			requests.Add(1)
+			if response := respond(buffer[:n]); response != nil {
+				conn.WriteTo(response, peer)
+			}
+		}
+	}()
+	return conn.LocalAddr().String(), requests
+}
+
+// bindingSuccess builds a Binding success response to the request with an address attribute.
+func bindingSuccess(request []byte, attrType uint16, ip net.IP) []byte {
+	family, raw := byte(0x01), ip.To4()
+	if raw == nil {
+		family, raw = 0x02, ip.To16()
+	}
+	value := append([]byte{0, family, 0, 0}, raw...)
+	if attrType == stunAttrXorMappedAddress {
+		for i := range raw {
+			value[4+i] ^= request[4+i]
+		}
+	}
+
+	response := make([]byte, stunHeaderSize, stunHeaderSize+4+len(value))
+	binary.BigEndian.PutUint16(response[0:2], stunBindingSuccess)
+	binary.BigEndian.PutUint16(response[2:4], uint16(4+len(value)))
+	copy(response[4:20], request[4:20])
+	response = binary.BigEndian.AppendUint16(response, attrType)
+	response = binary.BigEndian.AppendUint16(response, uint16(len(value)))
+	return append(response, value...)
+}
+
+func TestSTUNSourceDiscover(t *testing.T) {
+	tests := []struct {
+		name     string
+		network  string
+		listen   string
+		attrType uint16
+		mapped   string
+	}{
+		{"xor-mapped ipv4", "ip4", "127.0.0.1:0", stunAttrXorMappedAddress, "203.0.113.7"},
+		{"xor-mapped ipv6", "ip6", "[::1]:0", stunAttrXorMappedAddress, "2001:db8::7"},
+		{"mapped ipv4", "ip4", "127.0.0.1:0", stunAttrMappedAddress, "198.51.100.1"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			udpNetwork := "udp4"
+			if test.network == "ip6" {
+				udpNetwork = "udp6"
+			}
+			server, _ := stunServer(t, udpNetwork, test.listen, func(request []byte) []byte {
+				if binary.BigEndian.Uint16(request[0:2]) != stunBindingRequest ||
+					binary.BigEndian.Uint32(request[4:8]) != stunMagicCookie {
+					return nil
+				}
+				return bindingSuccess(request, test.attrType, net.ParseIP(test.mapped))
+			})
+
+			source := &STUNSource{Server: server, Timeout: time.Second}
+			ip, err := source.Discover(test.network)
+			if err != nil {
+				t.Fatalf("Discover() error = %v", err)
+			}
+			if !ip.Equal(net.ParseIP(test.mapped)) {
+				t.Errorf("Discover() = %s, want %s", ip, test.mapped)
+			}
+		})
+	}
+}
+
+func TestSTUNSourceWrongTransactionID(t *testing.T) {
+	server, _ := stunServer(t, "udp4", "127.0.0.1:0", func(request []byte) []byte {
+		response := bindingSuccess(request, stunAttrXorMappedAddress, net.ParseIP("203.0.113.7"))
+		response[19] ^= 0xff
+		return response
+	})
+
+	source := &STUNSource{Server: server, Timeout: time.Second}
+	if ip, err := source.Discover("ip4"); err == nil {
+		t.Errorf("Discover() = %s, want an error for a response to another transaction", ip)
+	}
+}
+
+func TestSTUNSourceRetries(t *testing.T) {
+	// Every request is lost
+	server, requests := stunServer(t, "udp4", "127.0.0.1:0", func(request []byte) []byte {
+		return nil
+	})
+
+	source := &STUNSource{Server: server, Timeout: 50 * time.Millisecond}
+	if _, err := source.Discover("ip4"); err == nil {
+		t.Fatal("Discover() succeeded without any response")
+	}
+	if n := requests.Load(); n != 3 {
+		t.Errorf("Discover() sent %d requests, want 3", n)
+	}
+}
+
+// staticSource is an IPSource answering a fixed IP or error.
+type staticSource struct {
+	ip  string
+	err error
+}
+
+func (s *staticSource) Name() string {
+	return "static:" + s.ip
+}
+
+func (s *staticSource) Discover(network string) (net.IP, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	return net.ParseIP(s.ip), nil
+}
+
+func TestDiscoverPublicIPConsensus(t *testing.T) {
+	failing := &staticSource{err: errors.New("unreachable")}
+	tests := []struct {
+		name      string
+		network   string
+		sources   []IPSource
+		consensus int
+		want      string
+		wantErr   bool
+	}{
+		{
+			name:      "first answer without consensus",
+			network:   "ip4",
+			sources:   []IPSource{&staticSource{ip: "203.0.113.1"}, &staticSource{ip: "203.0.113.2"}},
+			consensus: 1,
+			want:      "203.0.113.1",
+		},
+		{
+			name:      "agreeing sources",
+			network:   "ip4",
+			sources:   []IPSource{&staticSource{ip: "203.0.113.1"}, &staticSource{ip: "203.0.113.2"}, &staticSource{ip: "203.0.113.2"}},
+			consensus: 2,
+			want:      "203.0.113.2",
+		},
+		{
+			name:      "failing sources are skipped",
+			network:   "ip4",
+			sources:   []IPSource{failing, &staticSource{ip: "203.0.113.1"}, failing, &staticSource{ip: "203.0.113.1"}},
+			consensus: 2,
+			want:      "203.0.113.1",
+		},
+		{
+			name:      "answers of the other family are ignored",
+			network:   "ip6",
+			sources:   []IPSource{&staticSource{ip: "203.0.113.1"}, &staticSource{ip: "2001:db8::1"}},
+			consensus: 1,
+			want:      "2001:db8::1",
+		},
+		{
+			name:      "most frequent answer without consensus",
+			network:   "ip4",
+			sources:   []IPSource{&staticSource{ip: "203.0.113.1"}, &staticSource{ip: "203.0.113.2"}, &staticSource{ip: "203.0.113.2"}},
+			consensus: 3,
+			want:      "203.0.113.2",
+		},
+		{
+			name:      "no answer",
+			network:   "ip4",
+			sources:   []IPSource{failing, failing},
+			consensus: 2,
+			wantErr:   true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ip, err := DiscoverPublicIP(test.sources, test.network, test.consensus)
+			if test.wantErr {
+				if err == nil {
+					t.Errorf("DiscoverPublicIP() = %s, want an error", ip)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("DiscoverPublicIP() error = %v", err)
+			}
+			if !ip.Equal(net.ParseIP(test.want)) {
+				t.Errorf("DiscoverPublicIP() = %s, want %s", ip, test.want)
+			}
+		})
+	}
+}
+
+func TestParseSTUNResponseUnpaddedAttribute(t *testing.T) {
+	transactionID := []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12}
+	request := make([]byte, stunHeaderSize)
+	binary.BigEndian.PutUint32(request[4:8], stunMagicCookie)
+	copy(request[8:20], transactionID)
+
+	// appendAttribute adds an attribute without its padding and updates the length of the message.
+	appendAttribute := func(response []byte, attrType uint16, value []byte) []byte {
+		response = binary.BigEndian.AppendUint16(response, attrType)
+		response = binary.BigEndian.AppendUint16(response, uint16(len(value)))
+		response = append(response, value...)
+		binary.BigEndian.PutUint16(response[2:4], uint16(len(response)-stunHeaderSize))
+		return response
+	}
+	tests := []struct {
+		name     string
+		response []byte
+		want     string
+	}{
+		{
+			name:     "mapped address followed by an unpadded attribute",
+			response: appendAttribute(bindingSuccess(request, stunAttrMappedAddress, net.ParseIP("198.51.100.1")), 0x8022, []byte("fwg")),
+			want:     "198.51.100.1",
+		},
+		{
+			name:     "unpadded attribute followed by nothing",
+			response: appendAttribute(bindingSuccess(request, stunAttrMappedAddress, net.ParseIP("198.51.100.1"))[:stunHeaderSize], 0x8022, []byte("fwg-s")),
+		},
+		{
+			name:     "unpadded address attribute",
+			response: appendAttribute(bindingSuccess(request, stunAttrMappedAddress, net.ParseIP("198.51.100.1"))[:stunHeaderSize], stunAttrMappedAddress, []byte{0, 1, 0, 0, 198, 51, 100}),
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ip, err := parseSTUNResponse(test.response, transactionID)
+			if test.want == "" {
+				if err == nil {
+					t.Errorf("parseSTUNResponse() = %s, want an error", ip)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseSTUNResponse() error = %v", err)
+			}
+			if !ip.Equal(net.ParseIP(test.want)) {
+				t.Errorf("parseSTUNResponse() = %s, want %s", ip, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<!-- Auto-generated by fast-wireguard: configuration profile of the WireGuard app -->
+<plist version="1.0">
+<dict>
+	<key>PayloadDisplayName</key>
+	<string>{{ xml .DisplayName }}</string>
+	<key>PayloadType</key>
+	<string>Configuration</string>
+	<key>PayloadVersion</key>
+	<integer>1</integer>
+	<key>PayloadIdentifier</key>
+	<string>{{ xml .Identifier }}</string>
+	<key>PayloadUUID</key>
+	<string>{{ .ProfileUUID }}</string>
+	<key>PayloadContent</key>
+	<array>
+		<dict>
+			<key>PayloadDisplayName</key>
+			<string>VPN</string>
+			<key>PayloadType</key>
+			<string>com.apple.vpn.managed</string>
+			<key>PayloadVersion</key>
+			<integer>1</integer>
+			<key>PayloadIdentifier</key>
+			<string>{{ xml .Identifier }}.vpn</string>
+			<key>PayloadUUID</key>
+			<string>{{ .UUID }}</string>
+			<key>UserDefinedName</key>
+			<string>{{ xml .DisplayName }}</string>
+			<key>VPNType</key>
+			<string>VPN</string>
+			<key>VPNSubType</key>
+			<string>{{ .VPNSubType }}</string>
+			<key>VendorConfig</key>
+			<dict>
+				<key>WgQuickConfig</key>
+				<string>{{ xml .WgQuickConfig }}</string>
+			</dict>
+			<key>VPN</key>
+			<dict>
+				<key>RemoteAddress</key>
+				<string>{{ xml .Endpoint }}</string>
+				<key>AuthenticationMethod</key>
+				<string>Password</string>
+				{{- if .OnDemandRules }}
+				<key>OnDemandEnabled</key>
+				<integer>1</integer>
+				<key>OnDemandRules</key>
+				<array>
+					{{- range .OnDemandRules }}
+					<dict>
+						<key>Action</key>
+						<string>{{ .Action }}</string>
+						{{- if .InterfaceTypeMatch }}
+						<key>InterfaceTypeMatch</key>
+						<string>{{ .InterfaceTypeMatch }}</string>
+						{{- end }}
+						{{- if .SSIDMatch }}
+						<key>SSIDMatch</key>
+						<array>
+							{{- range .SSIDMatch }}
+							<string>{{ xml . }}</string>
+							{{- end }}
+						</array>
+						{{- end }}
+					</dict>
+					{{- end }}
+				</array>
+				{{- end }}
+			</dict>
+		</dict>
+	</array>
+</dict>
+</plist>
@@ -1,11 +1,30 @@
 [Interface]
 PrivateKey = {{ .PriKeyClient }}
 Address = {{ .AllowedIPs }}
-DNS = 8.8.8.8, 1.1.1.1
+{{- if .DNS }}
+DNS = {{ .DNS }}
+{{- end }}
 MTU = {{ .MTU }}
+{{- if .Subnets }}
+
+# Site-to-site router of {{ .Subnets }}: forward the traffic between the LAN and the
+# tunnel, and let the hosts of the LAN reach {{ .RoutedIPs }} through this router
+# (e.g. as their default gateway or with a static route on the gateway of the LAN).
+PostUp = sysctl -q -e -w net.ipv4.ip_forward=1 net.ipv6.conf.all.forwarding=1
+PostUp = iptables -A FORWARD -i %i -j ACCEPT
+PostUp = iptables -A FORWARD -o %i -j ACCEPT
+PostDown = iptables -D FORWARD -i %i -j ACCEPT
+PostDown = iptables -D FORWARD -o %i -j ACCEPT
+{{- if .SiteIPv6 }}
+PostUp = ip6tables -A FORWARD -i %i -j ACCEPT
+PostUp = ip6tables -A FORWARD -o %i -j ACCEPT
+PostDown = ip6tables -D FORWARD -i %i -j ACCEPT
+PostDown = ip6tables -D FORWARD -o %i -j ACCEPT
+{{- end }}
+{{- end }}
 
 [Peer]
 PublicKey = {{ .PubKeyServer }}
-AllowedIPs = 0.0.0.0/0, ::/0
+AllowedIPs = {{ .RoutedIPs }}
 Endpoint = {{ .Endpoint }}
 PersistentKeepalive = 25
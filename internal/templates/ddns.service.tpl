@@ -0,0 +1,13 @@
+# Auto-generated by fast-wireguard
+[Unit]
+Description=Fast-WireGuard dynamic DNS updater for %i
+After=network-online.target wg-quick@%i.service
+Wants=network-online.target
+
+[Service]
+ExecStart={{ .Executable }} ddns run %i
+Restart=on-failure
+RestartSec=30
+
+[Install]
+WantedBy=multi-user.target
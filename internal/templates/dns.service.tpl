@@ -0,0 +1,13 @@
+# Auto-generated by fast-wireguard
+[Unit]
+Description=Fast-WireGuard DNS service for %i
+After=wg-quick@%i.service
+BindsTo=wg-quick@%i.service
+
+[Service]
+ExecStart={{ .Executable }} dns serve %i
+Restart=on-failure
+RestartSec=3
+
+[Install]
+WantedBy=wg-quick@%i.service
@@ -15,4 +15,37 @@ var (
 	// WireGuard client configuration file template
 	//go:embed client.conf.tpl
 	ClientConfTpl string
+	// Systemd unit of the fwg DNS service
+	//go:embed dns.service.tpl
+	DNSServiceTpl string
+	// Systemd unit of the fwg dynamic DNS updater
+	//go:embed ddns.service.tpl
+	DDNSServiceTpl string
+	// Systemd unit of the fwg port mapping renewal
+	//go:embed portmap.service.tpl
+	PortMapServiceTpl string
+	// WireGuard configuration file of a node of a mesh
+	//go:embed mesh.conf.tpl
+	MeshConfTpl string
+	// Install script of the export bundle of a mesh node
+	//go:embed mesh-install.sh.tpl
+	MeshInstallTpl string
+	// NetworkManager keyfile of a client
+	//go:embed networkmanager.nmconnection.tpl
+	NetworkManagerTpl string
+	// systemd-networkd netdev of a client
+	//go:embed networkd.netdev.tpl
+	NetworkdNetdevTpl string
+	// systemd-networkd network of a client
+	//go:embed networkd.network.tpl
+	NetworkdNetworkTpl string
+	// OpenWrt UCI script of a client
+	//go:embed openwrt.uci.tpl
+	OpenWrtTpl string
+	// MikroTik RouterOS script of a client
+	//go:embed routeros.rsc.tpl
+	RouterOSTpl string
+	// Apple configuration profile of a client
+	//go:embed apple.mobileconfig.tpl
+	MobileConfigTpl string
 )
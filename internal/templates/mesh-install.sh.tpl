@@ -0,0 +1,8 @@
+#!/bin/sh
+# Auto-generated by fast-wireguard: install the node {{ .NodeName }} of the mesh {{ .MeshName }}
+set -e
+cd "$(dirname "$0")"
+install -m 600 {{ .MeshName }}.conf /etc/wireguard/{{ .MeshName }}.conf
+systemctl enable wg-quick@{{ .MeshName }}
+systemctl restart wg-quick@{{ .MeshName }}
+echo "Node {{ .NodeName }} of the mesh {{ .MeshName }} is up."
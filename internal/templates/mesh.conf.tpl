@@ -0,0 +1,19 @@
+# Auto-generated by fast-wireguard (mesh {{ .MeshName }}, node {{ .NodeName }})
+[Interface]
+PrivateKey = {{ .PrivateKey }}
+Address = {{ .Address }}
+ListenPort = {{ .ListenPort }}
+{{- if .MTU }}
+MTU = {{ .MTU }}
+{{- end }}
+{{- range .Peers }}
+
+[Peer]
+# Node {{ .Name }}
+PublicKey = {{ .PublicKey }}
+AllowedIPs = {{ .AllowedIPs }}
+{{- if .Endpoint }}
+Endpoint = {{ .Endpoint }}
+{{- end }}
+PersistentKeepalive = 25
+{{- end }}
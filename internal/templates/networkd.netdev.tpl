@@ -0,0 +1,20 @@
+# Auto-generated by fast-wireguard: copy to /etc/systemd/network/ with {{ .InterfaceName }}.network
+# and {{ .InterfaceName }}.key, then run "networkctl reload".
+[NetDev]
+Name={{ .InterfaceName }}
+Kind=wireguard
+MTUBytes={{ .MTU }}
+Description=WireGuard tunnel of {{ .PeerName }}
+
+[WireGuard]
+PrivateKeyFile=/etc/systemd/network/{{ .InterfaceName }}.key
+{{- if .FullTunnel }}
+# The encrypted packets are marked so they do not enter the tunnel again
+FirewallMark={{ .Table }}
+{{- end }}
+
+[WireGuardPeer]
+PublicKey={{ .PubKeyServer }}
+Endpoint={{ .Endpoint }}
+AllowedIPs={{ join .Routes "," }}
+PersistentKeepalive=25
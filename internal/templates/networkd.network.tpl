@@ -0,0 +1,45 @@
+# Auto-generated by fast-wireguard: the network of {{ .InterfaceName }}.netdev
+[Match]
+Name={{ .InterfaceName }}
+
+[Network]
+{{- range .IPv4Addresses }}
+Address={{ . }}
+{{- end }}
+{{- range .IPv6Addresses }}
+Address={{ . }}
+{{- end }}
+{{- range .IPv4DNS }}
+DNS={{ . }}
+{{- end }}
+{{- range .IPv6DNS }}
+DNS={{ . }}
+{{- end }}
+{{- if or .SearchDomains (and .FullTunnel (or .IPv4DNS .IPv6DNS)) }}
+Domains={{ if .FullTunnel }}~. {{ end }}{{ join .SearchDomains " " }}
+{{- end }}
+{{- range .Routes }}
+
+[Route]
+Destination={{ . }}
+{{- if $.FullTunnel }}
+Table={{ $.Table }}
+{{- end }}
+{{- end }}
+{{- if .FullTunnel }}
+
+# Like wg-quick: the default routes of the main table are ignored, and the packets
+# not marked by the tunnel use its table
+[RoutingPolicyRule]
+Table=main
+SuppressPrefixLength=0
+Family={{ .RuleFamily }}
+Priority=10
+
+[RoutingPolicyRule]
+FirewallMark={{ .Table }}
+InvertRule=yes
+Table={{ .Table }}
+Family={{ .RuleFamily }}
+Priority=11
+{{- end }}
@@ -0,0 +1,60 @@
+# Auto-generated by fast-wireguard: copy to /etc/NetworkManager/system-connections/
+# (owned by root, mode 600) and run "nmcli connection reload".
+[connection]
+id={{ .InterfaceName }}-{{ .PeerName }}
+uuid={{ .UUID }}
+type=wireguard
+interface-name={{ .InterfaceName }}
+autoconnect=false
+
+[wireguard]
+private-key={{ .PriKeyClient }}
+private-key-flags=0
+mtu={{ .MTU }}
+# The routes of allowed-ips are added by NetworkManager, a default route in a routing table of its own
+peer-routes=true
+
+[wireguard-peer.{{ .PubKeyServer }}]
+endpoint={{ .Endpoint }}
+persistent-keepalive=25
+allowed-ips={{ range .IPv4Routes }}{{ . }};{{ end }}{{ range .IPv6Routes }}{{ . }};{{ end }}
+
+[ipv4]
+{{- if .IPv4Addresses }}
+method=manual
+{{- range $i, $address := .IPv4Addresses }}
+address{{ add1 $i }}={{ $address }}
+{{- end }}
+{{- if .IPv4DNS }}
+dns={{ range .IPv4DNS }}{{ . }};{{ end }}
+{{- end }}
+{{- if .SearchDomains }}
+dns-search={{ range .SearchDomains }}{{ . }};{{ end }}
+{{- end }}
+{{- if .DNS }}
+# Prefer the DNS servers of the tunnel over the ones of the other connections
+dns-priority=-50
+{{- end }}
+{{- else }}
+method=disabled
+{{- end }}
+
+[ipv6]
+addr-gen-mode=default
+{{- if .IPv6Addresses }}
+method=manual
+{{- range $i, $address := .IPv6Addresses }}
+address{{ add1 $i }}={{ $address }}
+{{- end }}
+{{- if .IPv6DNS }}
+dns={{ range .IPv6DNS }}{{ . }};{{ end }}
+{{- end }}
+{{- if and .SearchDomains (not .IPv4Addresses) }}
+dns-search={{ range .SearchDomains }}{{ . }};{{ end }}
+{{- end }}
+{{- if .DNS }}
+dns-priority=-50
+{{- end }}
+{{- else }}
+method=disabled
+{{- end }}
@@ -0,0 +1,75 @@
+#!/bin/sh
+# Auto-generated by fast-wireguard: run on the OpenWrt router of {{ .PeerName }}
+# (needs the packages kmod-wireguard, wireguard-tools and luci-proto-wireguard).
+set -e
+
+# --- Interface {{ .Section }} ---
+uci -q delete network.{{ .Section }} || true
+uci set network.{{ .Section }}=interface
+uci set network.{{ .Section }}.proto='wireguard'
+uci set network.{{ .Section }}.private_key={{ quote .PriKeyClient }}
+uci set network.{{ .Section }}.mtu='{{ .MTU }}'
+{{- range .IPv4Addresses }}
+uci add_list network.{{ $.Section }}.addresses='{{ . }}'
+{{- end }}
+{{- range .IPv6Addresses }}
+uci add_list network.{{ $.Section }}.addresses='{{ . }}'
+{{- end }}
+{{- range .IPv4DNS }}
+uci add_list network.{{ $.Section }}.dns='{{ . }}'
+{{- end }}
+{{- range .IPv6DNS }}
+uci add_list network.{{ $.Section }}.dns='{{ . }}'
+{{- end }}
+{{- range .SearchDomains }}
+uci add_list network.{{ $.Section }}.dns_search={{ quote . }}
+{{- end }}
+
+# --- Peer: the fwg server, the allowed IPs are routed through the tunnel ---
+uci -q delete network.{{ .Section }}_server || true
+uci set network.{{ .Section }}_server=wireguard_{{ .Section }}
+uci set network.{{ .Section }}_server.description='fwg {{ .InterfaceName }}'
+uci set network.{{ .Section }}_server.public_key={{ quote .PubKeyServer }}
+uci set network.{{ .Section }}_server.endpoint_host={{ quote .EndpointHost }}
+uci set network.{{ .Section }}_server.endpoint_port='{{ .EndpointPort }}'
+uci set network.{{ .Section }}_server.persistent_keepalive='25'
+uci set network.{{ .Section }}_server.route_allowed_ips='1'
+{{- range .IPv4Routes }}
+uci add_list network.{{ $.Section }}_server.allowed_ips='{{ . }}'
+{{- end }}
+{{- range .IPv6Routes }}
+uci add_list network.{{ $.Section }}_server.allowed_ips='{{ . }}'
+{{- end }}
+
+# --- Firewall zone {{ .Section }} ---
+uci -q delete firewall.{{ .Section }} || true
+uci set firewall.{{ .Section }}=zone
+uci set firewall.{{ .Section }}.name='{{ .Section }}'
+uci set firewall.{{ .Section }}.input='REJECT'
+uci set firewall.{{ .Section }}.output='ACCEPT'
+uci set firewall.{{ .Section }}.forward='REJECT'
+{{- if .Subnets }}
+# Site-to-site: the LAN {{ .Subnets }} is routed by the server, without NAT
+uci set firewall.{{ .Section }}.masq='0'
+{{- else }}
+# The hosts of the LAN share the address of the router in the tunnel
+uci set firewall.{{ .Section }}.masq='1'
+{{- end }}
+uci set firewall.{{ .Section }}.mtu_fix='1'
+uci add_list firewall.{{ .Section }}.network='{{ .Section }}'
+uci -q delete firewall.lan_{{ .Section }} || true
+uci set firewall.lan_{{ .Section }}=forwarding
+uci set firewall.lan_{{ .Section }}.src='lan'
+uci set firewall.lan_{{ .Section }}.dest='{{ .Section }}'
+{{- if .Subnets }}
+uci -q delete firewall.{{ .Section }}_lan || true
+uci set firewall.{{ .Section }}_lan=forwarding
+uci set firewall.{{ .Section }}_lan.src='{{ .Section }}'
+uci set firewall.{{ .Section }}_lan.dest='lan'
+{{- end }}
+
+uci commit network
+uci commit firewall
+/etc/init.d/network reload
+/etc/init.d/firewall reload
+echo "Interface {{ .Section }} configured."
@@ -0,0 +1,13 @@
+# Auto-generated by fast-wireguard
+[Unit]
+Description=Fast-WireGuard port mapping on the gateway for %i
+After=network-online.target wg-quick@%i.service
+Wants=network-online.target
+
+[Service]
+ExecStart={{ .Executable }} portmap run %i
+Restart=on-failure
+RestartSec=30
+
+[Install]
+WantedBy=multi-user.target
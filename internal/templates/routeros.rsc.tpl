@@ -0,0 +1,72 @@
+# Auto-generated by fast-wireguard: RouterOS 7 script of the peer {{ ros .PeerName }},
+# upload it to the router and run "/import file-name={{ .FileName }}".
+/interface wireguard
+add name={{ .InterfaceName }} mtu={{ .MTU }} private-key={{ ros .PriKeyClient }} comment="fwg {{ .InterfaceName }}"
+/interface wireguard peers
+add interface={{ .InterfaceName }} public-key={{ ros .PubKeyServer }} endpoint-address={{ ros .EndpointHost }} endpoint-port={{ .EndpointPort }} allowed-address={{ join .AllowedList "," }} persistent-keepalive=25s comment="fwg server"
+{{- if .IPv4Addresses }}
+/ip address
+{{- range .IPv4Addresses }}
+add address={{ . }} interface={{ $.InterfaceName }} comment="fwg {{ $.InterfaceName }}"
+{{- end }}
+{{- end }}
+{{- if .IPv6Addresses }}
+/ipv6 address
+{{- range .IPv6Addresses }}
+add address={{ . }} interface={{ $.InterfaceName }} advertise=no comment="fwg {{ $.InterfaceName }}"
+{{- end }}
+{{- end }}
+{{- if .FullTunnel }}
+
+# The default routes of the tunnel are in the routing table {{ .Table }}, used by the traffic of the
+# LAN interface list, so the router itself keeps reaching the endpoint through its uplink
+/routing table
+add name={{ .Table }} fib comment="fwg {{ .InterfaceName }}"
+{{- end }}
+{{- if .IPv4Routes }}
+/ip route
+{{- range .IPv4Routes }}
+add dst-address={{ . }} gateway={{ $.InterfaceName }}{{ if eq . "0.0.0.0/0" }} routing-table={{ $.Table }}{{ end }} comment="fwg {{ $.InterfaceName }}"
+{{- end }}
+{{- end }}
+{{- if .IPv6Routes }}
+/ipv6 route
+{{- range .IPv6Routes }}
+add dst-address={{ . }} gateway={{ $.InterfaceName }}{{ if eq . "::/0" }} routing-table={{ $.Table }}{{ end }} comment="fwg {{ $.InterfaceName }}"
+{{- end }}
+{{- end }}
+{{- if .FullTunnel }}
+
+# The traffic between the LANs, to the private networks and to the connected networks keeps the main table
+/ip firewall address-list
+{{- range .IPv4Bypass }}
+add list={{ $.Table }}-bypass address={{ . }} comment="fwg {{ $.InterfaceName }}"
+{{- end }}
+:foreach route in=[/ip route find where connect] do={ :do { /ip firewall address-list add list={{ .Table }}-bypass address=[/ip route get $route dst-address] comment="fwg {{ .InterfaceName }}" } on-error={} }
+/ip firewall mangle
+add chain=prerouting in-interface-list=LAN dst-address-type=!local dst-address-list=!{{ .Table }}-bypass action=mark-routing new-routing-mark={{ .Table }} passthrough=no comment="fwg {{ .InterfaceName }}"
+{{- if .IPv6Routes }}
+/ipv6 firewall address-list
+{{- range .IPv6Bypass }}
+add list={{ $.Table }}-bypass address={{ . }} comment="fwg {{ $.InterfaceName }}"
+{{- end }}
+:foreach route in=[/ipv6 route find where connect] do={ :do { /ipv6 firewall address-list add list={{ .Table }}-bypass address=[/ipv6 route get $route dst-address] comment="fwg {{ .InterfaceName }}" } on-error={} }
+/ipv6 firewall mangle
+add chain=prerouting in-interface-list=LAN dst-address-type=!local dst-address-list=!{{ .Table }}-bypass action=mark-routing new-routing-mark={{ .Table }} passthrough=no comment="fwg {{ .InterfaceName }}"
+{{- end }}
+{{- end }}
+{{- if not .Subnets }}
+
+# The hosts of the LAN share the address of the router in the tunnel
+/ip firewall nat
+add chain=srcnat out-interface={{ .InterfaceName }} action=masquerade comment="fwg {{ .InterfaceName }}"
+{{- if .IPv6Addresses }}
+/ipv6 firewall nat
+add chain=srcnat out-interface={{ .InterfaceName }} action=masquerade comment="fwg {{ .InterfaceName }}"
+{{- end }}
+{{- end }}
+{{- if or .IPv4DNS .IPv6DNS }}
+
+# DNS servers of the tunnel, they replace the ones of the router:
+# /ip dns set servers={{ join .DNSList "," }}
+{{- end }}
@@ -6,14 +6,57 @@ PrivateKey = {{ .PriKeyServer }}
 MTU = {{ .MTU }}
 
 # --- Core Network Forwarding Rules (iptables) ---
+{{- if .Family.HasIPv4 }}
+PostUp = iptables -A FORWARD -i %i -o %i -j {{ peerToPeerTarget .PeerToPeer }}
 PostUp = iptables -A FORWARD -i %i -j ACCEPT
 PostUp = iptables -t nat -A POSTROUTING -o {{ .PhysicalInterface }} -j MASQUERADE
+{{- end }}
+{{- if .Family.HasIPv6 }}
+PostUp = ip6tables -A FORWARD -i %i -o %i -j {{ peerToPeerTarget .PeerToPeer }}
 PostUp = ip6tables -A FORWARD -i %i -j ACCEPT
+{{- if not .RoutedIPv6 }}
 PostUp = ip6tables -t nat -A POSTROUTING -o {{ .PhysicalInterface }} -j MASQUERADE
+{{- end }}
+{{- end }}
+{{- if .Family.HasIPv4 }}
+PostDown = iptables -D FORWARD -i %i -o %i -j {{ peerToPeerTarget .PeerToPeer }}
 PostDown = iptables -D FORWARD -i %i -j ACCEPT
 PostDown = iptables -t nat -D POSTROUTING -o {{ .PhysicalInterface }} -j MASQUERADE
+{{- end }}
+{{- if .Family.HasIPv6 }}
+PostDown = ip6tables -D FORWARD -i %i -o %i -j {{ peerToPeerTarget .PeerToPeer }}
 PostDown = ip6tables -D FORWARD -i %i -j ACCEPT
+{{- if not .RoutedIPv6 }}
 PostDown = ip6tables -t nat -D POSTROUTING -o {{ .PhysicalInterface }} -j MASQUERADE
+{{- end }}
+{{- end }}
+{{- if .RoutedIPv6 }}
+
+# --- Routed IPv6 (no NAT66) ---
+# Keep the default route learnt from the router advertisements while forwarding, and restore the setting on down
+PostUp = sysctl -n net.ipv6.conf.{{ .PhysicalInterface }}.accept_ra > /run/fwg-%i.accept_ra; sysctl -q -w net.ipv6.conf.{{ .PhysicalInterface }}.accept_ra=2
+PostDown = sysctl -q -w net.ipv6.conf.{{ .PhysicalInterface }}.accept_ra=$(cat /run/fwg-%i.accept_ra 2>/dev/null || echo 1); rm -f /run/fwg-%i.accept_ra
+{{- if .NDPProxy }}
+PostUp = sysctl -n net.ipv6.conf.{{ .PhysicalInterface }}.proxy_ndp > /run/fwg-%i.proxy_ndp; sysctl -q -w net.ipv6.conf.{{ .PhysicalInterface }}.proxy_ndp=1
+PostDown = sysctl -q -w net.ipv6.conf.{{ .PhysicalInterface }}.proxy_ndp=$(cat /run/fwg-%i.proxy_ndp 2>/dev/null || echo 0); rm -f /run/fwg-%i.proxy_ndp
+{{- range .NDPProxies }}
+PostUp = ip -6 neigh add proxy {{ . }} dev {{ $.PhysicalInterface }}
+PostDown = ip -6 neigh del proxy {{ . }} dev {{ $.PhysicalInterface }}
+{{- end }}
+{{- end }}
+{{- end }}
+{{- if .Forwards }}
+
+# --- Port Forwarding Rules (fwg forward) ---
+{{- range .Forwards }}
+{{- range .Commands "-A" }}
+PostUp = {{ . }}
+{{- end }}
+{{- range .Commands "-D" }}
+PostDown = {{ . }}
+{{- end }}
+{{- end }}
+{{- end }}
 
 # ------------------------------------------------------
 # Client list
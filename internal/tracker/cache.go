@@ -0,0 +1,49 @@
+package tracker
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+/*
+CachedIP is a public IP discovered at the given time.
+*/
+type CachedIP struct {
+	IP        string    `json:"ip"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+/*
+LoadPublicIPCache reads the discovered public IPs, indexed by network ("ip4" or "ip6").
+
+Returns an empty cache if it cannot be read.
+*/
+func LoadPublicIPCache() map[string]CachedIP {
+	cache := make(map[string]CachedIP)
+	content, err := os.ReadFile(publicIPCachePath())
+	if err != nil {
+		return cache
+	}
+	if err := json.Unmarshal(content, &cache); err != nil {
+		return make(map[string]CachedIP)
+	}
+	return cache
+}
+
+/*
+SavePublicIPCache writes the discovered public IPs.
+*/
+func SavePublicIPCache(cache map[string]CachedIP) error {
+	content, err := json.MarshalIndent(cache, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(publicIPCachePath(), append(content, '\n'), 0644)
+}
+
+// publicIPCachePath returns the path of the cache of the discovered public IPs.
+func publicIPCachePath() string {
+	return filepath.Join(SettingsDir, ".fwg_public_ip.json")
+}
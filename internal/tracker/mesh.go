@@ -0,0 +1,89 @@
+package tracker
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+/*
+MeshSettings describes a full-mesh network: every node has all the other nodes as peers.
+
+The keys of the nodes are kept, so regenerating the configurations does not change them.
+*/
+type MeshSettings struct {
+	Name       string      `json:"name"`
+	Network    string      `json:"network"`
+	ListenPort int         `json:"listen_port"`
+	MTU        int         `json:"mtu,omitempty"`
+	Nodes      []*MeshNode `json:"nodes"`
+}
+
+/*
+MeshNode is one node of a mesh, Endpoint is empty if the node cannot be reached (e.g. behind NAT).
+*/
+type MeshNode struct {
+	Name       string `json:"name"`
+	Endpoint   string `json:"endpoint,omitempty"`
+	Address    string `json:"address"`
+	PrivateKey string `json:"private_key"`
+	PublicKey  string `json:"public_key"`
+}
+
+// meshPath returns the path of the settings file of the given mesh.
+func meshPath(meshName string) string {
+	return filepath.Join(SettingsDir, fmt.Sprintf(".fwg_mesh_%s.json", meshName))
+}
+
+/*
+LoadMeshSettings reads the settings of the given mesh.
+*/
+func LoadMeshSettings(meshName string) (*MeshSettings, error) {
+	content, err := os.ReadFile(meshPath(meshName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("the mesh %s does not exist", meshName)
+		}
+		return nil, fmt.Errorf("Cannot read mesh %s: %w", meshName, err)
+	}
+	mesh := &MeshSettings{}
+	if err := json.Unmarshal(content, mesh); err != nil {
+		return nil, fmt.Errorf("Cannot parse mesh %s: %w", meshName, err)
+	}
+	return mesh, nil
+}
+
+/*
+MeshExists reports whether the given mesh has a settings file.
+*/
+func MeshExists(meshName string) bool {
+	_, err := os.Stat(meshPath(meshName))
+	return err == nil
+}
+
+/*
+SaveMeshSettings writes the settings of the given mesh.
+
+The file contains the private keys of all the nodes, so it is only readable by root.
+*/
+func SaveMeshSettings(mesh *MeshSettings) error {
+	content, err := json.MarshalIndent(mesh, "", "  ")
+	if err != nil {
+		return fmt.Errorf("Cannot encode mesh %s: %w", mesh.Name, err)
+	}
+	if err := os.WriteFile(meshPath(mesh.Name), append(content, '\n'), 0600); err != nil {
+		return fmt.Errorf("Cannot write mesh %s: %w", mesh.Name, err)
+	}
+	return nil
+}
+
+/*
+RemoveMeshSettings deletes the settings file of the given mesh.
+*/
+func RemoveMeshSettings(meshName string) error {
+	if err := os.Remove(meshPath(meshName)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("Cannot remove mesh %s: %w", meshName, err)
+	}
+	return nil
+}
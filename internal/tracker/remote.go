@@ -0,0 +1,74 @@
+package tracker
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+/*
+RemoteServer is an fwg server of another host (e.g. another region) for which client bundles are generated.
+
+Pool holds the networks of its tunnel reserved for the bundles, one address of each is given to a client.
+The remote server only learns the clients when their peers are added there.
+*/
+type RemoteServer struct {
+	Name      string                   `json:"name"`
+	PublicKey string                   `json:"public_key"`
+	Endpoint  string                   `json:"endpoint"`
+	Interface string                   `json:"interface"`
+	Pool      []string                 `json:"pool"`
+	DNS       []string                 `json:"dns,omitempty"`
+	MTU       int                      `json:"mtu"`
+	Clients   map[string]*RemoteClient `json:"clients,omitempty"`
+}
+
+/*
+RemoteClient is a user given access to a remote server, its keys and addresses are kept so the bundle
+can be generated again without touching the remote server.
+*/
+type RemoteClient struct {
+	Addresses  []string `json:"addresses"`
+	PrivateKey string   `json:"private_key"`
+	PublicKey  string   `json:"public_key"`
+}
+
+// remotesPath returns the path of the file of the remote servers.
+func remotesPath() string {
+	return filepath.Join(SettingsDir, ".fwg_remotes.json")
+}
+
+/*
+LoadRemoteServers reads the registered remote servers, none if the file does not exist.
+*/
+func LoadRemoteServers() ([]*RemoteServer, error) {
+	content, err := os.ReadFile(remotesPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("Cannot read remote servers: %w", err)
+	}
+	var servers []*RemoteServer
+	if err := json.Unmarshal(content, &servers); err != nil {
+		return nil, fmt.Errorf("Cannot parse remote servers: %w", err)
+	}
+	return servers, nil
+}
+
+/*
+SaveRemoteServers writes the registered remote servers.
+
+The file contains the private keys of the clients, so it is only readable by root.
+*/
+func SaveRemoteServers(servers []*RemoteServer) error {
+	content, err := json.MarshalIndent(servers, "", "  ")
+	if err != nil {
+		return fmt.Errorf("Cannot encode remote servers: %w", err)
+	}
+	if err := os.WriteFile(remotesPath(), append(content, '\n'), 0600); err != nil {
+		return fmt.Errorf("Cannot write remote servers: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+package tracker
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+var (
+	SettingsDir = "/etc/wireguard"
+)
+
+/*
+InterfaceSettings keeps the options of one managed interface that cannot be
+recovered from the WireGuard configuration file itself.
+*/
+type InterfaceSettings struct {
+	ListenPort        int                      `json:"listen_port"`
+	Address           string                   `json:"address"`
+	Family            string                   `json:"family,omitempty"`
+	IPv6              IPv6Settings             `json:"ipv6"`
+	MTU               int                      `json:"mtu"`
+	ClientMTU         int                      `json:"client_mtu,omitempty"`
+	PhysicalInterface string                   `json:"physical_interface"`
+	LANs              []string                 `json:"lans,omitempty"`
+	PeerToPeer        string                   `json:"peer_to_peer,omitempty"`
+	Endpoint          string                   `json:"endpoint,omitempty"`
+	PreferIPv6        bool                     `json:"prefer_ipv6,omitempty"`
+	Discovery         DiscoverySettings        `json:"discovery"`
+	DDNS              DDNSSettings             `json:"ddns"`
+	PortMapping       bool                     `json:"port_mapping,omitempty"`
+	DNS               DNSSettings              `json:"dns"`
+	Peers             map[string]*PeerSettings `json:"peers,omitempty"`
+	Forwards          []PortForward            `json:"forwards,omitempty"`
+}
+
+/*
+DNSSettings describes the resolvers pushed to clients and the optional DNS
+service run by fwg on the tunnel address, which forwards the queries to the
+upstream resolvers and answers the names of the peers in Zone.
+*/
+type DNSSettings struct {
+	Servers       []string `json:"servers,omitempty"`
+	SearchDomains []string `json:"search_domains,omitempty"`
+	Forwarder     bool     `json:"forwarder,omitempty"`
+	Upstreams     []string `json:"upstreams,omitempty"`
+	Zone          string   `json:"zone,omitempty"`
+}
+
+/*
+IPv6Settings configures how the IPv6 traffic of the peers reaches the Internet.
+
+Mode "nat" (or empty) masquerades the unique local addresses of the peers (NAT66). Mode "routed"
+gives the peers global addresses of Prefix, one /128 each or one prefix of DelegatedLength each,
+and answers the neighbor solicitations for them on the physical interface if NDPProxy is set.
+*/
+type IPv6Settings struct {
+	Mode            string `json:"mode,omitempty"`
+	Prefix          string `json:"prefix,omitempty"`
+	DelegatedLength int    `json:"delegated_length,omitempty"`
+	NDPProxy        bool   `json:"ndp_proxy,omitempty"`
+}
+
+/*
+DiscoverySettings configures how the public IP of the server is discovered.
+
+Empty lists mean the default STUN servers and HTTP providers, "none" disables them.
+*/
+type DiscoverySettings struct {
+	STUNServers     []string `json:"stun_servers,omitempty"`
+	HTTPProviders   []string `json:"http_providers,omitempty"`
+	Consensus       int      `json:"consensus,omitempty"`
+	Offline         bool     `json:"offline,omitempty"`
+	CacheTTLSeconds int      `json:"cache_ttl_seconds,omitempty"`
+}
+
+/*
+DDNSSettings configures the dynamic DNS updates of the name of the server.
+
+Provider is "rfc2136" (DNS UPDATE signed with TSIG), "http" (URL template) or empty if disabled.
+*/
+type DDNSSettings struct {
+	Provider        string `json:"provider,omitempty"`
+	Name            string `json:"name,omitempty"`
+	Server          string `json:"server,omitempty"`
+	Zone            string `json:"zone,omitempty"`
+	TSIGKeyName     string `json:"tsig_key_name,omitempty"`
+	TSIGSecret      string `json:"tsig_secret,omitempty"`
+	TSIGAlgorithm   string `json:"tsig_algorithm,omitempty"`
+	TTL             int    `json:"ttl,omitempty"`
+	URLTemplate     string `json:"url_template,omitempty"`
+	IntervalSeconds int    `json:"interval_seconds,omitempty"`
+}
+
+/*
+PeerSettings keeps the per-peer options, indexed by the public key of the peer.
+
+Subnets are the LANs behind a site-to-site peer (a router), routed to it by the server.
+Routes is the route profile of the other peers: "full" (or empty) sends all their traffic through
+the tunnel, "split" only the networks behind the server. Owner is a free-form note, e.g. a user or a team.
+*/
+type PeerSettings struct {
+	Name         string       `json:"name"`
+	PriKeyClient string       `json:"private_key,omitempty"`
+	DNS          *DNSSettings `json:"dns,omitempty"`
+	Subnets      []string     `json:"subnets,omitempty"`
+	Routes       string       `json:"routes,omitempty"`
+	Owner        string       `json:"owner,omitempty"`
+}
+
+/*
+PortForward publishes a port of a peer (given by its public key) on the physical interface of the server.
+*/
+type PortForward struct {
+	PubKeyClient string `json:"peer"`
+	Proto        string `json:"proto"`
+	PublicPort   int    `json:"public_port"`
+	PeerPort     int    `json:"peer_port"`
+}
+
+// settingsPath returns the path of the settings file of the given interface.
+func settingsPath(interfaceName string) string {
+	return filepath.Join(SettingsDir, fmt.Sprintf(".fwg_%s.json", interfaceName))
+}
+
+/*
+LoadInterfaceSettings reads the settings of the given interface.
+
+Returns empty settings if the interface has no settings file yet.
+*/
+func LoadInterfaceSettings(interfaceName string) (*InterfaceSettings, error) {
+	settings := &InterfaceSettings{}
+	content, err := os.ReadFile(settingsPath(interfaceName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return settings, nil
+		}
+		return nil, fmt.Errorf("Cannot read settings of %s: %w", interfaceName, err)
+	}
+	if err := json.Unmarshal(content, settings); err != nil {
+		return nil, fmt.Errorf("Cannot parse settings of %s: %w", interfaceName, err)
+	}
+	return settings, nil
+}
+
+/*
+SaveInterfaceSettings writes the settings of the given interface.
+
+The file may contain private keys of the peers, so it is only readable by root.
+*/
+func SaveInterfaceSettings(interfaceName string, settings *InterfaceSettings) error {
+	content, err := json.MarshalIndent(settings, "", "  ")
+	if err != nil {
+		return fmt.Errorf("Cannot encode settings of %s: %w", interfaceName, err)
+	}
+	if err := os.WriteFile(settingsPath(interfaceName), append(content, '\n'), 0600); err != nil {
+		return fmt.Errorf("Cannot write settings of %s: %w", interfaceName, err)
+	}
+	return nil
+}
+
+/*
+RemoveInterfaceSettings deletes the settings file of the given interface.
+*/
+func RemoveInterfaceSettings(interfaceName string) error {
+	if err := os.Remove(settingsPath(interfaceName)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("Cannot remove settings of %s: %w", interfaceName, err)
+	}
+	return nil
+}
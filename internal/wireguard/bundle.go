@@ -0,0 +1,134 @@
+package wireguard
+
+import (
+	"bytes"
+	"fast-wireguard/pkg/utils"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"rsc.io/qr"
+)
+
+/*
+BundlePeers writes a ZIP archive with the client configuration of each peer ("<peer>.conf", the layout the
+WireGuard apps import), a PNG QR code of it ("<peer>.png") and a README listing the peers.
+
+All the peers of the interface are bundled if none is given. The archive is encrypted if a passphrase is given.
+*/
+func BundlePeers(interfaceName string, peerNames []string, outPath string, passphrase string) error {
+	// 1. Select the peers
+	if len(peerNames) == 0 {
+		peers, err := parseWGPeerConfig(interfaceName)
+		if err != nil {
+			return err
+		}
+		for _, peer := range peers {
+			peerNames = append(peerNames, peer.PubKeyClient)
+		}
+	}
+	if len(peerNames) == 0 {
+		return fmt.Errorf("no peer in %s", interfaceName)
+	}
+
+	var archive bytes.Buffer
+	writer := utils.NewZipWriter(&archive, passphrase)
+	var manifest bytes.Buffer
+	table := tabwriter.NewWriter(&manifest, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "PEER\tCONFIGURATION\tQR CODE\tADDRESS\tPUBLIC KEY")
+
+	// 2. Add the configuration and the QR code of each peer, under a unique file name
+	used := make(map[string]bool)
+	var notes []string
+	for _, peerName := range peerNames {
+		peer, err := FindPeer(interfaceName, peerName)
+		if err != nil {
+			return err
+		}
+		data, err := BuildClientConfig(interfaceName, peerName)
+		if err != nil {
+			return err
+		}
+		content, err := RenderClientConfig(data)
+		if err != nil {
+			return err
+		}
+
+		// Peers added without a name are named after their address
+		if peer.PeerName == "" {
+			peer.PeerName = fmt.Sprintf("peer-%s", strings.Split(data.AllowedIPs, "/")[0])
+		}
+		name := exportFileName(peer.PeerName)
+		for i := 2; used[name]; i++ {
+			name = fmt.Sprintf("%s-%d", exportFileName(peer.PeerName), i)
+		}
+		used[name] = true
+		if err := writer.AddFile(name+".conf", []byte(content), 0600); err != nil {
+			return err
+		}
+		qrFile := name + ".png"
+		if png, err := utils.QRCodePNG(content, qr.L, 512); err != nil {
+			notes = append(notes, fmt.Sprintf("No QR code for %s: %v.", peer.PeerName, err))
+			qrFile = "-"
+		} else if err := writer.AddFile(qrFile, png, 0600); err != nil {
+			return err
+		}
+		if strings.Contains(data.PriKeyClient, "<") {
+			notes = append(notes, fmt.Sprintf("The private key of %s is not known to the server, fill it in %s.conf.", peer.PeerName, name))
+		}
+		fmt.Fprintf(table, "%s\t%s.conf\t%s\t%s\t%s\n", peer.PeerName, name, qrFile, data.AllowedIPs, peer.PubKeyClient)
+	}
+	table.Flush()
+
+	// 3. Add the README
+	var readme strings.Builder
+	fmt.Fprintf(&readme, "WireGuard client configurations of the interface %s, generated by fast-wireguard.\n\n", interfaceName)
+	readme.WriteString("Import a .conf file in the WireGuard app (\"Import tunnel(s) from file\", which also accepts this archive\n")
+	readme.WriteString("when it is not encrypted), scan its .png QR code with the mobile app, or copy it to /etc/wireguard/\n")
+	readme.WriteString("and run \"wg-quick up <file>\" on Linux. Each file contains a private key: give it only to its owner.\n\n")
+	readme.Write(manifest.Bytes())
+	for _, note := range notes {
+		readme.WriteString("\n" + note)
+	}
+	if len(notes) > 0 {
+		readme.WriteString("\n")
+	}
+	if err := writer.AddFile("README.txt", []byte(readme.String()), 0644); err != nil {
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("failed to write the archive: %w", err)
+	}
+
+	if err := writePrivateFile(outPath, archive.Bytes()); err != nil {
+		return fmt.Errorf("failed to write the archive to %s: %w", outPath, err)
+	}
+	for _, note := range notes {
+		fmt.Printf("Warning: %s\n", note)
+	}
+	if passphrase != "" {
+		fmt.Printf("✅ Encrypted bundle of %d peers written to %s\n", len(peerNames), outPath)
+	} else {
+		fmt.Printf("✅ Bundle of %d peers written to %s\n", len(peerNames), outPath)
+	}
+	return nil
+}
+
+// writePrivateFile writes data readable by the owner only, also restricting the mode of an existing file
+// before the keys are written to it.
+func writePrivateFile(path string, data []byte) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if err := f.Chmod(0600); err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -3,6 +3,8 @@ package wireguard
 import (
 	"bytes"
 	_ "embed"
+	"errors"
+	"fast-wireguard/internal/system"
 	"fast-wireguard/internal/templates"
 	"fast-wireguard/internal/tracker"
 	"fast-wireguard/pkg/utils"
@@ -17,6 +19,9 @@ import (
 
 var (
 	wgConfigDir = "/etc/wireguard"
+
+	// ErrOverwriteDeclined is returned when the user keeps the existing configuration of an interface
+	ErrOverwriteDeclined = errors.New("the existing configuration was kept")
 )
 
 type WgConfTplData struct {
@@ -26,6 +31,12 @@ type WgConfTplData struct {
 	Address           string
 	MTU               int
 	PhysicalInterface string
+	Family            system.Family
+	RoutedIPv6        bool // the peers have global IPv6 addresses, without NAT66
+	NDPProxy          bool // the addresses of the peers are answered on the physical interface
+	NDPProxies        []net.IP
+	PeerToPeer        bool // the peers can reach each other through the server
+	Forwards          []ForwardRule
 }
 
 type PeerConfTplData struct {
@@ -38,12 +49,18 @@ type ClientConfTplData struct {
 	PriKeyClient string
 	AllowedIPs   string
 	PubKeyServer string
+	RoutedIPs    string // AllowedIPs of the server on the client, i.e. the traffic sent through the tunnel
 	Endpoint     string
-	MTU          int
+	MTU          int // MTU recommended to the client, which may be lower than the one of the server
+	DNS          string
+	Subnets      string // LANs behind a site-to-site client, forwarded by it
+	SiteIPv6     bool
 }
 
 /*
 GenerateWGConfig create the service file for the given parameters
+
+Returns ErrOverwriteDeclined if the configuration file exists and the user does not overwrite it.
 */
 func GenerateWGConfig(
 	interfaceName string,
@@ -52,19 +69,17 @@ func GenerateWGConfig(
 	mtu int,
 	IPAdressLocal string,
 	physicalInterface string,
+	family system.Family,
+	ipv6 tracker.IPv6Settings,
+	peerToPeer bool,
 	force bool,
 ) error {
 	// 1. Make sure the path of the configuration file
 	configPath := filepath.Join(wgConfigDir, fmt.Sprintf("%s.conf", interfaceName))
 
 	// 2. Handle the option "force"
-	if !force {
-		if _, err := os.Stat(configPath); err == nil {
-			confirmed := utils.PromptConfirm(fmt.Sprintf("Config file for %s already exists. Do you want to overwrite it?", interfaceName), false)
-			if !confirmed {
-				return nil
-			}
-		}
+	if err := confirmOverwrite(interfaceName, force); err != nil {
+		return err
 	}
 
 	// 3. Prepare the data for template rendering
@@ -75,21 +90,20 @@ func GenerateWGConfig(
 		Address:           IPAdressLocal,
 		MTU:               mtu,
 		PhysicalInterface: physicalInterface,
+		Family:            family,
+		RoutedIPv6:        ipv6.Mode == ipv6ModeRouted,
+		NDPProxy:          ipv6.NDPProxy,
+		PeerToPeer:        peerToPeer,
 	}
 
 	// 4. Parse and render the template
-	tmpl, err := template.New("wgConfig").Parse(templates.WgConfTpl)
+	content, err := renderWGInterfaceConfig(data)
 	if err != nil {
-		return fmt.Errorf("failed to parse config template: %w", err)
-	}
-
-	var buffer bytes.Buffer
-	if err := tmpl.Execute(&buffer, data); err != nil {
-		return fmt.Errorf("failed to render config template: %w", err)
+		return err
 	}
 
 	// 6. Write the file with privilege 0600
-	if err := os.WriteFile(configPath, buffer.Bytes(), 0600); err != nil {
+	if err := os.WriteFile(configPath, content, 0600); err != nil {
 		return fmt.Errorf("failed to write config file to %s: %w", configPath, err)
 	}
 
@@ -102,6 +116,93 @@ func GenerateWGConfig(
 	return nil
 }
 
+// confirmOverwrite asks whether to overwrite the existing configuration file of the interface, unless forced.
+func confirmOverwrite(interfaceName string, force bool) error {
+	if force {
+		return nil
+	}
+	if _, err := os.Stat(filepath.Join(wgConfigDir, fmt.Sprintf("%s.conf", interfaceName))); err != nil {
+		return nil
+	}
+	if !utils.PromptConfirm(fmt.Sprintf("Config file for %s already exists. Do you want to overwrite it?", interfaceName), false) {
+		return ErrOverwriteDeclined
+	}
+	return nil
+}
+
+/*
+RefreshWGConfig renders the [Interface] section of the configuration file again from the settings of the interface.
+
+The server private key and the peers are kept unchanged.
+*/
+func RefreshWGConfig(interfaceName string) error {
+	configPath := filepath.Join(wgConfigDir, fmt.Sprintf("%s.conf", interfaceName))
+
+	// 1. Collect the data of the interface
+	settings, err := loadInterfaceSettings(interfaceName)
+	if err != nil {
+		return err
+	}
+	current, err := parseWGInterfaceConfig(interfaceName)
+	if err != nil {
+		return err
+	}
+	forwards, err := forwardRules(interfaceName, settings)
+	if err != nil {
+		return err
+	}
+	peers, err := parseWGPeerConfig(interfaceName)
+	if err != nil {
+		return err
+	}
+	data := WgConfTplData{
+		InterfaceName:     interfaceName,
+		PriKeyServer:      current.PriKeyServer,
+		ListenPort:        settings.ListenPort,
+		Address:           settings.Address,
+		MTU:               settings.MTU,
+		PhysicalInterface: settings.PhysicalInterface,
+		Family:            system.Family(settings.Family),
+		RoutedIPv6:        isRoutedIPv6(settings),
+		NDPProxy:          settings.IPv6.NDPProxy,
+		NDPProxies:        ndpProxyIPs(settings, peers),
+		PeerToPeer:        peerToPeerAllowed(settings),
+		Forwards:          forwards,
+	}
+
+	// 2. Render the [Interface] section and keep the peers
+	content, err := renderWGInterfaceConfig(data)
+	if err != nil {
+		return err
+	}
+	original, err := os.ReadFile(configPath)
+	if err != nil {
+		return fmt.Errorf("failed to read configuration file: %w", err)
+	}
+	if idx := strings.Index(string(original), "[Peer]"); idx != -1 {
+		content = append(content, original[idx:]...)
+	}
+
+	if err := os.WriteFile(configPath, content, 0600); err != nil {
+		return fmt.Errorf("failed to write config file to %s: %w", configPath, err)
+	}
+	return nil
+}
+
+// renderWGInterfaceConfig renders the [Interface] section of the server configuration.
+func renderWGInterfaceConfig(data WgConfTplData) ([]byte, error) {
+	tmpl, err := template.New("wgConfig").Funcs(template.FuncMap{"peerToPeerTarget": peerToPeerTarget}).Parse(templates.WgConfTpl)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config template: %w", err)
+	}
+
+	var buffer bytes.Buffer
+	if err := tmpl.Execute(&buffer, data); err != nil {
+		return nil, fmt.Errorf("failed to render config template: %w", err)
+	}
+	return buffer.Bytes(), nil
+}
+
 /*
 DeleteWGConfig removes the WireGuard configuration file for the given interface.
 */
@@ -129,6 +230,14 @@ func DeleteWGConfig(interfaceName string) error {
 		return fmt.Errorf("failed to remove private key file %s: %w", PriKeyPath, err)
 	}
 
+	// Stop the fwg services of the interface and remove its settings
+	if err := disableAllUnits(interfaceName); err != nil {
+		return err
+	}
+	if err := tracker.RemoveInterfaceSettings(interfaceName); err != nil {
+		return err
+	}
+
 	// Remove this from tracker
 	if err := tracker.RemoveInterfaceFromLog(interfaceName); err != nil {
 		return fmt.Errorf("failed to untrack the interface: %w\n", err)
@@ -167,14 +276,16 @@ AddWGPeerConfig adds a peer configuration to the given WireGuard interface confi
 */
 func AddWGPeerConfig(
 	interfaceName string,
-	serverPublicIP string,
-	listenPort int,
+	endpointHost string,
+	endpointPort int,
 	mtu int,
 	pubKeyServer string,
 	peerName string,
 	AllowedIPs string,
 	pubKeyClient string,
 	priKeyClient string,
+	routedIPs string,
+	dns string,
 ) (string, error) {
 	// 1. Make sure the path of the configuration file
 	configPath := filepath.Join(wgConfigDir, fmt.Sprintf("%s.conf", interfaceName))
@@ -206,7 +317,51 @@ func AddWGPeerConfig(
 	}
 
 	// 4. Handle the given peerName and AllowdIPs
-	if strings.Contains(AllowedIPs, "[auto-ipv4]") {
+	AllowedIPs = fillAutoAddresses(AllowedIPs, peers)
+
+	// 5. Prepare the data for template rendering
+	data := PeerConfTplData{
+		PeerName:     peerName,
+		PubKeyClient: pubKeyClient,
+		AllowedIPs:   AllowedIPs,
+	}
+
+	// 6. Parse and render the template
+	peerConfig, err := renderWGPeerConfig(data)
+	if err != nil {
+		return "", err
+	}
+
+	// 7. Append the peer configuration to the WireGuard config file
+	f, err := os.OpenFile(configPath, os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return "", fmt.Errorf("failed to open configuration file for appending: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(peerConfig); err != nil {
+		return "", fmt.Errorf("failed to append peer configuration: %w", err)
+	}
+	fmt.Printf("✅ Peer configuration added to %s\n", configPath)
+
+	// 8. Generate Client Configuration
+	return GenerateWGClientConfig(
+		endpointHost,
+		endpointPort,
+		priKeyClient,
+		pubKeyServer,
+		clientAddresses(AllowedIPs),
+		routedIPs,
+		mtu,
+		dns,
+	)
+}
+
+/*
+fillAutoAddresses replaces the placeholders [auto-ipv4] and [auto-ipv6] of the addresses with the first
+last octet (IPv4) or last 2 bytes (IPv6) not used by the given peers.
+*/
+func fillAutoAddresses(allowedIPs string, peers []PeerConfTplData) string {
+	if strings.Contains(allowedIPs, "[auto-ipv4]") {
 		// Scan for used IPv4 octets
 		usedOctets := make(map[int]bool)
 		for _, peer := range peers {
@@ -228,12 +383,12 @@ func AddWGPeerConfig(
 		// Allocate an unused IPv4 octet
 		for i := 2; i < 255; i++ {
 			if !usedOctets[i] {
-				AllowedIPs = strings.ReplaceAll(AllowedIPs, "[auto-ipv4]", strconv.Itoa(i))
+				allowedIPs = strings.ReplaceAll(allowedIPs, "[auto-ipv4]", strconv.Itoa(i))
 				break
 			}
 		}
 	}
-	if strings.Contains(AllowedIPs, "[auto-ipv6]") {
+	if strings.Contains(allowedIPs, "[auto-ipv6]") {
 		// Scan for used IPv6 last 2 bytes
 		usedValues := make(map[int]bool)
 		for _, peer := range peers {
@@ -256,49 +411,25 @@ func AddWGPeerConfig(
 		// Allocate an unused IPv6 value
 		for i := 2; i < 65535; i++ {
 			if !usedValues[i] {
-				AllowedIPs = strings.ReplaceAll(AllowedIPs, "[auto-ipv6]", strconv.Itoa(i))
+				allowedIPs = strings.ReplaceAll(allowedIPs, "[auto-ipv6]", strconv.Itoa(i))
 				break
 			}
 		}
 	}
+	return allowedIPs
+}
 
-	// 5. Prepare the data for template rendering
-	data := PeerConfTplData{
-		PeerName:     peerName,
-		PubKeyClient: pubKeyClient,
-		AllowedIPs:   AllowedIPs,
-	}
-
-	// 6. Parse and render the template
+// renderWGPeerConfig renders the [Peer] section of a peer in the configuration file of the interface.
+func renderWGPeerConfig(data PeerConfTplData) ([]byte, error) {
 	tmpl, err := template.New("peerConfig").Parse(templates.PeerConfTpl)
 	if err != nil {
-		return "", fmt.Errorf("failed to parse peer config template: %w", err)
+		return nil, fmt.Errorf("failed to parse peer config template: %w", err)
 	}
 	var buffer bytes.Buffer
 	if err := tmpl.Execute(&buffer, data); err != nil {
-		return "", fmt.Errorf("failed to render peer config template: %w", err)
+		return nil, fmt.Errorf("failed to render peer config template: %w", err)
 	}
-
-	// 7. Append the peer configuration to the WireGuard config file
-	f, err := os.OpenFile(configPath, os.O_APPEND|os.O_WRONLY, 0600)
-	if err != nil {
-		return "", fmt.Errorf("failed to open configuration file for appending: %w", err)
-	}
-	defer f.Close()
-	if _, err := f.Write(buffer.Bytes()); err != nil {
-		return "", fmt.Errorf("failed to append peer configuration: %w", err)
-	}
-	fmt.Printf("✅ Peer configuration added to %s\n", configPath)
-
-	// 8. Generate Client Configuration
-	return GenerateWGClientConfig(
-		serverPublicIP,
-		listenPort,
-		priKeyClient,
-		pubKeyServer,
-		AllowedIPs,
-		mtu,
-	)
+	return buffer.Bytes(), nil
 }
 
 /*
@@ -386,29 +517,36 @@ func parseWGPeerConfig(interfaceName string) ([]PeerConfTplData, error) {
 
 /*
 GenerateWGClientConfig generates the WireGuard client configuration string.
+
+The host of the endpoint is either an IP address or a DNS name.
 */
 func GenerateWGClientConfig(
-	serverPublicIP string,
-	listenPort int,
+	endpointHost string,
+	endpointPort int,
 	priKeyClient string,
 	pubKeyServer string,
 	allowedIPs string,
+	routedIPs string,
 	mtu int,
+	dns string,
 ) (string, error) {
-	host := serverPublicIP
-	if ip := net.ParseIP(host); ip != nil && ip.To4() == nil {
-		host = fmt.Sprintf("[%s]", host)
-	}
-	endpoint := fmt.Sprintf("%s:%d", host, listenPort)
+	endpoint := net.JoinHostPort(endpointHost, strconv.Itoa(endpointPort))
 
-	clientData := ClientConfTplData{
+	return RenderClientConfig(&ClientConfTplData{
 		PriKeyClient: priKeyClient,
 		AllowedIPs:   allowedIPs,
 		PubKeyServer: pubKeyServer,
+		RoutedIPs:    routedIPs,
 		Endpoint:     endpoint,
 		MTU:          mtu,
-	}
+		DNS:          dns,
+	})
+}
 
+/*
+RenderClientConfig renders the wg-quick configuration file of a client.
+*/
+func RenderClientConfig(clientData *ClientConfTplData) (string, error) {
 	tmplClient, err := template.New("clientConfig").Parse(templates.ClientConfTpl)
 	if err != nil {
 		return "", fmt.Errorf("failed to parse client config template: %w", err)
@@ -421,3 +559,39 @@ func GenerateWGClientConfig(
 
 	return clientBuffer.String(), nil
 }
+
+/*
+parseWGInterfaceConfig reads the WireGuard configuration file and extracts the [Interface] section.
+*/
+func parseWGInterfaceConfig(interfaceName string) (WgConfTplData, error) {
+	configPath := filepath.Join(wgConfigDir, fmt.Sprintf("%s.conf", interfaceName))
+	data := WgConfTplData{InterfaceName: interfaceName}
+
+	content, err := os.ReadFile(configPath)
+	if err != nil {
+		return data, fmt.Errorf("failed to read configuration file: %w", err)
+	}
+	section := strings.Split(string(content), "[Peer]")[0]
+	for line := range strings.SplitSeq(section, "\n") {
+		line = strings.TrimSpace(line)
+		if after, ok := strings.CutPrefix(line, "Address ="); ok {
+			data.Address = strings.TrimSpace(after)
+		} else if after, ok := strings.CutPrefix(line, "PrivateKey ="); ok {
+			data.PriKeyServer = strings.TrimSpace(after)
+		} else if after, ok := strings.CutPrefix(line, "ListenPort ="); ok {
+			data.ListenPort, _ = strconv.Atoi(strings.TrimSpace(after))
+		} else if after, ok := strings.CutPrefix(line, "MTU ="); ok {
+			data.MTU, _ = strconv.Atoi(strings.TrimSpace(after))
+		} else if strings.HasSuffix(line, "-j MASQUERADE") && data.PhysicalInterface == "" {
+			// PostUp = iptables -t nat -A POSTROUTING -o {{ .PhysicalInterface }} -j MASQUERADE
+			fields := strings.Fields(line)
+			for i, field := range fields[:len(fields)-1] {
+				if field == "-o" {
+					data.PhysicalInterface = fields[i+1]
+				}
+			}
+		}
+	}
+
+	return data, nil
+}
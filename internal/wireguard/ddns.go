@@ -0,0 +1,155 @@
+package wireguard
+
+import (
+	"fast-wireguard/internal/ddns"
+	"fast-wireguard/internal/system"
+	"fast-wireguard/internal/templates"
+	"fast-wireguard/internal/tracker"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"time"
+)
+
+/*
+SetDDNS records the dynamic DNS settings of the interface and (re)starts or stops the updater service.
+
+The clients use the updated name as endpoint from now on.
+*/
+func SetDDNS(interfaceName string, ddnsSettings tracker.DDNSSettings) error {
+	settings, err := loadInterfaceSettings(interfaceName)
+	if err != nil {
+		return err
+	}
+
+	if ddnsSettings.Provider == "" {
+		settings.DDNS = ddnsSettings
+		if err := tracker.SaveInterfaceSettings(interfaceName, settings); err != nil {
+			return err
+		}
+		return disableUnit("fwg-ddns", interfaceName, false)
+	}
+
+	// 1. Validate the settings by building the updater
+	if _, err := newDDNSUpdater(ddnsSettings); err != nil {
+		return err
+	}
+	if ddnsSettings.Name == "" {
+		return fmt.Errorf("the name to update is required")
+	}
+
+	// 2. Point the clients to the name instead of an IP address
+	host, port, err := ParseEndpoint(settings.Endpoint)
+	if settings.Endpoint == "" || (err == nil && net.ParseIP(host) != nil) {
+		settings.Endpoint = ddnsSettings.Name
+		if port != 0 {
+			settings.Endpoint = net.JoinHostPort(ddnsSettings.Name, strconv.Itoa(port))
+		}
+		fmt.Printf("✅ Endpoint of %s changed to %s\n", interfaceName, settings.Endpoint)
+	}
+
+	settings.DDNS = ddnsSettings
+	if err := tracker.SaveInterfaceSettings(interfaceName, settings); err != nil {
+		return err
+	}
+	return enableUnit("fwg-ddns", templates.DDNSServiceTpl, interfaceName)
+}
+
+/*
+RunDDNS discovers the public IP of the server periodically and updates the DNS name whenever it changes.
+
+With once, it updates the name a single time and returns.
+*/
+func RunDDNS(interfaceName string, once bool) error {
+	settings, err := loadInterfaceSettings(interfaceName)
+	if err != nil {
+		return err
+	}
+	if settings.DDNS.Provider == "" {
+		return fmt.Errorf("dynamic DNS is not configured for %s", interfaceName)
+	}
+	updater, err := newDDNSUpdater(settings.DDNS)
+	if err != nil {
+		return err
+	}
+	interval := time.Duration(settings.DDNS.IntervalSeconds) * time.Second
+	if interval <= 0 {
+		interval = 5 * time.Minute
+	}
+
+	// Always discover the current IP instead of using the cache
+	opts := discoveryOptions(settings)
+	opts.CacheTTL = 0
+
+	lastIP := ""
+	for {
+		ip, err := system.GetPublicIP(opts)
+		switch {
+		case err != nil:
+			log.Printf("Failed to discover the public IP: %v", err)
+		case ip == lastIP:
+			// Nothing changed
+		case !isPublicIP(ip):
+			log.Printf("Ignoring the non-public IP %s", ip)
+		default:
+			if err := updater.Update(settings.DDNS.Name, net.ParseIP(ip)); err != nil {
+				log.Printf("Failed to update %s to %s via %s: %v", settings.DDNS.Name, ip, updater.Name(), err)
+				if once {
+					return err
+				}
+				break
+			}
+			if lastIP == "" {
+				log.Printf("Updated %s to %s via %s", settings.DDNS.Name, ip, updater.Name())
+			} else {
+				log.Printf("Public IP changed from %s to %s, updated %s via %s", lastIP, ip, settings.DDNS.Name, updater.Name())
+			}
+			lastIP = ip
+		}
+
+		if once {
+			return nil
+		}
+		time.Sleep(interval)
+	}
+}
+
+// newDDNSUpdater builds the updater of the provider of the settings.
+func newDDNSUpdater(settings tracker.DDNSSettings) (ddns.Updater, error) {
+	switch settings.Provider {
+	case "rfc2136":
+		if settings.Server == "" || settings.Zone == "" {
+			return nil, fmt.Errorf("the DNS server and the zone are required for RFC 2136 updates")
+		}
+		algorithm := settings.TSIGAlgorithm
+		if algorithm == "" {
+			algorithm = "hmac-sha256"
+		}
+		ttl := settings.TTL
+		if ttl <= 0 {
+			ttl = 60
+		}
+		return &ddns.RFC2136Updater{
+			Server:        settings.Server,
+			Zone:          settings.Zone,
+			TSIGKeyName:   settings.TSIGKeyName,
+			TSIGSecret:    settings.TSIGSecret,
+			TSIGAlgorithm: algorithm,
+			TTL:           uint32(ttl),
+		}, nil
+	case "http":
+		if settings.URLTemplate == "" {
+			return nil, fmt.Errorf("the update URL is required for HTTP updates")
+		}
+		return &ddns.HTTPUpdater{URLTemplate: settings.URLTemplate}, nil
+	default:
+		return nil, fmt.Errorf("unsupported dynamic DNS provider %s, use rfc2136 or http", settings.Provider)
+	}
+}
+
+// isPublicIP reports whether the IP can be published, i.e. it is not a private, carrier-grade NAT, loopback or link-local address.
+func isPublicIP(ip string) bool {
+	parsedIP := net.ParseIP(ip)
+	return parsedIP != nil && parsedIP.IsGlobalUnicast() && !parsedIP.IsPrivate() && !cgnatNetwork.Contains(parsedIP)
+}
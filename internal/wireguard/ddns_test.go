@@ -0,0 +1,28 @@
+package wireguard
+
+import "testing"
+
+func TestIsPublicIP(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"203.0.113.7", true},
+		{"2001:db8::7", true},
+		{"192.168.1.10", false},
+		{"10.0.0.1", false},
+		{"100.64.0.1", false},
+		{"100.127.255.254", false},
+		{"100.128.0.1", true},
+		{"127.0.0.1", false},
+		{"169.254.1.1", false},
+		{"fd00::1", false},
+		{"fe80::1", false},
+		{"invalid", false},
+	}
+	for _, test := range tests {
+		if got := isPublicIP(test.ip); got != test.want {
+			t.Errorf("isPublicIP(%s) = %t, want %t", test.ip, got, test.want)
+		}
+	}
+}
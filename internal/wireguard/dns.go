@@ -0,0 +1,206 @@
+package wireguard
+
+import (
+	"fast-wireguard/internal/resolver"
+	"fast-wireguard/internal/system"
+	"fast-wireguard/internal/templates"
+	"fast-wireguard/internal/tracker"
+	"fast-wireguard/pkg/utils"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+/*
+clientDNS returns the value of the "DNS =" line in the client configuration of the given peer.
+
+The servers and search domains of the peer override the ones of the interface.
+If no server is configured and the DNS service is enabled, the clients use the tunnel address of the server,
+and the zone of the peer names is added to the search domains.
+*/
+func clientDNS(settings *tracker.InterfaceSettings, peer *tracker.PeerSettings) string {
+	servers, searchDomains := settings.DNS.Servers, settings.DNS.SearchDomains
+	if peer != nil && peer.DNS != nil {
+		if len(peer.DNS.Servers) > 0 {
+			servers = peer.DNS.Servers
+		}
+		if len(peer.DNS.SearchDomains) > 0 {
+			searchDomains = peer.DNS.SearchDomains
+		}
+	}
+	if len(servers) == 0 && DNSServiceEnabled(settings) {
+		for _, ip := range TunnelIPs(settings.Address) {
+			servers = append(servers, ip.String())
+		}
+		if settings.DNS.Zone != "" && !slices.Contains(searchDomains, settings.DNS.Zone) {
+			searchDomains = append([]string{settings.DNS.Zone}, searchDomains...)
+		}
+	}
+	return strings.Join(append(append([]string{}, servers...), searchDomains...), ", ")
+}
+
+/*
+DNSServiceEnabled reports whether the fwg DNS service should run for the interface.
+*/
+func DNSServiceEnabled(settings *tracker.InterfaceSettings) bool {
+	return settings.DNS.Forwarder || settings.DNS.Zone != ""
+}
+
+/*
+TunnelIPs returns the IP addresses of the server from the "Address =" value of the interface.
+*/
+func TunnelIPs(address string) []net.IP {
+	var ips []net.IP
+	for _, part := range utils.SplitList(address) {
+		ip, _, err := net.ParseCIDR(part)
+		if err != nil {
+			ip = net.ParseIP(part)
+		}
+		if ip != nil {
+			ips = append(ips, ip)
+		}
+	}
+	return ips
+}
+
+/*
+SetDNS changes the DNS settings of the given interface with update, then starts or stops the fwg DNS service to match them.
+*/
+func SetDNS(interfaceName string, update func(dnsSettings *tracker.DNSSettings)) error {
+	settings, err := loadInterfaceSettings(interfaceName)
+	if err != nil {
+		return err
+	}
+	update(&settings.DNS)
+	if err := tracker.SaveInterfaceSettings(interfaceName, settings); err != nil {
+		return err
+	}
+	fmt.Printf("✅ DNS settings of %s updated.\n", interfaceName)
+
+	if DNSServiceEnabled(settings) {
+		err = EnableDNSService(interfaceName)
+	} else {
+		err = DisableDNSService(interfaceName, false)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update the DNS service: %w", err)
+	}
+	return nil
+}
+
+/*
+EnableDNSService installs the systemd unit of the fwg DNS service and starts it for the given interface.
+*/
+func EnableDNSService(interfaceName string) error {
+	return enableUnit("fwg-dns", templates.DNSServiceTpl, interfaceName)
+}
+
+/*
+DisableDNSService stops the fwg DNS service of the given interface and disables it.
+*/
+func DisableDNSService(interfaceName string, silent bool) error {
+	return disableUnit("fwg-dns", interfaceName, silent)
+}
+
+/*
+RunDNSService serves DNS on the tunnel addresses of the given interface until it fails.
+
+The queries are forwarded to the upstream resolvers, except the names of the peers in the zone
+of the interface, which are reloaded whenever the configuration file changes, and the reverse names
+of the tunnel networks.
+*/
+func RunDNSService(interfaceName string) error {
+	settings, err := loadInterfaceSettings(interfaceName)
+	if err != nil {
+		return err
+	}
+
+	upstreams := settings.DNS.Upstreams
+	if len(upstreams) == 0 {
+		upstreams = system.GetSystemResolvers()
+	}
+	fmt.Printf("Forwarding DNS queries to %s\n", strings.Join(upstreams, ", "))
+	var handler dns.Handler = resolver.NewForwarder(upstreams)
+
+	if settings.DNS.Zone != "" {
+		zone := resolver.NewZone(settings.DNS.Zone, handler)
+		for _, network := range tunnelNetworks(settings.Address) {
+			if _, ipNet, err := net.ParseCIDR(network); err == nil {
+				zone.Networks = append(zone.Networks, ipNet)
+			}
+		}
+		go watchZone(interfaceName, settings, zone)
+		handler = zone
+	}
+
+	return resolver.ListenAndServe(TunnelIPs(settings.Address), handler)
+}
+
+// watchZone updates the hosts of the zone every time the configuration file of the interface changes.
+func watchZone(interfaceName string, settings *tracker.InterfaceSettings, zone *resolver.Zone) {
+	configPath := filepath.Join(wgConfigDir, fmt.Sprintf("%s.conf", interfaceName))
+	var lastModTime time.Time
+	for {
+		if info, err := os.Stat(configPath); err == nil && !info.ModTime().Equal(lastModTime) {
+			hosts, err := zoneHosts(interfaceName, settings)
+			if err != nil {
+				fmt.Printf("Warning: failed to load the peers of %s: %v\n", interfaceName, err)
+			} else {
+				zone.Update(hosts)
+				lastModTime = info.ModTime()
+				fmt.Printf("✅ Zone %s loaded with %d hosts.\n", settings.DNS.Zone, len(hosts))
+			}
+		}
+		time.Sleep(5 * time.Second)
+	}
+}
+
+/*
+zoneHosts returns the tunnel addresses of the server ("server") and of every named peer.
+*/
+func zoneHosts(interfaceName string, settings *tracker.InterfaceSettings) (map[string][]net.IP, error) {
+	peers, err := parseWGPeerConfig(interfaceName)
+	if err != nil {
+		return nil, err
+	}
+
+	hosts := map[string][]net.IP{"server": TunnelIPs(settings.Address)}
+	for _, peer := range peers {
+		label := dnsLabel(peer.PeerName)
+		if label == "" {
+			continue
+		}
+		if _, ok := hosts[label]; ok {
+			fmt.Printf("Warning: the name %s is used by several peers, only the first one is resolved.\n", label)
+			continue
+		}
+		ips := hostIPs(peer.AllowedIPs)
+		if len(ips) > 0 {
+			hosts[label] = ips
+		}
+	}
+	return hosts, nil
+}
+
+// dnsLabel converts the name of a peer into a DNS label (e.g. "Laptop Alice" into "laptop-alice").
+func dnsLabel(name string) string {
+	var builder strings.Builder
+	for _, r := range strings.ToLower(name) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			builder.WriteRune(r)
+		} else {
+			builder.WriteRune('-')
+		}
+	}
+	label := strings.Trim(builder.String(), "-")
+	if len(label) > 63 {
+		label = strings.TrimRight(label[:63], "-")
+	}
+	return label
+}
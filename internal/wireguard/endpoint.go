@@ -0,0 +1,103 @@
+package wireguard
+
+import (
+	"fast-wireguard/internal/system"
+	"fast-wireguard/internal/tracker"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+ParseEndpoint splits an endpoint given as "host[:port]", where the host is an IP address or a DNS name.
+
+IPv6 addresses with a port are written in brackets (e.g. "[2001:db8::1]:51820").
+Returns the host and the port, 0 if no port is given.
+*/
+func ParseEndpoint(endpoint string) (string, int, error) {
+	endpoint = strings.TrimSpace(endpoint)
+	if endpoint == "" {
+		return "", 0, fmt.Errorf("empty endpoint")
+	}
+
+	// A bare IPv6 address has no port
+	if ip := net.ParseIP(strings.Trim(endpoint, "[]")); ip != nil {
+		return ip.String(), 0, nil
+	}
+
+	host, port := endpoint, 0
+	if h, p, err := net.SplitHostPort(endpoint); err == nil {
+		port, err = strconv.Atoi(p)
+		if err != nil || port < 1 || port > 65535 {
+			return "", 0, fmt.Errorf("invalid port in endpoint %s", endpoint)
+		}
+		host = h
+	}
+	if !isValidHostname(host) {
+		return "", 0, fmt.Errorf("invalid host in endpoint %s", endpoint)
+	}
+	return host, port, nil
+}
+
+/*
+clientEndpoint returns the host and the port the clients connect to.
+
+If the interface has no endpoint yet, the public IP of the server is detected and recorded into the settings,
+so the caller has to save them.
+*/
+func clientEndpoint(settings *tracker.InterfaceSettings) (string, int, error) {
+	if settings.Endpoint == "" {
+		serverPublicIP, err := system.GetPublicIP(discoveryOptions(settings))
+		if err != nil {
+			return "", 0, err
+		}
+		settings.Endpoint = serverPublicIP
+	}
+
+	host, port, err := ParseEndpoint(settings.Endpoint)
+	if err != nil {
+		return "", 0, err
+	}
+	if port == 0 {
+		port = settings.ListenPort
+	}
+	return host, port, nil
+}
+
+/*
+discoveryOptions returns the options of the public IP discovery of the interface.
+*/
+func discoveryOptions(settings *tracker.InterfaceSettings) *system.DiscoveryOptions {
+	return &system.DiscoveryOptions{
+		STUNServers:   settings.Discovery.STUNServers,
+		HTTPProviders: settings.Discovery.HTTPProviders,
+		Consensus:     settings.Discovery.Consensus,
+		Offline:       settings.Discovery.Offline,
+		PreferIPv6:    settings.PreferIPv6,
+		CacheTTL:      time.Duration(settings.Discovery.CacheTTLSeconds) * time.Second,
+	}
+}
+
+// isValidHostname checks the form of a DNS name or an IP address.
+func isValidHostname(host string) bool {
+	if net.ParseIP(host) != nil {
+		return true
+	}
+	host = strings.TrimSuffix(host, ".")
+	if host == "" || len(host) > 253 {
+		return false
+	}
+	for label := range strings.SplitSeq(host, ".") {
+		if label == "" || len(label) > 63 || strings.HasPrefix(label, "-") || strings.HasSuffix(label, "-") {
+			return false
+		}
+		for _, r := range label {
+			if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-') {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,655 @@
+package wireguard
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/xml"
+	"fast-wireguard/internal/templates"
+	"fast-wireguard/pkg/utils"
+	"fmt"
+	"net"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"gopkg.in/yaml.v3"
+)
+
+/*
+ExportFile is a file of an exported client configuration, Mode and Owner (root if empty) are the ones it needs on the client.
+*/
+type ExportFile struct {
+	Name    string
+	Content string
+	Mode    uint32
+	Owner   string
+}
+
+/*
+ExportOptions selects the export format, the metadata of the Kubernetes manifests and the settings of the Apple profiles.
+
+With several peers, a name given for the manifests is the prefix of the name of each one.
+OnDemand is "off", "always" or "untrusted" (connect except on the Wi-Fi networks of TrustedSSIDs).
+*/
+type ExportOptions struct {
+	Format       string
+	Name         string
+	Namespace    string
+	Labels       map[string]string
+	ConfigMap    bool
+	DisplayName  string
+	Platform     string
+	OnDemand     string
+	TrustedSSIDs []string
+}
+
+/*
+ExportTplData is the client configuration of a peer split by family, as the network managers of the clients expect it.
+*/
+type ExportTplData struct {
+	*ClientConfTplData
+	InterfaceName string
+	PeerName      string
+	PubKeyClient  string
+	UUID          string
+	EndpointHost  string
+	EndpointPort  int
+	IPv4Addresses []string
+	IPv6Addresses []string
+	IPv4Routes    []string
+	IPv6Routes    []string
+	IPv4DNS       []string
+	IPv6DNS       []string
+	SearchDomains []string
+	FullTunnel    bool     // the default routes go through the tunnel
+	Networks      []string // networks of the tunnel
+	Options       *ExportOptions
+}
+
+var (
+	// Characters of the peer names not kept in the exported file names
+	unsafeFileChars = regexp.MustCompile(`[^a-zA-Z0-9_.-]`)
+	// Characters not allowed in the names of the UCI sections
+	unsafeUCIChars = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+	// Characters not allowed in the names of the Kubernetes objects (RFC 1123 subdomains)
+	unsafeK8sChars = regexp.MustCompile(`[^a-z0-9.-]+`)
+	// Networks kept out of a full tunnel, which contain the LAN of the peer
+	privateNetworks = []string{"10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "fc00::/7"}
+)
+
+// The table of the routes of the host
+const mainTable = 254
+
+// exportFormats renders the files of each export format.
+var exportFormats = map[string]func(data *ExportTplData) ([]ExportFile, error){
+	"wg-quick":       exportWGQuick,
+	"networkmanager": exportNetworkManager,
+	"networkd":       exportNetworkd,
+	"openwrt":        exportOpenWrt,
+	"mikrotik":       exportRouterOS,
+	"k8s-secret":     exportK8sSecret,
+	"mobileconfig":   exportMobileConfig,
+	"netplan":        exportNetplan,
+}
+
+/*
+ExportFormats returns the names of the export formats.
+*/
+func ExportFormats() []string {
+	var names []string
+	for name := range exportFormats {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+/*
+ExportClientConfigs renders the client configurations of the peers (given by name or public key) in the format of the options.
+
+With several peers, the files are named "<peer>/<file>".
+*/
+func ExportClientConfigs(interfaceName string, peerNames []string, opts *ExportOptions) ([]ExportFile, error) {
+	render, ok := exportFormats[opts.Format]
+	if !ok {
+		return nil, fmt.Errorf("invalid format %s, use %s", opts.Format, strings.Join(ExportFormats(), ", "))
+	}
+	settings, err := loadInterfaceSettings(interfaceName)
+	if err != nil {
+		return nil, err
+	}
+	var files []ExportFile
+	for _, peerName := range peerNames {
+		peer, err := FindPeer(interfaceName, peerName)
+		if err != nil {
+			return nil, err
+		}
+		clientData, err := BuildClientConfig(interfaceName, peerName)
+		if err != nil {
+			return nil, err
+		}
+		data, err := newExportTplData(interfaceName, peer, clientData)
+		if err != nil {
+			return nil, err
+		}
+		data.Networks = tunnelNetworks(settings.Address)
+		data.Options = opts
+		if len(peerNames) > 1 && opts.Name != "" {
+			peerOpts := *opts
+			peerOpts.Name = opts.Name + "-" + peer.PeerName
+			data.Options = &peerOpts
+		}
+		peerFiles, err := render(data)
+		if err != nil {
+			return nil, err
+		}
+		// The files of several peers are named alike, each peer gets a directory
+		if len(peerNames) > 1 {
+			for i := range peerFiles {
+				peerFiles[i].Name = filepath.Join(exportFileName(peer.PeerName), peerFiles[i].Name)
+			}
+		}
+		files = append(files, peerFiles...)
+	}
+	return files, nil
+}
+
+// newExportTplData splits the addresses, the routes and the DNS servers of the client configuration by family.
+func newExportTplData(interfaceName string, peer PeerConfTplData, clientData *ClientConfTplData) (*ExportTplData, error) {
+	host, port, err := net.SplitHostPort(clientData.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid endpoint %s: %w", clientData.Endpoint, err)
+	}
+	data := &ExportTplData{
+		ClientConfTplData: clientData,
+		InterfaceName:     interfaceName,
+		PeerName:          peer.PeerName,
+		PubKeyClient:      peer.PubKeyClient,
+		UUID:              stableUUID(interfaceName + "/" + peer.PubKeyClient),
+		EndpointHost:      host,
+	}
+	data.EndpointPort, _ = strconv.Atoi(port)
+	data.IPv4Addresses, data.IPv6Addresses = splitFamilies(utils.SplitList(clientData.AllowedIPs))
+	data.IPv4Routes, data.IPv6Routes = splitFamilies(utils.SplitList(clientData.RoutedIPs))
+	data.FullTunnel = slices.Contains(data.IPv4Routes, "0.0.0.0/0") || slices.Contains(data.IPv6Routes, "::/0")
+	for _, item := range utils.SplitList(clientData.DNS) {
+		ip := net.ParseIP(item)
+		switch {
+		case ip == nil:
+			data.SearchDomains = append(data.SearchDomains, item)
+		case ip.To4() != nil:
+			data.IPv4DNS = append(data.IPv4DNS, item)
+		default:
+			data.IPv6DNS = append(data.IPv6DNS, item)
+		}
+	}
+	return data, nil
+}
+
+// splitFamilies splits a list of addresses or networks into the IPv4 and the IPv6 ones.
+func splitFamilies(items []string) ([]string, []string) {
+	var ipv4, ipv6 []string
+	for _, item := range items {
+		if strings.Contains(item, ":") {
+			ipv6 = append(ipv6, item)
+		} else {
+			ipv4 = append(ipv4, item)
+		}
+	}
+	return ipv4, ipv6
+}
+
+// stableUUID derives a name-based UUID (version 5 layout) from the seed, so an exported connection keeps its UUID.
+func stableUUID(seed string) string {
+	sum := sha1.Sum([]byte(seed))
+	sum[6] = sum[6]&0x0f | 0x50
+	sum[8] = sum[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16])
+}
+
+// renderExportTemplate renders one of the templates of the export formats.
+func renderExportTemplate(name string, text string, data any) (string, error) {
+	tmpl, err := template.New(name).Funcs(template.FuncMap{
+		"join":  strings.Join,
+		"add1":  func(i int) int { return i + 1 },
+		"quote": shellQuote,
+		"ros":   routerOSQuote,
+		"xml":   xmlEscape,
+	}).Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse %s template: %w", name, err)
+	}
+	var buffer bytes.Buffer
+	if err := tmpl.Execute(&buffer, data); err != nil {
+		return "", fmt.Errorf("failed to render %s template: %w", name, err)
+	}
+	return buffer.String(), nil
+}
+
+// exportWGQuick renders the configuration file of wg-quick, named after the interface.
+func exportWGQuick(data *ExportTplData) ([]ExportFile, error) {
+	content, err := RenderClientConfig(data.ClientConfTplData)
+	if err != nil {
+		return nil, err
+	}
+	return []ExportFile{{Name: data.InterfaceName + ".conf", Content: content, Mode: 0600}}, nil
+}
+
+// exportNetworkManager renders the keyfile of NetworkManager, to copy into /etc/NetworkManager/system-connections.
+func exportNetworkManager(data *ExportTplData) ([]ExportFile, error) {
+	content, err := renderExportTemplate("networkManager", templates.NetworkManagerTpl, data)
+	if err != nil {
+		return nil, err
+	}
+	return []ExportFile{{Name: fmt.Sprintf("%s-%s.nmconnection", data.InterfaceName, exportFileName(data.PeerName)), Content: content, Mode: 0600}}, nil
+}
+
+// exportFileName replaces the characters of the name which are not safe in a file name.
+func exportFileName(name string) string {
+	return unsafeFileChars.ReplaceAllString(name, "_")
+}
+
+/*
+networkdTplData adds the routing of systemd-networkd: a full tunnel routes its default routes in a table of its own,
+used by the packets not marked by the tunnel, as wg-quick does.
+*/
+type networkdTplData struct {
+	*ExportTplData
+	Table      int
+	RuleFamily string
+	Routes     []string
+}
+
+// exportNetworkd renders the netdev, the network and the private key file of systemd-networkd.
+func exportNetworkd(data *ExportTplData) ([]ExportFile, error) {
+	networkdData := &networkdTplData{
+		ExportTplData: data,
+		Table:         51820,
+		Routes:        utils.SplitList(data.RoutedIPs),
+	}
+	switch {
+	case len(data.IPv4Routes) > 0 && len(data.IPv6Routes) > 0:
+		networkdData.RuleFamily = "both"
+	case len(data.IPv6Routes) > 0:
+		networkdData.RuleFamily = "ipv6"
+	default:
+		networkdData.RuleFamily = "ipv4"
+	}
+
+	netdev, err := renderExportTemplate("networkdNetdev", templates.NetworkdNetdevTpl, networkdData)
+	if err != nil {
+		return nil, err
+	}
+	network, err := renderExportTemplate("networkdNetwork", templates.NetworkdNetworkTpl, networkdData)
+	if err != nil {
+		return nil, err
+	}
+	// systemd-networkd reads the netdev and the key as the group systemd-network
+	return []ExportFile{
+		{Name: data.InterfaceName + ".netdev", Content: netdev, Mode: 0640, Owner: "root:systemd-network"},
+		{Name: data.InterfaceName + ".network", Content: network, Mode: 0644},
+		{Name: data.InterfaceName + ".key", Content: data.PriKeyClient + "\n", Mode: 0640, Owner: "root:systemd-network"},
+	}, nil
+}
+
+/*
+openWrtTplData adds the name of the UCI sections, the interface name with the characters UCI does not allow replaced.
+*/
+type openWrtTplData struct {
+	*ExportTplData
+	Section string
+}
+
+// exportOpenWrt renders the UCI script configuring the interface, the peer and the firewall zone on OpenWrt.
+func exportOpenWrt(data *ExportTplData) ([]ExportFile, error) {
+	openWrtData := &openWrtTplData{
+		ExportTplData: data,
+		Section:       unsafeUCIChars.ReplaceAllString(data.InterfaceName, "_"),
+	}
+	content, err := renderExportTemplate("openWrt", templates.OpenWrtTpl, openWrtData)
+	if err != nil {
+		return nil, err
+	}
+	return []ExportFile{{Name: fmt.Sprintf("%s-%s-openwrt.sh", data.InterfaceName, exportFileName(data.PeerName)), Content: content, Mode: 0700}}, nil
+}
+
+// shellQuote quotes the value for the shell, inside single quotes.
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+/*
+routerOSTplData adds the lists of RouterOS, the name of the routing table of a full tunnel and the private
+networks its traffic does not enter.
+*/
+type routerOSTplData struct {
+	*ExportTplData
+	FileName    string
+	Table       string
+	AllowedList []string
+	DNSList     []string
+	IPv4Bypass  []string
+	IPv6Bypass  []string
+}
+
+// exportRouterOS renders the RouterOS script adding the interface, the peer, the addresses and the routes on MikroTik.
+func exportRouterOS(data *ExportTplData) ([]ExportFile, error) {
+	routerOSData := &routerOSTplData{
+		ExportTplData: data,
+		FileName:      fmt.Sprintf("%s-%s.rsc", data.InterfaceName, exportFileName(data.PeerName)),
+		Table:         "fwg-" + data.InterfaceName,
+		AllowedList:   utils.SplitList(data.RoutedIPs),
+		DNSList:       append(append([]string{}, data.IPv4DNS...), data.IPv6DNS...),
+	}
+	for _, network := range privateNetworks {
+		if strings.Contains(network, ":") {
+			routerOSData.IPv6Bypass = append(routerOSData.IPv6Bypass, network)
+		} else {
+			routerOSData.IPv4Bypass = append(routerOSData.IPv4Bypass, network)
+		}
+	}
+	content, err := renderExportTemplate("routerOS", templates.RouterOSTpl, routerOSData)
+	if err != nil {
+		return nil, err
+	}
+	return []ExportFile{{Name: routerOSData.FileName, Content: content, Mode: 0600}}, nil
+}
+
+// routerOSQuote quotes the value as a RouterOS string.
+func routerOSQuote(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, `$`, `\$`).Replace(value) + `"`
+}
+
+// k8sObject is a Kubernetes Secret or ConfigMap.
+type k8sObject struct {
+	APIVersion string            `yaml:"apiVersion"`
+	Kind       string            `yaml:"kind"`
+	Metadata   k8sMetadata       `yaml:"metadata"`
+	Type       string            `yaml:"type,omitempty"`
+	Data       map[string]string `yaml:"data"`
+}
+
+type k8sMetadata struct {
+	Name      string            `yaml:"name"`
+	Namespace string            `yaml:"namespace,omitempty"`
+	Labels    map[string]string `yaml:"labels"`
+}
+
+/*
+exportK8sSecret renders a Secret holding the wg-quick configuration (base64 under "<interface>.conf"), followed by a
+ConfigMap of its parts which are not secret if the options ask for it.
+*/
+func exportK8sSecret(data *ExportTplData) ([]ExportFile, error) {
+	content, err := RenderClientConfig(data.ClientConfTplData)
+	if err != nil {
+		return nil, err
+	}
+	name := data.Options.Name
+	if name == "" {
+		name = data.InterfaceName + "-" + data.PeerName
+	}
+	metadata := k8sMetadata{
+		Name:      k8sName(name),
+		Namespace: data.Options.Namespace,
+		Labels:    map[string]string{"app.kubernetes.io/managed-by": "fast-wireguard"},
+	}
+	for key, value := range data.Options.Labels {
+		metadata.Labels[key] = value
+	}
+
+	objects := []k8sObject{{
+		APIVersion: "v1",
+		Kind:       "Secret",
+		Metadata:   metadata,
+		Type:       "Opaque",
+		Data:       map[string]string{data.InterfaceName + ".conf": base64.StdEncoding.EncodeToString([]byte(content))},
+	}}
+	if data.Options.ConfigMap {
+		objects = append(objects, k8sObject{
+			APIVersion: "v1",
+			Kind:       "ConfigMap",
+			Metadata:   metadata,
+			Data: map[string]string{
+				"address":           data.AllowedIPs,
+				"dns":               data.DNS,
+				"endpoint":          data.Endpoint,
+				"server-public-key": data.PubKeyServer,
+				"allowed-ips":       data.RoutedIPs,
+				"mtu":               fmt.Sprint(data.MTU),
+			},
+		})
+	}
+
+	// The encoder separates the documents with "---"
+	var buffer bytes.Buffer
+	encoder := yaml.NewEncoder(&buffer)
+	encoder.SetIndent(2)
+	for _, object := range objects {
+		if err := encoder.Encode(object); err != nil {
+			return nil, fmt.Errorf("failed to encode the %s of %s: %w", object.Kind, data.PeerName, err)
+		}
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, fmt.Errorf("failed to encode the manifests of %s: %w", data.PeerName, err)
+	}
+	return []ExportFile{{
+		Name:    fmt.Sprintf("%s-%s-k8s.yaml", data.InterfaceName, exportFileName(data.PeerName)),
+		Content: buffer.String(),
+		Mode:    0600,
+	}}, nil
+}
+
+// k8sName turns the name into a valid name of a Kubernetes object.
+func k8sName(name string) string {
+	name = unsafeK8sChars.ReplaceAllString(strings.ToLower(name), "-")
+	if len(name) > 253 {
+		name = name[:253]
+	}
+	return strings.Trim(name, "-.")
+}
+
+/*
+mobileConfigTplData adds the identifiers of the profile and the on-demand rules of the VPN payload.
+*/
+type mobileConfigTplData struct {
+	*ExportTplData
+	DisplayName   string
+	Identifier    string
+	ProfileUUID   string
+	VPNSubType    string
+	WgQuickConfig string
+	OnDemandRules []onDemandRule
+}
+
+type onDemandRule struct {
+	Action             string
+	InterfaceTypeMatch string
+	SSIDMatch          []string
+}
+
+// exportMobileConfig renders the configuration profile installing the tunnel in the WireGuard app of iOS or macOS.
+func exportMobileConfig(data *ExportTplData) ([]ExportFile, error) {
+	content, err := RenderClientConfig(data.ClientConfTplData)
+	if err != nil {
+		return nil, err
+	}
+	mobileConfigData := &mobileConfigTplData{
+		ExportTplData: data,
+		DisplayName:   data.Options.DisplayName,
+		Identifier:    fmt.Sprintf("com.fast-wireguard.%s.%s", data.InterfaceName, exportFileName(data.PeerName)),
+		ProfileUUID:   stableUUID(data.InterfaceName + "/" + data.PubKeyClient + "/profile"),
+		WgQuickConfig: content,
+	}
+	if mobileConfigData.DisplayName == "" {
+		mobileConfigData.DisplayName = fmt.Sprintf("%s (%s)", data.PeerName, data.InterfaceName)
+	}
+
+	// 1. The WireGuard app has one bundle identifier per platform
+	switch data.Options.Platform {
+	case "ios", "":
+		mobileConfigData.VPNSubType = "com.wireguard.ios"
+	case "macos":
+		mobileConfigData.VPNSubType = "com.wireguard.macos"
+	default:
+		return nil, fmt.Errorf("invalid platform %s, use ios or macos", data.Options.Platform)
+	}
+
+	// 2. Connect on demand, the first matching rule applies
+	switch data.Options.OnDemand {
+	case "off", "":
+	case "always":
+		mobileConfigData.OnDemandRules = []onDemandRule{{Action: "Connect"}}
+	case "untrusted":
+		if len(data.Options.TrustedSSIDs) > 0 {
+			mobileConfigData.OnDemandRules = append(mobileConfigData.OnDemandRules,
+				onDemandRule{Action: "Disconnect", InterfaceTypeMatch: "WiFi", SSIDMatch: data.Options.TrustedSSIDs})
+		}
+		mobileConfigData.OnDemandRules = append(mobileConfigData.OnDemandRules, onDemandRule{Action: "Connect"})
+	default:
+		return nil, fmt.Errorf("invalid on-demand mode %s, use off, always or untrusted", data.Options.OnDemand)
+	}
+
+	profile, err := renderExportTemplate("mobileConfig", templates.MobileConfigTpl, mobileConfigData)
+	if err != nil {
+		return nil, err
+	}
+	return []ExportFile{{
+		Name:    fmt.Sprintf("%s-%s.mobileconfig", data.InterfaceName, exportFileName(data.PeerName)),
+		Content: profile,
+		Mode:    0600,
+	}}, nil
+}
+
+// xmlEscape escapes the value for the text of an XML element.
+func xmlEscape(value string) string {
+	var buffer bytes.Buffer
+	xml.EscapeText(&buffer, []byte(value))
+	return buffer.String()
+}
+
+// netplanConfig is the netplan fragment of a WireGuard tunnel.
+type netplanConfig struct {
+	Network struct {
+		Version int                       `yaml:"version"`
+		Tunnels map[string]*netplanTunnel `yaml:"tunnels"`
+	} `yaml:"network"`
+}
+
+type netplanTunnel struct {
+	Mode        string              `yaml:"mode"`
+	Keys        map[string]string   `yaml:"keys"`
+	MTU         int                 `yaml:"mtu,omitempty"`
+	Addresses   []string            `yaml:"addresses"`
+	Nameservers *netplanNameservers `yaml:"nameservers,omitempty"`
+	Mark        int                 `yaml:"mark,omitempty"`
+	Routes      []netplanRoute      `yaml:"routes,omitempty"`
+	Policy      []netplanPolicy     `yaml:"routing-policy,omitempty"`
+	Peers       []netplanPeer       `yaml:"peers"`
+}
+
+type netplanRoute struct {
+	To    string `yaml:"to"`
+	Table int    `yaml:"table,omitempty"`
+}
+
+type netplanPolicy struct {
+	From     string `yaml:"from,omitempty"`
+	To       string `yaml:"to,omitempty"`
+	Mark     int    `yaml:"mark,omitempty"`
+	Table    int    `yaml:"table"`
+	Priority int    `yaml:"priority"`
+}
+
+type netplanNameservers struct {
+	Addresses []string `yaml:"addresses,omitempty"`
+	Search    []string `yaml:"search,omitempty"`
+}
+
+type netplanPeer struct {
+	Keys       map[string]string `yaml:"keys"`
+	AllowedIPs []string          `yaml:"allowed-ips"`
+	Endpoint   string            `yaml:"endpoint"`
+	Keepalive  int               `yaml:"keepalive"`
+}
+
+/*
+exportNetplan renders the netplan fragment of the tunnel, to copy into /etc/netplan.
+
+netplan has no rule ignoring the default routes of the main table, so a full tunnel routes its default routes
+in the table 51820 like networkd, used by the packets not marked by the tunnel except the ones to the private
+networks, which keep the routes of the main table (the LAN and the networks of the tunnel).
+*/
+func exportNetplan(data *ExportTplData) ([]ExportFile, error) {
+	const table = 51820
+	routedIPs := utils.SplitList(data.RoutedIPs)
+	tunnel := &netplanTunnel{
+		Mode:      "wireguard",
+		Keys:      map[string]string{"private": data.PriKeyClient},
+		MTU:       data.MTU,
+		Addresses: append(append([]string{}, data.IPv4Addresses...), data.IPv6Addresses...),
+		Peers: []netplanPeer{{
+			Keys:       map[string]string{"public": data.PubKeyServer},
+			AllowedIPs: routedIPs,
+			Endpoint:   data.Endpoint,
+			Keepalive:  25,
+		}},
+	}
+	if !data.FullTunnel {
+		for _, route := range routedIPs {
+			tunnel.Routes = append(tunnel.Routes, netplanRoute{To: route})
+		}
+	} else {
+		// The encrypted packets are marked so they do not enter the tunnel again
+		tunnel.Mark = table
+		for _, network := range data.Networks {
+			tunnel.Routes = append(tunnel.Routes, netplanRoute{To: network})
+		}
+		for _, route := range routedIPs {
+			tunnel.Routes = append(tunnel.Routes, netplanRoute{To: route, Table: table})
+		}
+		var families []string
+		if len(data.IPv4Routes) > 0 {
+			families = append(families, "0.0.0.0/0")
+		}
+		if len(data.IPv6Routes) > 0 {
+			families = append(families, "::/0")
+		}
+		for _, anyIP := range families {
+			tunnel.Policy = append(tunnel.Policy, netplanPolicy{From: anyIP, Mark: table, Table: mainTable, Priority: 10})
+			for _, network := range privateNetworks {
+				if strings.Contains(network, ":") == strings.Contains(anyIP, ":") {
+					tunnel.Policy = append(tunnel.Policy, netplanPolicy{To: network, Table: mainTable, Priority: 11})
+				}
+			}
+			tunnel.Policy = append(tunnel.Policy, netplanPolicy{From: anyIP, Table: table, Priority: 12})
+		}
+	}
+	if data.DNS != "" {
+		tunnel.Nameservers = &netplanNameservers{
+			Addresses: append(append([]string{}, data.IPv4DNS...), data.IPv6DNS...),
+			Search:    data.SearchDomains,
+		}
+	}
+	config := &netplanConfig{}
+	config.Network.Version = 2
+	config.Network.Tunnels = map[string]*netplanTunnel{data.InterfaceName: tunnel}
+
+	var buffer bytes.Buffer
+	buffer.WriteString("# Auto-generated by fast-wireguard: copy to /etc/netplan/ (mode 600) and run \"netplan apply\"\n")
+	if data.FullTunnel {
+		buffer.WriteString("# The default routes use the table 51820, except for the encrypted packets and the private networks\n")
+	}
+	encoder := yaml.NewEncoder(&buffer)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(config); err != nil {
+		return nil, fmt.Errorf("failed to encode the netplan configuration of %s: %w", data.PeerName, err)
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, fmt.Errorf("failed to encode the netplan configuration of %s: %w", data.PeerName, err)
+	}
+	return []ExportFile{{Name: fmt.Sprintf("90-fwg-%s.yaml", data.InterfaceName), Content: buffer.String(), Mode: 0600}}, nil
+}
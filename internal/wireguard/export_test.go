@@ -0,0 +1,338 @@
+package wireguard
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+// testExportData builds the export data of the peer "laptop" with the given addresses, routes and DNS.
+func testExportData(t *testing.T, allowedIPs string, routedIPs string, dns string) *ExportTplData {
+	t.Helper()
+	peer := PeerConfTplData{PeerName: "laptop", PubKeyClient: "cHViS2V5Q2xpZW50cHViS2V5Q2xpZW50cHViS2V5Q2w="}
+	data, err := newExportTplData("wg0", peer, &ClientConfTplData{
+		PriKeyClient: "cHJpS2V5Q2xpZW50cHJpS2V5Q2xpZW50cHJpS2V5Q2w=",
+		AllowedIPs:   allowedIPs,
+		PubKeyServer: "cHViS2V5U2VydmVycHViS2V5U2VydmVycHViS2V5U2U=",
+		RoutedIPs:    routedIPs,
+		Endpoint:     "vpn.example.com:51820",
+		MTU:          1420,
+		DNS:          dns,
+	})
+	if err != nil {
+		t.Fatalf("newExportTplData() error = %v", err)
+	}
+	data.Networks = []string{"10.0.0.0/24", "fd00::/64"}
+	data.Options = &ExportOptions{}
+	return data
+}
+
+func TestNewExportTplData(t *testing.T) {
+	tests := []struct {
+		name          string
+		allowedIPs    string
+		routedIPs     string
+		dns           string
+		wantIPv4      []string
+		wantIPv6      []string
+		wantIPv4DNS   []string
+		wantIPv6DNS   []string
+		wantSearch    []string
+		wantFull      bool
+		wantIPv6Route []string
+	}{
+		{
+			name:          "dual stack full tunnel",
+			allowedIPs:    "10.0.0.2/32, fd00::2/128",
+			routedIPs:     "0.0.0.0/0, ::/0",
+			dns:           "10.0.0.1, fd00::1, wg.internal, example.com",
+			wantIPv4:      []string{"10.0.0.2/32"},
+			wantIPv6:      []string{"fd00::2/128"},
+			wantIPv4DNS:   []string{"10.0.0.1"},
+			wantIPv6DNS:   []string{"fd00::1"},
+			wantSearch:    []string{"wg.internal", "example.com"},
+			wantFull:      true,
+			wantIPv6Route: []string{"::/0"},
+		},
+		{
+			name:       "IPv4 split tunnel without DNS",
+			allowedIPs: "10.0.0.2/32",
+			routedIPs:  "10.0.0.0/24, 192.168.1.0/24",
+			wantIPv4:   []string{"10.0.0.2/32"},
+		},
+		{
+			name:          "IPv6 only full tunnel",
+			allowedIPs:    "fd00::2/128",
+			routedIPs:     "::/0",
+			dns:           "fd00::1",
+			wantIPv6:      []string{"fd00::2/128"},
+			wantIPv6DNS:   []string{"fd00::1"},
+			wantFull:      true,
+			wantIPv6Route: []string{"::/0"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data := testExportData(t, test.allowedIPs, test.routedIPs, test.dns)
+			checks := []struct {
+				field string
+				got   []string
+				want  []string
+			}{
+				{"IPv4Addresses", data.IPv4Addresses, test.wantIPv4},
+				{"IPv6Addresses", data.IPv6Addresses, test.wantIPv6},
+				{"IPv4DNS", data.IPv4DNS, test.wantIPv4DNS},
+				{"IPv6DNS", data.IPv6DNS, test.wantIPv6DNS},
+				{"SearchDomains", data.SearchDomains, test.wantSearch},
+				{"IPv6Routes", data.IPv6Routes, test.wantIPv6Route},
+			}
+			for _, check := range checks {
+				if !slices.Equal(check.got, check.want) {
+					t.Errorf("%s = %v, want %v", check.field, check.got, check.want)
+				}
+			}
+			if data.FullTunnel != test.wantFull {
+				t.Errorf("FullTunnel = %t, want %t", data.FullTunnel, test.wantFull)
+			}
+			if data.EndpointHost != "vpn.example.com" || data.EndpointPort != 51820 {
+				t.Errorf("endpoint = %s port %d, want vpn.example.com port 51820", data.EndpointHost, data.EndpointPort)
+			}
+		})
+	}
+}
+
+func TestNewExportTplDataInvalidEndpoint(t *testing.T) {
+	peer := PeerConfTplData{PeerName: "laptop"}
+	if _, err := newExportTplData("wg0", peer, &ClientConfTplData{Endpoint: "vpn.example.com"}); err == nil {
+		t.Error("newExportTplData() accepted an endpoint without port")
+	}
+}
+
+func TestStableUUID(t *testing.T) {
+	uuidPattern := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-5[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	first := stableUUID("wg0/key")
+	if !uuidPattern.MatchString(first) {
+		t.Errorf("stableUUID() = %s, want a version 5 UUID", first)
+	}
+	if again := stableUUID("wg0/key"); again != first {
+		t.Errorf("stableUUID() = %s then %s for the same seed", first, again)
+	}
+	if other := stableUUID("wg1/key"); other == first {
+		t.Errorf("stableUUID() = %s for two seeds", other)
+	}
+}
+
+func TestK8sName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"wg0-laptop", "wg0-laptop"},
+		{"wg0-Laptop Alice", "wg0-laptop-alice"},
+		{"wg0_phone@home", "wg0-phone-home"},
+		{"-wg0.laptop.", "wg0.laptop"},
+		{strings.Repeat("a", 300), strings.Repeat("a", 253)},
+	}
+	for _, test := range tests {
+		if got := k8sName(test.name); got != test.want {
+			t.Errorf("k8sName(%q) = %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestExportQuoting(t *testing.T) {
+	tests := []struct {
+		name  string
+		quote func(string) string
+		value string
+		want  string
+	}{
+		{"routerOS plain", routerOSQuote, "laptop", `"laptop"`},
+		{"routerOS special characters", routerOSQuote, `a"b\c$d`, `"a\"b\\c\$d"`},
+		{"shell plain", shellQuote, "laptop", `'laptop'`},
+		{"shell single quote", shellQuote, "it's", `'it'\''s'`},
+		{"shell no expansion", shellQuote, "$(reboot)", `'$(reboot)'`},
+		{"xml plain", xmlEscape, "laptop", "laptop"},
+		{"xml special characters", xmlEscape, `<a & "b">`, "&lt;a &amp; &#34;b&#34;&gt;"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.quote(test.value); got != test.want {
+				t.Errorf("quote(%q) = %q, want %q", test.value, got, test.want)
+			}
+		})
+	}
+}
+
+func TestExportNetworkdFullTunnel(t *testing.T) {
+	tests := []struct {
+		name      string
+		routedIPs string
+		want      []string
+		wantNot   []string
+	}{
+		{
+			name:      "full tunnel",
+			routedIPs: "0.0.0.0/0, ::/0",
+			want: []string{
+				"FirewallMark=51820",
+				"[Route]\nDestination=0.0.0.0/0\nTable=51820",
+				"[Route]\nDestination=::/0\nTable=51820",
+				"[RoutingPolicyRule]\nTable=main\nSuppressPrefixLength=0\nFamily=both\nPriority=10",
+				"[RoutingPolicyRule]\nFirewallMark=51820\nInvertRule=yes\nTable=51820\nFamily=both\nPriority=11",
+				"Domains=~. wg.internal",
+			},
+		},
+		{
+			name:      "split tunnel",
+			routedIPs: "10.0.0.0/24",
+			want:      []string{"[Route]\nDestination=10.0.0.0/24\n", "Domains=wg.internal"},
+			wantNot:   []string{"FirewallMark", "Table=", "RoutingPolicyRule"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data := testExportData(t, "10.0.0.2/32, fd00::2/128", test.routedIPs, "10.0.0.1, wg.internal")
+			files, err := exportNetworkd(data)
+			if err != nil {
+				t.Fatalf("exportNetworkd() error = %v", err)
+			}
+			content := files[0].Content + files[1].Content
+			for _, want := range test.want {
+				if !strings.Contains(content, want) {
+					t.Errorf("the networkd files miss %q:\n%s", want, content)
+				}
+			}
+			for _, wantNot := range test.wantNot {
+				if strings.Contains(content, wantNot) {
+					t.Errorf("the networkd files contain %q:\n%s", wantNot, content)
+				}
+			}
+		})
+	}
+}
+
+func TestExportNetplanFullTunnel(t *testing.T) {
+	tests := []struct {
+		name       string
+		routedIPs  string
+		wantMark   int
+		wantRoutes []netplanRoute
+		wantPolicy []netplanPolicy
+	}{
+		{
+			name:      "full tunnel",
+			routedIPs: "0.0.0.0/0",
+			wantMark:  51820,
+			wantRoutes: []netplanRoute{
+				{To: "10.0.0.0/24"},
+				{To: "fd00::/64"},
+				{To: "0.0.0.0/0", Table: 51820},
+			},
+			wantPolicy: []netplanPolicy{
+				{From: "0.0.0.0/0", Mark: 51820, Table: mainTable, Priority: 10},
+				{To: "10.0.0.0/8", Table: mainTable, Priority: 11},
+				{To: "172.16.0.0/12", Table: mainTable, Priority: 11},
+				{To: "192.168.0.0/16", Table: mainTable, Priority: 11},
+				{From: "0.0.0.0/0", Table: 51820, Priority: 12},
+			},
+		},
+		{
+			name:       "split tunnel",
+			routedIPs:  "10.0.0.0/24, 192.168.1.0/24",
+			wantRoutes: []netplanRoute{{To: "10.0.0.0/24"}, {To: "192.168.1.0/24"}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data := testExportData(t, "10.0.0.2/32", test.routedIPs, "10.0.0.1")
+			files, err := exportNetplan(data)
+			if err != nil {
+				t.Fatalf("exportNetplan() error = %v", err)
+			}
+			var config netplanConfig
+			if err := yaml.Unmarshal([]byte(files[0].Content), &config); err != nil {
+				t.Fatalf("the netplan file is not valid YAML: %v\n%s", err, files[0].Content)
+			}
+			tunnel := config.Network.Tunnels["wg0"]
+			if tunnel == nil {
+				t.Fatalf("the netplan file has no tunnel wg0:\n%s", files[0].Content)
+			}
+			if tunnel.Mark != test.wantMark {
+				t.Errorf("mark = %d, want %d", tunnel.Mark, test.wantMark)
+			}
+			if !slices.Equal(tunnel.Routes, test.wantRoutes) {
+				t.Errorf("routes = %+v, want %+v", tunnel.Routes, test.wantRoutes)
+			}
+			if !slices.Equal(tunnel.Policy, test.wantPolicy) {
+				t.Errorf("routing-policy = %+v, want %+v", tunnel.Policy, test.wantPolicy)
+			}
+			if !slices.Equal(tunnel.Peers[0].AllowedIPs, strings.Split(test.routedIPs, ", ")) {
+				t.Errorf("allowed-ips = %v, want %s", tunnel.Peers[0].AllowedIPs, test.routedIPs)
+			}
+		})
+	}
+}
+
+func TestExportFormats(t *testing.T) {
+	for _, format := range ExportFormats() {
+		t.Run(format, func(t *testing.T) {
+			data := testExportData(t, "10.0.0.2/32, fd00::2/128", "0.0.0.0/0, ::/0", "10.0.0.1, wg.internal")
+			data.PeerName = `Laptop "Alice"`
+			data.Options = &ExportOptions{Format: format, ConfigMap: true}
+			files, err := exportFormats[format](data)
+			if err != nil {
+				t.Fatalf("export error = %v", err)
+			}
+			if len(files) == 0 {
+				t.Fatal("no file exported")
+			}
+			for _, file := range files {
+				if file.Name == "" || file.Content == "" || file.Mode == 0 {
+					t.Errorf("file %q (mode %04o) is empty or unnamed", file.Name, file.Mode)
+				}
+				if strings.Contains(file.Content, data.PriKeyClient) && file.Mode&0o007 != 0 {
+					t.Errorf("file %q holds the private key with the mode %04o", file.Name, file.Mode)
+				}
+				if strings.ContainsAny(file.Name, ` "/`) {
+					t.Errorf("file name %q is not safe", file.Name)
+				}
+			}
+
+			// The structured formats must parse back
+			content := files[0].Content
+			switch format {
+			case "k8s-secret", "netplan":
+				decoder := yaml.NewDecoder(strings.NewReader(content))
+				for {
+					var document map[string]any
+					if err := decoder.Decode(&document); err != nil {
+						if err != io.EOF {
+							t.Errorf("invalid YAML: %v\n%s", err, content)
+						}
+						break
+					}
+				}
+			case "mobileconfig":
+				decoder := xml.NewDecoder(bytes.NewReader([]byte(content)))
+				for {
+					if _, err := decoder.Token(); err != nil {
+						if err != io.EOF {
+							t.Errorf("invalid XML: %v\n%s", err, content)
+						}
+						break
+					}
+				}
+				if !strings.Contains(content, "Laptop &#34;Alice&#34;") {
+					t.Errorf("the peer name is not escaped:\n%s", content)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,250 @@
+package wireguard
+
+import (
+	"fast-wireguard/internal/tracker"
+	"fast-wireguard/pkg/utils"
+	"fmt"
+	"net"
+	"strings"
+)
+
+type ForwardOptions struct {
+	PeerName   string
+	Proto      string
+	PublicPort int
+	PeerPort   int
+}
+
+/*
+ForwardRule is a port forwarding of the settings resolved with the name and the addresses of the peer.
+
+Masquerade is set for the peers which do not send their default traffic through the tunnel, their replies
+would leave through their own uplink: the forwarded connections come from the address of the server instead.
+*/
+type ForwardRule struct {
+	PeerName          string
+	Proto             string
+	PublicPort        int
+	PeerPort          int
+	PhysicalInterface string
+	PeerIPs           []net.IP
+	Masquerade        bool
+}
+
+/*
+Commands returns the iptables commands adding ("-A") or deleting ("-D") the DNAT and forward rules.
+
+"%i" stands for the WireGuard interface, as in the PostUp and PostDown lines of wg-quick.
+*/
+func (r ForwardRule) Commands(action string) []string {
+	var commands []string
+	for _, ip := range r.PeerIPs {
+		iptables, destination := "iptables", fmt.Sprintf("%s:%d", ip, r.PeerPort)
+		if ip.To4() == nil {
+			iptables, destination = "ip6tables", fmt.Sprintf("[%s]:%d", ip, r.PeerPort)
+		}
+		commands = append(commands,
+			fmt.Sprintf("%s -t nat %s PREROUTING -i %s -p %s --dport %d -j DNAT --to-destination %s",
+				iptables, action, r.PhysicalInterface, r.Proto, r.PublicPort, destination),
+			fmt.Sprintf("%s %s FORWARD -i %s -o %%i -p %s -d %s --dport %d -j ACCEPT",
+				iptables, action, r.PhysicalInterface, r.Proto, ip, r.PeerPort),
+		)
+		if r.Masquerade {
+			commands = append(commands,
+				fmt.Sprintf("%s -t nat %s POSTROUTING -o %%i -p %s -d %s --dport %d -j MASQUERADE",
+					iptables, action, r.Proto, ip, r.PeerPort))
+		}
+	}
+	return commands
+}
+
+/*
+AddPortForward publishes a port of a peer on the physical interface of the server.
+
+The rules are written into the configuration file, so they survive restarts, and applied at once if the interface is up.
+*/
+func AddPortForward(interfaceName string, opts *ForwardOptions) error {
+	// 1. Validate the options
+	proto := strings.ToLower(opts.Proto)
+	if proto != "tcp" && proto != "udp" {
+		return fmt.Errorf("unsupported protocol %s, use tcp or udp", opts.Proto)
+	}
+	if opts.PublicPort < 1 || opts.PublicPort > 65535 || opts.PeerPort < 1 || opts.PeerPort > 65535 {
+		return fmt.Errorf("ports must be between 1 and 65535")
+	}
+	peer, err := FindPeer(interfaceName, opts.PeerName)
+	if err != nil {
+		return err
+	}
+	if len(hostIPs(peer.AllowedIPs)) == 0 {
+		return fmt.Errorf("peer %s has no tunnel address", opts.PeerName)
+	}
+
+	settings, err := loadInterfaceSettings(interfaceName)
+	if err != nil {
+		return err
+	}
+	if settings.PhysicalInterface == "" {
+		return fmt.Errorf("the physical interface of %s is unknown", interfaceName)
+	}
+	for _, forward := range settings.Forwards {
+		if forward.Proto == proto && forward.PublicPort == opts.PublicPort {
+			return fmt.Errorf("port %s/%d is already forwarded", proto, opts.PublicPort)
+		}
+	}
+
+	// 2. Apply the rules to the running interface, before anything is recorded
+	rule := ForwardRule{
+		PeerName:          peer.PeerName,
+		Proto:             proto,
+		PublicPort:        opts.PublicPort,
+		PeerPort:          opts.PeerPort,
+		PhysicalInterface: settings.PhysicalInterface,
+		PeerIPs:           hostIPs(peer.AllowedIPs),
+		Masquerade:        forwardMasquerade(settings, peer.PubKeyClient),
+	}
+	if err := applyForwardRule(interfaceName, rule, "-A"); err != nil {
+		return err
+	}
+
+	// 3. Record the forwarding and write it into the configuration file, or remove the rules again
+	settings.Forwards = append(settings.Forwards, tracker.PortForward{
+		PubKeyClient: peer.PubKeyClient,
+		Proto:        proto,
+		PublicPort:   opts.PublicPort,
+		PeerPort:     opts.PeerPort,
+	})
+	if err := tracker.SaveInterfaceSettings(interfaceName, settings); err != nil {
+		applyForwardRule(interfaceName, rule, "-D")
+		return err
+	}
+	if err := RefreshWGConfig(interfaceName); err != nil {
+		settings.Forwards = settings.Forwards[:len(settings.Forwards)-1]
+		if saveErr := tracker.SaveInterfaceSettings(interfaceName, settings); saveErr != nil {
+			fmt.Printf("Warning: failed to remove the forwarding from the settings of %s: %v\n", interfaceName, saveErr)
+		}
+		applyForwardRule(interfaceName, rule, "-D")
+		return err
+	}
+
+	fmt.Printf("✅ Port %s/%d forwarded to %s:%d\n", proto, opts.PublicPort, peer.PeerName, opts.PeerPort)
+	return nil
+}
+
+/*
+DeletePortForward removes the forwarding of the given public port.
+*/
+func DeletePortForward(interfaceName string, proto string, publicPort int) error {
+	settings, err := loadInterfaceSettings(interfaceName)
+	if err != nil {
+		return err
+	}
+	rules, err := forwardRules(interfaceName, settings)
+	if err != nil {
+		return err
+	}
+
+	proto = strings.ToLower(proto)
+	for i, forward := range settings.Forwards {
+		if forward.Proto != proto || forward.PublicPort != publicPort {
+			continue
+		}
+		settings.Forwards = append(settings.Forwards[:i], settings.Forwards[i+1:]...)
+		if err := tracker.SaveInterfaceSettings(interfaceName, settings); err != nil {
+			return err
+		}
+		if err := RefreshWGConfig(interfaceName); err != nil {
+			return err
+		}
+		if err := applyForwardRule(interfaceName, rules[i], "-D"); err != nil {
+			return err
+		}
+		fmt.Printf("✅ Forwarding of port %s/%d removed.\n", proto, publicPort)
+		return nil
+	}
+	return fmt.Errorf("port %s/%d is not forwarded", proto, publicPort)
+}
+
+/*
+ListPortForwards returns the port forwarding rules of the interface.
+*/
+func ListPortForwards(interfaceName string) ([]ForwardRule, error) {
+	settings, err := loadInterfaceSettings(interfaceName)
+	if err != nil {
+		return nil, err
+	}
+	return forwardRules(interfaceName, settings)
+}
+
+/*
+forwardRules resolves every port forwarding of the settings with the current addresses of the peers.
+
+The rules are in the same order as the settings, a rule whose peer no longer exists has no address.
+*/
+func forwardRules(interfaceName string, settings *tracker.InterfaceSettings) ([]ForwardRule, error) {
+	if len(settings.Forwards) == 0 {
+		return nil, nil
+	}
+	peers, err := parseWGPeerConfig(interfaceName)
+	if err != nil {
+		return nil, err
+	}
+
+	var rules []ForwardRule
+	for _, forward := range settings.Forwards {
+		rule := ForwardRule{
+			Proto:             forward.Proto,
+			PublicPort:        forward.PublicPort,
+			PeerPort:          forward.PeerPort,
+			PhysicalInterface: settings.PhysicalInterface,
+		}
+		for _, peer := range peers {
+			if peer.PubKeyClient == forward.PubKeyClient {
+				rule.PeerName = peer.PeerName
+				rule.PeerIPs = hostIPs(peer.AllowedIPs)
+				rule.Masquerade = forwardMasquerade(settings, peer.PubKeyClient)
+				break
+			}
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// forwardMasquerade reports whether the peer keeps its own default route: a site-to-site peer or a peer with the split route profile.
+func forwardMasquerade(settings *tracker.InterfaceSettings, pubKeyClient string) bool {
+	peerSettings := settings.Peers[pubKeyClient]
+	return peerSettings != nil && (len(peerSettings.Subnets) > 0 || peerSettings.Routes == RouteSplit)
+}
+
+// applyForwardRule runs the commands of the rule if the interface is up.
+//
+// If a rule cannot be added, the ones already added are deleted, so a failed forwarding leaves no rule behind.
+func applyForwardRule(interfaceName string, rule ForwardRule, action string) error {
+	if !IsServiceActive(interfaceName) {
+		return nil
+	}
+	commands := rule.Commands(action)
+	for i, command := range commands {
+		if err := utils.RunAsRootSilent(strings.ReplaceAll(command, "%i", interfaceName)); err != nil {
+			if action == "-A" {
+				for _, undo := range rule.Commands("-D")[:i] {
+					utils.RunAsRootSilent(strings.ReplaceAll(undo, "%i", interfaceName))
+				}
+			}
+			return fmt.Errorf("failed to run %q: %w", command, err)
+		}
+	}
+	return nil
+}
+
+/*
+FormatPortForward describes the rule for the listing (e.g. "tcp/8080 -> laptop:80").
+*/
+func FormatPortForward(rule ForwardRule) string {
+	peerName := rule.PeerName
+	if peerName == "" {
+		peerName = "<deleted peer>"
+	}
+	return fmt.Sprintf("%s/%d -> %s:%d", rule.Proto, rule.PublicPort, peerName, rule.PeerPort)
+}
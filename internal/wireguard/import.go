@@ -0,0 +1,423 @@
+package wireguard
+
+import (
+	"encoding/csv"
+	"errors"
+	"fast-wireguard/internal/system"
+	"fast-wireguard/internal/tracker"
+	"fast-wireguard/pkg/utils"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+/*
+PeerRecord is one peer of an import or export file, a row of a CSV file or an entry of a YAML list.
+
+Only the name is required to import a peer: the address is allocated and the key pair generated if missing.
+*/
+type PeerRecord struct {
+	Name       string `yaml:"name"`
+	Address    string `yaml:"address,omitempty"`
+	PublicKey  string `yaml:"public_key,omitempty"`
+	PrivateKey string `yaml:"private_key,omitempty"`
+	Subnets    string `yaml:"subnets,omitempty"`
+	Routes     string `yaml:"routes,omitempty"`
+	Owner      string `yaml:"owner,omitempty"`
+}
+
+var (
+	// Columns of the CSV files, in the order they are written
+	peerRecordColumns = []string{"name", "address", "public_key", "private_key", "subnets", "routes", "owner"}
+)
+
+// field returns the field of the record stored in the given CSV column.
+func (r *PeerRecord) field(column string) *string {
+	switch column {
+	case "name":
+		return &r.Name
+	case "address":
+		return &r.Address
+	case "public_key":
+		return &r.PublicKey
+	case "private_key":
+		return &r.PrivateKey
+	case "subnets":
+		return &r.Subnets
+	case "routes":
+		return &r.Routes
+	case "owner":
+		return &r.Owner
+	}
+	return nil
+}
+
+/*
+PeerRecordFormat returns the format of a peer file from its extension: csv or yaml.
+*/
+func PeerRecordFormat(path string) (string, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		return "csv", nil
+	case ".yaml", ".yml":
+		return "yaml", nil
+	}
+	return "", fmt.Errorf("unknown format of %s, use a .csv, .yaml or .yml file", path)
+}
+
+/*
+ReadPeerRecords reads the peers of a CSV file (with a header row naming the columns) or a YAML list.
+*/
+func ReadPeerRecords(path string) ([]PeerRecord, error) {
+	format, err := PeerRecordFormat(path)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer f.Close()
+
+	var records []PeerRecord
+	if format == "yaml" {
+		decoder := yaml.NewDecoder(f)
+		decoder.KnownFields(true)
+		if err := decoder.Decode(&records); err != nil && err != io.EOF {
+			return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+		return records, nil
+	}
+
+	reader := csv.NewReader(f)
+	reader.Comment = '#'
+	reader.TrimLeadingSpace = true
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+	header := rows[0]
+	for i, column := range header {
+		header[i] = strings.ToLower(strings.TrimSpace(column))
+		if (&PeerRecord{}).field(header[i]) == nil {
+			return nil, fmt.Errorf("unknown column %q in %s, use %s", column, path, strings.Join(peerRecordColumns, ", "))
+		}
+	}
+	for _, row := range rows[1:] {
+		var record PeerRecord
+		for i, value := range row {
+			*record.field(header[i]) = strings.TrimSpace(value)
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+/*
+WritePeerRecords writes the peers in the given format: csv (with a header row) or yaml.
+*/
+func WritePeerRecords(w io.Writer, records []PeerRecord, format string) error {
+	if format == "yaml" {
+		encoder := yaml.NewEncoder(w)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(records); err != nil {
+			return fmt.Errorf("failed to write the peers: %w", err)
+		}
+		return encoder.Close()
+	}
+
+	writer := csv.NewWriter(w)
+	writer.Write(peerRecordColumns)
+	for _, record := range records {
+		row := make([]string, len(peerRecordColumns))
+		for i, column := range peerRecordColumns {
+			row[i] = *record.field(column)
+		}
+		writer.Write(row)
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("failed to write the peers: %w", err)
+	}
+	return nil
+}
+
+/*
+ImportPeers adds the peers to the interface in one transaction and returns them as added, with their
+addresses and keys.
+
+The peers without an address get defaultAddress (with [auto-ipv4] and [auto-ipv6] placeholders).
+Every entry is checked before anything is written: if one is invalid, none is added and the errors of
+all the entries are returned. The service is not reloaded.
+*/
+func ImportPeers(interfaceName string, records []PeerRecord, defaultAddress string) ([]PeerRecord, error) {
+	if len(records) == 0 {
+		return nil, fmt.Errorf("no peer to import")
+	}
+
+	// 1. Load the interface and its peers
+	settings, err := loadInterfaceSettings(interfaceName)
+	if err != nil {
+		return nil, err
+	}
+	peers, err := parseWGPeerConfig(interfaceName)
+	if err != nil {
+		return nil, err
+	}
+	configPath := filepath.Join(wgConfigDir, fmt.Sprintf("%s.conf", interfaceName))
+	original, err := os.ReadFile(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read configuration file: %w", err)
+	}
+	if settings.Peers == nil {
+		settings.Peers = make(map[string]*tracker.PeerSettings)
+	}
+	names := make(map[string]bool)
+	keys := make(map[string]bool)
+	usedIPs := make(map[string]string)
+	for _, peer := range peers {
+		names[peer.PeerName] = true
+		keys[peer.PubKeyClient] = true
+		for _, ip := range hostIPs(peer.AllowedIPs) {
+			usedIPs[ip.String()] = peer.PeerName
+		}
+	}
+
+	// 2. Check every entry and allocate its addresses, as if the entries before it were added
+	family := system.Family(settings.Family)
+	results := make([]PeerRecord, 0, len(records))
+	var added []PeerConfTplData
+	var sections []byte
+	var routedSubnets []string
+	var errs []error
+	for i, record := range records {
+		result, err := importPeer(settings, record, defaultAddress, family, peers, names, keys, usedIPs)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("entry %d (%s): %w", i+1, record.Name, err))
+			continue
+		}
+		peer := PeerConfTplData{
+			PeerName:     result.Name,
+			PubKeyClient: result.PublicKey,
+			AllowedIPs:   strings.Join(append([]string{result.Address}, utils.SplitList(result.Subnets)...), ", "),
+		}
+		section, err := renderWGPeerConfig(peer)
+		if err != nil {
+			return nil, err
+		}
+		sections = append(sections, section...)
+		peers = append(peers, peer)
+		added = append(added, peer)
+		routedSubnets = append(routedSubnets, utils.SplitList(result.Subnets)...)
+		results = append(results, result)
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("%d of %d entries are invalid, no peer added:\n%w", len(errs), len(records), errors.Join(errs...))
+	}
+
+	// 3. Write the peers to the configuration file at once, and restore it if the settings cannot be saved
+	tmpPath := configPath + ".tmp"
+	if err := os.WriteFile(tmpPath, append(original, sections...), 0600); err != nil {
+		return nil, fmt.Errorf("failed to write configuration file: %w", err)
+	}
+	if err := os.Rename(tmpPath, configPath); err != nil {
+		os.Remove(tmpPath)
+		return nil, fmt.Errorf("failed to write configuration file: %w", err)
+	}
+	if err := tracker.SaveInterfaceSettings(interfaceName, settings); err != nil {
+		if restoreErr := os.WriteFile(configPath, original, 0600); restoreErr != nil {
+			fmt.Printf("Warning: failed to restore %s: %v\n", configPath, restoreErr)
+		}
+		return nil, err
+	}
+	fmt.Printf("✅ %d peers added to %s\n", len(results), configPath)
+
+	// 4. Answer the neighbor solicitations of the new peers and route the subnets of the new sites
+	if settings.IPv6.NDPProxy {
+		if err := RefreshWGConfig(interfaceName); err != nil {
+			return nil, err
+		}
+		if err := applyNDPProxy(interfaceName, settings, ndpProxyIPs(settings, added), "add"); err != nil {
+			fmt.Printf("Warning: %v\n", err)
+		}
+	}
+	if len(routedSubnets) > 0 {
+		if err := applySiteRoutes(interfaceName, routedSubnets, "replace"); err != nil {
+			fmt.Printf("Warning: %v\n", err)
+		}
+		fmt.Printf("✅ Subnets %s routed to the new site-to-site peers.\n", strings.Join(routedSubnets, ", "))
+	}
+	return results, nil
+}
+
+/*
+importPeer checks one entry against the interface and the entries before it, and records it in the settings
+and the maps of the names, keys and addresses in use.
+
+Returns the entry as added, with its addresses and keys.
+*/
+func importPeer(
+	settings *tracker.InterfaceSettings,
+	record PeerRecord,
+	defaultAddress string,
+	family system.Family,
+	peers []PeerConfTplData,
+	names map[string]bool,
+	keys map[string]bool,
+	usedIPs map[string]string,
+) (PeerRecord, error) {
+	// 1. Check the name, the route profile and the keys
+	name := strings.TrimSpace(record.Name)
+	if name == "" {
+		return PeerRecord{}, fmt.Errorf("the peer has no name")
+	}
+	if strings.ContainsFunc(name, func(r rune) bool { return r < ' ' }) {
+		return PeerRecord{}, fmt.Errorf("invalid name %q", name)
+	}
+	if names[name] {
+		return PeerRecord{}, fmt.Errorf("a peer named %s already exists", name)
+	}
+	routes, err := ParseRouteProfile(record.Routes)
+	if err != nil {
+		return PeerRecord{}, err
+	}
+	pubKeyClient, priKeyClient := record.PublicKey, record.PrivateKey
+	if pubKeyClient != "" {
+		if !isValidKey(pubKeyClient) {
+			return PeerRecord{}, fmt.Errorf("invalid public key %q", pubKeyClient)
+		}
+		if keys[pubKeyClient] {
+			return PeerRecord{}, fmt.Errorf("a peer with the public key %s already exists", pubKeyClient)
+		}
+	} else if priKeyClient != "" {
+		return PeerRecord{}, fmt.Errorf("a private key needs its public key")
+	}
+	if priKeyClient != "" && !isValidKey(priKeyClient) {
+		return PeerRecord{}, fmt.Errorf("invalid private key")
+	}
+
+	// 2. Allocate the addresses of the families carried by the tunnel
+	address := record.Address
+	if address == "" {
+		address = defaultAddress
+	}
+	addresses := family.FilterAddresses(address)
+	if addresses == "" {
+		return PeerRecord{}, fmt.Errorf("no %s address in %q", family, address)
+	}
+	if isRoutedIPv6(settings) {
+		if addresses, err = allocateRoutedIPv6(addresses, settings, peers); err != nil {
+			return PeerRecord{}, err
+		}
+	}
+	addresses = fillAutoAddresses(addresses, peers)
+	if strings.Contains(addresses, "[auto-") {
+		return PeerRecord{}, fmt.Errorf("no free address left for %s", address)
+	}
+	for _, part := range utils.SplitList(addresses) {
+		if _, _, err := net.ParseCIDR(part); err != nil {
+			return PeerRecord{}, fmt.Errorf("invalid address %s: %w", part, err)
+		}
+	}
+	ips := hostIPs(addresses)
+	for _, ip := range ips {
+		if owner, used := usedIPs[ip.String()]; used {
+			return PeerRecord{}, fmt.Errorf("the address %s is already used by %s", ip, owner)
+		}
+	}
+	subnets, err := parseSubnets(utils.SplitList(record.Subnets), settings, pubKeyClient)
+	if err != nil {
+		return PeerRecord{}, err
+	}
+
+	// 3. Generate the key pair of the peer if necessary
+	if pubKeyClient == "" {
+		if priKeyClient, pubKeyClient, err = GenerateWGKeyPair(); err != nil {
+			return PeerRecord{}, err
+		}
+	}
+
+	// 4. Record the peer
+	names[name] = true
+	keys[pubKeyClient] = true
+	for _, ip := range ips {
+		usedIPs[ip.String()] = name
+	}
+	settings.Peers[pubKeyClient] = &tracker.PeerSettings{
+		Name:         name,
+		PriKeyClient: priKeyClient,
+		Subnets:      subnets,
+		Routes:       routes,
+		Owner:        record.Owner,
+	}
+	return PeerRecord{
+		Name:       name,
+		Address:    addresses,
+		PublicKey:  pubKeyClient,
+		PrivateKey: priKeyClient,
+		Subnets:    strings.Join(subnets, ", "),
+		Routes:     routes,
+		Owner:      record.Owner,
+	}, nil
+}
+
+/*
+ExportPeerRecords returns the peers (given by name or public key, all if none given) of the interface
+as records, which can be imported again. The private keys are left out.
+*/
+func ExportPeerRecords(interfaceName string, peerNames []string) ([]PeerRecord, error) {
+	settings, err := loadInterfaceSettings(interfaceName)
+	if err != nil {
+		return nil, err
+	}
+	if len(peerNames) == 0 {
+		peers, err := parseWGPeerConfig(interfaceName)
+		if err != nil {
+			return nil, err
+		}
+		for _, peer := range peers {
+			peerNames = append(peerNames, peer.PubKeyClient)
+		}
+	}
+
+	var records []PeerRecord
+	for _, peerName := range peerNames {
+		peer, err := FindPeer(interfaceName, peerName)
+		if err != nil {
+			return nil, err
+		}
+		record := PeerRecord{
+			Name:      peer.PeerName,
+			PublicKey: peer.PubKeyClient,
+			Routes:    RouteFull,
+		}
+		var subnets []string
+		if peerSettings := settings.Peers[peer.PubKeyClient]; peerSettings != nil {
+			subnets = peerSettings.Subnets
+			if peerSettings.Routes != "" {
+				record.Routes = peerSettings.Routes
+			}
+			record.Owner = peerSettings.Owner
+		}
+		// The subnets behind the peer are not addresses of its interface
+		var addresses []string
+		for _, part := range utils.SplitList(peer.AllowedIPs) {
+			if !slices.Contains(subnets, part) {
+				addresses = append(addresses, part)
+			}
+		}
+		record.Address = strings.Join(addresses, ", ")
+		record.Subnets = strings.Join(subnets, ", ")
+		records = append(records, record)
+	}
+	return records, nil
+}
@@ -0,0 +1,286 @@
+package wireguard
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fast-wireguard/internal/tracker"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testKey returns a valid WireGuard key made of the given byte.
+func testKey(b byte) string {
+	return base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{b}, 32))
+}
+
+// testImportInterface creates the interface wg0 in temporary directories, with the peer laptop at 10.8.0.2.
+// Returns the path of its configuration file.
+func testImportInterface(t *testing.T) string {
+	t.Helper()
+	configDir, settingsDir := wgConfigDir, tracker.SettingsDir
+	wgConfigDir, tracker.SettingsDir = t.TempDir(), t.TempDir()
+	t.Cleanup(func() { wgConfigDir, tracker.SettingsDir = configDir, settingsDir })
+
+	configPath := filepath.Join(wgConfigDir, "wg0.conf")
+	config := "[Interface]\nAddress = 10.8.0.1/24\nListenPort = 51820\nPrivateKey = " + testKey(1) + "\n\n" +
+		"[Peer]\n# Peer name laptop\nPublicKey = " + testKey(2) + "\nAllowedIPs = 10.8.0.2/32\n"
+	if err := os.WriteFile(configPath, []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+	settings := &tracker.InterfaceSettings{
+		Family: "ipv4",
+		Peers:  map[string]*tracker.PeerSettings{testKey(2): {Name: "laptop"}},
+	}
+	if err := tracker.SaveInterfaceSettings("wg0", settings); err != nil {
+		t.Fatal(err)
+	}
+	return configPath
+}
+
+func TestReadPeerRecords(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		want    []PeerRecord
+		wantErr string
+	}{
+		{
+			name:    "csv columns in any order and case",
+			file:    "peers.csv",
+			content: "Public_Key, NAME ,address\n" + testKey(3) + ", phone , 10.8.0.3/32\n",
+			want:    []PeerRecord{{Name: "phone", Address: "10.8.0.3/32", PublicKey: testKey(3)}},
+		},
+		{
+			name:    "csv all columns",
+			file:    "peers.csv",
+			content: "name,address,public_key,private_key,subnets,routes,owner\nsite,10.8.0.4/32,pub,pri,\"192.168.10.0/24, 192.168.11.0/24\",split,alice\n",
+			want: []PeerRecord{{
+				Name: "site", Address: "10.8.0.4/32", PublicKey: "pub", PrivateKey: "pri",
+				Subnets: "192.168.10.0/24, 192.168.11.0/24", Routes: "split", Owner: "alice",
+			}},
+		},
+		{
+			name:    "csv comments",
+			file:    "peers.csv",
+			content: "# exported by fwg\nname,owner\n# phone,bob\ntablet,alice\n",
+			want:    []PeerRecord{{Name: "tablet", Owner: "alice"}},
+		},
+		{
+			name:    "csv header only",
+			file:    "peers.csv",
+			content: "name,address\n",
+		},
+		{
+			name: "empty csv",
+			file: "peers.CSV",
+		},
+		{
+			name:    "csv unknown column",
+			file:    "peers.csv",
+			content: "name,email\nphone,bob@example.com\n",
+			wantErr: `unknown column "email"`,
+		},
+		{
+			name:    "csv missing field",
+			file:    "peers.csv",
+			content: "name,address\nphone\n",
+			wantErr: "wrong number of fields",
+		},
+		{
+			name:    "yaml list",
+			file:    "peers.yaml",
+			content: "# exported by fwg\n- name: phone\n  address: 10.8.0.3/32\n  routes: split\n- name: tablet\n  owner: alice\n",
+			want:    []PeerRecord{{Name: "phone", Address: "10.8.0.3/32", Routes: "split"}, {Name: "tablet", Owner: "alice"}},
+		},
+		{
+			name: "empty yaml",
+			file: "peers.yml",
+		},
+		{
+			name:    "yaml unknown field",
+			file:    "peers.yml",
+			content: "- name: phone\n  email: bob@example.com\n",
+			wantErr: "field email not found",
+		},
+		{
+			name:    "yaml not a list",
+			file:    "peers.yaml",
+			content: "name: phone\n",
+			wantErr: "failed to parse",
+		},
+		{
+			name:    "unknown format",
+			file:    "peers.txt",
+			content: "phone\n",
+			wantErr: "unknown format",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), test.file)
+			if err := os.WriteFile(path, []byte(test.content), 0600); err != nil {
+				t.Fatal(err)
+			}
+			got, err := ReadPeerRecords(path)
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("ReadPeerRecords() error = %v, want %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ReadPeerRecords() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("ReadPeerRecords() = %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestImportPeersInvalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		records  []PeerRecord
+		wantErrs []string
+	}{
+		{
+			name:     "no entry",
+			wantErrs: []string{"no peer to import"},
+		},
+		{
+			name:     "duplicate name across rows",
+			records:  []PeerRecord{{Name: "phone", PublicKey: testKey(3)}, {Name: "phone", PublicKey: testKey(4)}},
+			wantErrs: []string{"1 of 2 entries", "entry 2 (phone): a peer named phone already exists"},
+		},
+		{
+			name:     "name of an existing peer",
+			records:  []PeerRecord{{Name: "laptop", PublicKey: testKey(3)}},
+			wantErrs: []string{"entry 1 (laptop): a peer named laptop already exists"},
+		},
+		{
+			name:     "duplicate key across rows",
+			records:  []PeerRecord{{Name: "phone", PublicKey: testKey(3)}, {Name: "tablet", PublicKey: testKey(3)}},
+			wantErrs: []string{"entry 2 (tablet): a peer with the public key " + testKey(3) + " already exists"},
+		},
+		{
+			name:     "key of an existing peer",
+			records:  []PeerRecord{{Name: "phone", PublicKey: testKey(2)}},
+			wantErrs: []string{"entry 1 (phone): a peer with the public key"},
+		},
+		{
+			name: "duplicate address across rows",
+			records: []PeerRecord{
+				{Name: "phone", Address: "10.8.0.3/32", PublicKey: testKey(3)},
+				{Name: "tablet", Address: "10.8.0.3/32", PublicKey: testKey(4)},
+			},
+			wantErrs: []string{"entry 2 (tablet): the address 10.8.0.3 is already used by phone"},
+		},
+		{
+			name:     "address of an existing peer",
+			records:  []PeerRecord{{Name: "phone", Address: "10.8.0.2/32", PublicKey: testKey(3)}},
+			wantErrs: []string{"entry 1 (phone): the address 10.8.0.2 is already used by laptop"},
+		},
+		{
+			name:     "private key without public key",
+			records:  []PeerRecord{{Name: "phone", PrivateKey: testKey(5)}},
+			wantErrs: []string{"entry 1 (phone): a private key needs its public key"},
+		},
+		{
+			name: "every invalid entry reported",
+			records: []PeerRecord{
+				{Name: "", PublicKey: testKey(3)},
+				{Name: "phone", PublicKey: testKey(4)},
+				{Name: "tablet", PublicKey: "invalid"},
+				{Name: "site", PublicKey: testKey(5), Routes: "local"},
+			},
+			wantErrs: []string{
+				"3 of 4 entries",
+				"entry 1 (): the peer has no name",
+				`entry 3 (tablet): invalid public key "invalid"`,
+				"entry 4 (site): invalid route profile local",
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			configPath := testImportInterface(t)
+			settingsPath := filepath.Join(tracker.SettingsDir, ".fwg_wg0.json")
+			config, _ := os.ReadFile(configPath)
+			settings, _ := os.ReadFile(settingsPath)
+
+			results, err := ImportPeers("wg0", test.records, "10.8.0.[auto-ipv4]/32")
+			if err == nil {
+				t.Fatalf("ImportPeers() = %+v, want an error", results)
+			}
+			for _, want := range test.wantErrs {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("ImportPeers() error = %v, want %q", err, want)
+				}
+			}
+
+			// No peer is added if any entry is invalid
+			if got, _ := os.ReadFile(configPath); !bytes.Equal(got, config) {
+				t.Errorf("the configuration file changed:\n%s", got)
+			}
+			if got, _ := os.ReadFile(settingsPath); !bytes.Equal(got, settings) {
+				t.Errorf("the settings changed:\n%s", got)
+			}
+		})
+	}
+}
+
+func TestImportPeers(t *testing.T) {
+	configPath := testImportInterface(t)
+	records := []PeerRecord{
+		{Name: "phone", PublicKey: testKey(3), PrivateKey: testKey(4), Owner: "alice"},
+		{Name: "tablet", Address: "10.8.0.4/32", PublicKey: testKey(5), Routes: "split"},
+		{Name: "desktop", PublicKey: testKey(6)},
+	}
+	results, err := ImportPeers("wg0", records, "10.8.0.[auto-ipv4]/32")
+	if err != nil {
+		t.Fatalf("ImportPeers() error = %v", err)
+	}
+
+	// 1. The automatic addresses skip the ones of the existing peers and of the entries before
+	want := []PeerRecord{
+		{Name: "phone", Address: "10.8.0.3/32", PublicKey: testKey(3), PrivateKey: testKey(4), Routes: RouteFull, Owner: "alice"},
+		{Name: "tablet", Address: "10.8.0.4/32", PublicKey: testKey(5), Routes: RouteSplit},
+		{Name: "desktop", Address: "10.8.0.5/32", PublicKey: testKey(6), Routes: RouteFull},
+	}
+	if !reflect.DeepEqual(results, want) {
+		t.Fatalf("ImportPeers() = %+v, want %+v", results, want)
+	}
+
+	// 2. The peers are appended to the configuration file
+	peers, err := parseWGPeerConfig("wg0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantPeers := []PeerConfTplData{{PeerName: "laptop", PubKeyClient: testKey(2), AllowedIPs: "10.8.0.2/32"}}
+	for _, result := range want {
+		wantPeers = append(wantPeers, PeerConfTplData{PeerName: result.Name, PubKeyClient: result.PublicKey, AllowedIPs: result.Address})
+	}
+	if !reflect.DeepEqual(peers, wantPeers) {
+		t.Errorf("peers of %s = %+v, want %+v", configPath, peers, wantPeers)
+	}
+
+	// 3. The settings of the peers are saved
+	settings, err := tracker.LoadInterfaceSettings("wg0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantSettings := map[string]*tracker.PeerSettings{
+		testKey(2): {Name: "laptop"},
+		testKey(3): {Name: "phone", PriKeyClient: testKey(4), Routes: RouteFull, Owner: "alice"},
+		testKey(5): {Name: "tablet", Routes: RouteSplit},
+		testKey(6): {Name: "desktop", Routes: RouteFull},
+	}
+	if !reflect.DeepEqual(settings.Peers, wantSettings) {
+		t.Errorf("settings of the peers = %+v, want %+v", settings.Peers, wantSettings)
+	}
+}
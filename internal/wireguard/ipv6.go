@@ -0,0 +1,254 @@
+package wireguard
+
+import (
+	"fast-wireguard/internal/tracker"
+	"fast-wireguard/pkg/utils"
+	"fmt"
+	"math/big"
+	"net"
+	"strings"
+)
+
+const (
+	ipv6ModeRouted = "routed"
+)
+
+// isRoutedIPv6 reports whether the peers of the interface have global IPv6 addresses instead of NAT66.
+func isRoutedIPv6(settings *tracker.InterfaceSettings) bool {
+	return settings.IPv6.Mode == ipv6ModeRouted
+}
+
+/*
+newRoutedIPv6Settings validates the global prefix of the routed mode.
+
+ndpProxy is "on", "off" or "auto", which proxies the neighbor discovery if the prefix is on-link on the
+physical interface, i.e. the upstream router expects the addresses to be there instead of routing the prefix.
+*/
+func newRoutedIPv6Settings(prefix string, delegatedLength int, ndpProxy string, physicalInterface string) (tracker.IPv6Settings, error) {
+	ip, ipNet, err := net.ParseCIDR(prefix)
+	if err != nil {
+		return tracker.IPv6Settings{}, fmt.Errorf("invalid IPv6 prefix %s: %w", prefix, err)
+	}
+	if ip.To4() != nil || !ip.IsGlobalUnicast() || ip.IsPrivate() {
+		return tracker.IPv6Settings{}, fmt.Errorf("%s is not a global IPv6 prefix", prefix)
+	}
+	ones, _ := ipNet.Mask.Size()
+	if delegatedLength != 0 && (delegatedLength <= ones || delegatedLength > 128) {
+		return tracker.IPv6Settings{}, fmt.Errorf("the delegated prefix length must be between %d and 128", ones+1)
+	}
+
+	var proxy bool
+	switch ndpProxy {
+	case "on":
+		proxy = true
+	case "off":
+		proxy = false
+	case "auto", "":
+		proxy = isOnLink(physicalInterface, ipNet)
+	default:
+		return tracker.IPv6Settings{}, fmt.Errorf("invalid NDP proxy mode %s, use auto, on or off", ndpProxy)
+	}
+	if proxy && delegatedLength != 0 {
+		return tracker.IPv6Settings{}, fmt.Errorf("the NDP proxy only answers for single addresses, route %s to the server to delegate prefixes", ipNet)
+	}
+
+	return tracker.IPv6Settings{
+		Mode:            ipv6ModeRouted,
+		Prefix:          ipNet.String(),
+		DelegatedLength: delegatedLength,
+		NDPProxy:        proxy,
+	}, nil
+}
+
+// isOnLink reports whether the physical interface has an address in the prefix.
+func isOnLink(physicalInterface string, prefix *net.IPNet) bool {
+	for _, ip := range interfaceIPs(physicalInterface) {
+		if prefix.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+routedServerAddress replaces the IPv6 addresses of the server by the first address of the routed prefix.
+
+With the NDP proxy, the prefix is on-link on the physical interface: the server takes a single address
+(/128), so the prefix is not routed to the tunnel too, skipping the first one (usually the router) and
+the addresses of the physical interface.
+*/
+func routedServerAddress(address string, ipv6 tracker.IPv6Settings, physicalInterface string) string {
+	_, prefix, err := net.ParseCIDR(ipv6.Prefix)
+	if err != nil {
+		return address
+	}
+	var addresses []string
+	for _, part := range utils.SplitList(address) {
+		if !strings.Contains(part, ":") {
+			addresses = append(addresses, part)
+		}
+	}
+	if ipv6.NDPProxy {
+		used := make(map[string]bool)
+		for _, ip := range interfaceIPs(physicalInterface) {
+			used[ip.String()] = true
+		}
+		for i := int64(2); ; i++ {
+			candidate := offsetIP(prefix.IP, big.NewInt(i))
+			if !prefix.Contains(candidate) {
+				return strings.Join(addresses, ", ")
+			}
+			if !used[candidate.String()] {
+				addresses = append(addresses, fmt.Sprintf("%s/128", candidate))
+				return strings.Join(addresses, ", ")
+			}
+		}
+	}
+	ones, _ := prefix.Mask.Size()
+	addresses = append(addresses, fmt.Sprintf("%s/%d", offsetIP(prefix.IP, big.NewInt(1)), ones))
+	return strings.Join(addresses, ", ")
+}
+
+// interfaceIPs returns the addresses of the physical interface.
+func interfaceIPs(physicalInterface string) []net.IP {
+	iface, err := net.InterfaceByName(physicalInterface)
+	if err != nil {
+		return nil
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return nil
+	}
+	var ips []net.IP
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok {
+			ips = append(ips, ipNet.IP)
+		}
+	}
+	return ips
+}
+
+/*
+allocateRoutedIPv6 replaces the "[auto-ipv6]" addresses of the client by the first free address of the
+routed prefix, or the first free delegated prefix.
+
+The network address and the first address (or the first delegated prefix) are never allocated, nor the
+addresses of the server and of its physical interface (in the on-link prefix of the NDP proxy).
+*/
+func allocateRoutedIPv6(addresses string, settings *tracker.InterfaceSettings, peers []PeerConfTplData) (string, error) {
+	if !strings.Contains(addresses, "[auto-ipv6]") {
+		return addresses, nil
+	}
+	_, prefix, err := net.ParseCIDR(settings.IPv6.Prefix)
+	if err != nil {
+		return "", fmt.Errorf("invalid routed IPv6 prefix %s: %w", settings.IPv6.Prefix, err)
+	}
+	ones, bits := prefix.Mask.Size()
+
+	// 1. Collect the addresses (or prefixes) already given to the peers
+	length, first := bits, int64(2)
+	if settings.IPv6.DelegatedLength != 0 {
+		length, first = settings.IPv6.DelegatedLength, 1
+	}
+	used := make(map[string]bool)
+	taken := append(hostIPs(settings.Address), interfaceIPs(settings.PhysicalInterface)...)
+	for _, peer := range peers {
+		for _, part := range utils.SplitList(peer.AllowedIPs) {
+			if ip, _, err := net.ParseCIDR(part); err == nil {
+				taken = append(taken, ip)
+			}
+		}
+	}
+	for _, ip := range taken {
+		if prefix.Contains(ip) {
+			used[ip.Mask(net.CIDRMask(length, bits)).String()] = true
+		}
+	}
+
+	// 2. Take the first free one
+	step := new(big.Int).Lsh(big.NewInt(1), uint(bits-length))
+	count := new(big.Int).Lsh(big.NewInt(1), uint(length-ones))
+	var allocated string
+	for i := big.NewInt(first); i.Cmp(count) < 0; i.Add(i, big.NewInt(1)) {
+		candidate := offsetIP(prefix.IP, new(big.Int).Mul(i, step))
+		if !used[candidate.String()] {
+			allocated = fmt.Sprintf("%s/%d", candidate, length)
+			break
+		}
+	}
+	if allocated == "" {
+		return "", fmt.Errorf("no free address left in %s", prefix)
+	}
+
+	var result []string
+	for _, part := range utils.SplitList(addresses) {
+		if strings.Contains(part, "[auto-ipv6]") {
+			part = allocated
+		}
+		result = append(result, part)
+	}
+	return strings.Join(result, ", "), nil
+}
+
+// offsetIP adds the offset to the IP address.
+func offsetIP(ip net.IP, offset *big.Int) net.IP {
+	value := new(big.Int).SetBytes(ip.To16())
+	value.Add(value, offset)
+	result := make(net.IP, net.IPv6len)
+	value.FillBytes(result)
+	return result
+}
+
+/*
+clientAddresses returns the addresses of the interface of the client from its AllowedIPs on the server.
+
+A delegated IPv6 prefix is routed to the client, which takes the first address of it for itself.
+*/
+func clientAddresses(allowedIPs string) string {
+	var addresses []string
+	for _, part := range utils.SplitList(allowedIPs) {
+		ip, ipNet, err := net.ParseCIDR(part)
+		if err == nil && ip.To4() == nil && ip.Equal(ipNet.IP) {
+			if ones, bits := ipNet.Mask.Size(); ones < bits {
+				part = fmt.Sprintf("%s/%d", offsetIP(ipNet.IP, big.NewInt(1)), bits)
+			}
+		}
+		addresses = append(addresses, part)
+	}
+	return strings.Join(addresses, ", ")
+}
+
+/*
+ndpProxyIPs returns the addresses of the peers in the routed prefix, answered by the server on the physical interface.
+*/
+func ndpProxyIPs(settings *tracker.InterfaceSettings, peers []PeerConfTplData) []net.IP {
+	if !isRoutedIPv6(settings) || !settings.IPv6.NDPProxy {
+		return nil
+	}
+	_, prefix, err := net.ParseCIDR(settings.IPv6.Prefix)
+	if err != nil {
+		return nil
+	}
+	var ips []net.IP
+	for _, peer := range peers {
+		for _, ip := range hostIPs(peer.AllowedIPs) {
+			if prefix.Contains(ip) {
+				ips = append(ips, ip)
+			}
+		}
+	}
+	return ips
+}
+
+// applyNDPProxy adds ("add") or deletes ("del") the proxy entries of the addresses if the interface is up.
+func applyNDPProxy(interfaceName string, settings *tracker.InterfaceSettings, ips []net.IP, action string) error {
+	if !IsServiceActive(interfaceName) {
+		return nil
+	}
+	for _, ip := range ips {
+		if err := utils.RunAsRootSilent("ip", "-6", "neigh", action, "proxy", ip.String(), "dev", settings.PhysicalInterface); err != nil {
+			return fmt.Errorf("failed to %s the NDP proxy entry of %s: %w", action, ip, err)
+		}
+	}
+	return nil
+}
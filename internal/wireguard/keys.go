@@ -48,3 +48,36 @@ func GenerateWGKeys(interfaceName string) (string, string, error) {
 	publicKey := strings.TrimSpace(string(pubKeyBytes))
 	return privateKey, publicKey, nil
 }
+
+/*
+GenerateWGKeyPair generates a WireGuard private and public key pair for a client peer without writing any file.
+
+Returns the private key, public key, and an error if any operation fails.
+*/
+func GenerateWGKeyPair() (string, string, error) {
+	priKeyBytes, err := exec.Command("wg", "genkey").Output()
+	if err != nil {
+		return "", "", fmt.Errorf("Failed to generate private key for wireguard: %w", err)
+	}
+
+	cmdPubKey := exec.Command("wg", "pubkey")
+	cmdPubKey.Stdin = bytes.NewReader(priKeyBytes)
+	pubKeyBytes, err := cmdPubKey.Output()
+	if err != nil {
+		return "", "", fmt.Errorf("Failed to generate public key for wireguard: %w", err)
+	}
+
+	return strings.TrimSpace(string(priKeyBytes)), strings.TrimSpace(string(pubKeyBytes)), nil
+}
+
+/*
+ReadServerPublicKey reads the public key of the given interface written by GenerateWGKeys.
+*/
+func ReadServerPublicKey(interfaceName string) (string, error) {
+	pubKeyPath := filepath.Join(configDir, fmt.Sprintf("%s.pub", interfaceName))
+	pubKeyBytes, err := os.ReadFile(pubKeyPath)
+	if err != nil {
+		return "", fmt.Errorf("Failed to read public key from %s: %w", pubKeyPath, err)
+	}
+	return strings.TrimSpace(string(pubKeyBytes)), nil
+}
@@ -0,0 +1,389 @@
+package wireguard
+
+import (
+	"bytes"
+	"fast-wireguard/internal/templates"
+	"fast-wireguard/internal/tracker"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+var (
+	meshConfigDir = "/etc/wireguard/mesh"
+	// Names of the meshes are interface names, names of the nodes are file names
+	meshNamePattern = regexp.MustCompile(`^[a-zA-Z0-9_=+.-]{1,15}$`)
+	nodeNamePattern = regexp.MustCompile(`^[a-zA-Z0-9_.-]+$`)
+)
+
+/*
+MeshNodeSpec describes a node to add to a mesh, the address is allocated if empty.
+*/
+type MeshNodeSpec struct {
+	Name     string
+	Endpoint string
+	Address  string
+}
+
+type MeshConfTplData struct {
+	MeshName   string
+	NodeName   string
+	PrivateKey string
+	Address    string
+	ListenPort int
+	MTU        int
+	Peers      []MeshPeerTplData
+}
+
+type MeshPeerTplData struct {
+	Name       string
+	PublicKey  string
+	AllowedIPs string
+	Endpoint   string
+}
+
+/*
+ParseMeshNode parses a node given as "name,endpoint,address", the endpoint and the address are optional.
+*/
+func ParseMeshNode(spec string) (MeshNodeSpec, error) {
+	parts := strings.Split(spec, ",")
+	if len(parts) > 3 {
+		return MeshNodeSpec{}, fmt.Errorf("invalid node %q, use name,endpoint,address", spec)
+	}
+	for len(parts) < 3 {
+		parts = append(parts, "")
+	}
+	return MeshNodeSpec{
+		Name:     strings.TrimSpace(parts[0]),
+		Endpoint: strings.TrimSpace(parts[1]),
+		Address:  strings.TrimSpace(parts[2]),
+	}, nil
+}
+
+/*
+CreateMesh records a new mesh with its nodes and generates the configuration of every node.
+*/
+func CreateMesh(meshName string, network string, listenPort int, mtu int, nodes []MeshNodeSpec) error {
+	// 1. Validate the mesh
+	if !meshNamePattern.MatchString(meshName) {
+		return fmt.Errorf("invalid mesh name %s, it is used as interface name (at most 15 letters, digits or _=+.-)", meshName)
+	}
+	if tracker.MeshExists(meshName) {
+		return fmt.Errorf("the mesh %s already exists", meshName)
+	}
+	_, ipNet, err := net.ParseCIDR(network)
+	if err != nil {
+		return fmt.Errorf("invalid mesh network %s: %w", network, err)
+	}
+	mesh := &tracker.MeshSettings{
+		Name:       meshName,
+		Network:    ipNet.String(),
+		ListenPort: listenPort,
+		MTU:        mtu,
+	}
+
+	// 2. Add the nodes and write their configurations
+	for _, node := range nodes {
+		if err := addMeshNode(mesh, node); err != nil {
+			return err
+		}
+	}
+	if err := tracker.SaveMeshSettings(mesh); err != nil {
+		return err
+	}
+	fmt.Printf("✅ Mesh %s created with %d nodes.\n", meshName, len(mesh.Nodes))
+	return writeMeshConfigs(mesh)
+}
+
+/*
+AddMeshNode adds a node to the mesh, only the configurations which changed are written again.
+*/
+func AddMeshNode(meshName string, node MeshNodeSpec) error {
+	mesh, err := tracker.LoadMeshSettings(meshName)
+	if err != nil {
+		return err
+	}
+	if err := addMeshNode(mesh, node); err != nil {
+		return err
+	}
+	if err := tracker.SaveMeshSettings(mesh); err != nil {
+		return err
+	}
+	return writeMeshConfigs(mesh)
+}
+
+/*
+RemoveMeshNode removes a node from the mesh, only the configurations which changed are written again.
+*/
+func RemoveMeshNode(meshName string, nodeName string) error {
+	mesh, err := tracker.LoadMeshSettings(meshName)
+	if err != nil {
+		return err
+	}
+	for i, node := range mesh.Nodes {
+		if node.Name != nodeName {
+			continue
+		}
+		mesh.Nodes = append(mesh.Nodes[:i], mesh.Nodes[i+1:]...)
+		if err := tracker.SaveMeshSettings(mesh); err != nil {
+			return err
+		}
+		fmt.Printf("✅ Node %s removed from the mesh %s.\n", nodeName, meshName)
+		return writeMeshConfigs(mesh)
+	}
+	return fmt.Errorf("no node named %s in the mesh %s", nodeName, meshName)
+}
+
+/*
+DeleteMesh forgets the mesh and removes the configurations of its nodes.
+*/
+func DeleteMesh(meshName string) error {
+	if !tracker.MeshExists(meshName) {
+		return fmt.Errorf("the mesh %s does not exist", meshName)
+	}
+	if err := os.RemoveAll(filepath.Join(meshConfigDir, meshName)); err != nil {
+		return fmt.Errorf("failed to remove the configurations of %s: %w", meshName, err)
+	}
+	if err := tracker.RemoveMeshSettings(meshName); err != nil {
+		return err
+	}
+	fmt.Printf("✅ Mesh %s removed.\n", meshName)
+	return nil
+}
+
+/*
+GetMesh returns the settings of the mesh.
+*/
+func GetMesh(meshName string) (*tracker.MeshSettings, error) {
+	return tracker.LoadMeshSettings(meshName)
+}
+
+/*
+ExportMesh writes a bundle per node into outDir: "<node>/<mesh>.conf" and the script installing it.
+*/
+func ExportMesh(meshName string, outDir string) error {
+	mesh, err := tracker.LoadMeshSettings(meshName)
+	if err != nil {
+		return err
+	}
+	installTmpl, err := template.New("meshInstall").Parse(templates.MeshInstallTpl)
+	if err != nil {
+		return fmt.Errorf("failed to parse install script template: %w", err)
+	}
+
+	for _, node := range mesh.Nodes {
+		data, content, err := renderMeshConfig(mesh, node)
+		if err != nil {
+			return err
+		}
+		nodeDir := filepath.Join(outDir, node.Name)
+		if err := os.MkdirAll(nodeDir, 0700); err != nil {
+			return fmt.Errorf("failed to create %s: %w", nodeDir, err)
+		}
+		confPath := filepath.Join(nodeDir, fmt.Sprintf("%s.conf", meshName))
+		if err := os.WriteFile(confPath, content, 0600); err != nil {
+			return fmt.Errorf("failed to write config file to %s: %w", confPath, err)
+		}
+
+		var script bytes.Buffer
+		if err := installTmpl.Execute(&script, data); err != nil {
+			return fmt.Errorf("failed to render install script template: %w", err)
+		}
+		scriptPath := filepath.Join(nodeDir, "install.sh")
+		if err := os.WriteFile(scriptPath, script.Bytes(), 0700); err != nil {
+			return fmt.Errorf("failed to write install script to %s: %w", scriptPath, err)
+		}
+	}
+	fmt.Printf("✅ Bundles of the %d nodes of %s exported to %s\n", len(mesh.Nodes), meshName, outDir)
+	return nil
+}
+
+// addMeshNode validates the node, allocates its address if necessary and generates its keys.
+func addMeshNode(mesh *tracker.MeshSettings, spec MeshNodeSpec) error {
+	if !nodeNamePattern.MatchString(spec.Name) {
+		return fmt.Errorf("invalid node name %q, use letters, digits or _.-", spec.Name)
+	}
+	if spec.Endpoint != "" {
+		if _, _, err := ParseEndpoint(spec.Endpoint); err != nil {
+			return err
+		}
+	}
+	_, network, err := net.ParseCIDR(mesh.Network)
+	if err != nil {
+		return fmt.Errorf("invalid mesh network %s: %w", mesh.Network, err)
+	}
+
+	// 1. Check the name and the address against the other nodes
+	used := make(map[string]bool)
+	for _, node := range mesh.Nodes {
+		if node.Name == spec.Name {
+			return fmt.Errorf("the node %s already exists in the mesh %s", spec.Name, mesh.Name)
+		}
+		used[node.Address] = true
+	}
+	address := spec.Address
+	if address == "" {
+		if address, err = allocateMeshAddress(network, used); err != nil {
+			return err
+		}
+	} else {
+		ip := net.ParseIP(address)
+		if ip == nil || !network.Contains(ip) {
+			return fmt.Errorf("the address %s is not in the mesh network %s", address, mesh.Network)
+		}
+		address = ip.String()
+		if used[address] {
+			return fmt.Errorf("the address %s is already used in the mesh %s", address, mesh.Name)
+		}
+	}
+
+	// 2. Generate the keys once, they are kept when the configurations are regenerated
+	privateKey, publicKey, err := GenerateWGKeyPair()
+	if err != nil {
+		return err
+	}
+	mesh.Nodes = append(mesh.Nodes, &tracker.MeshNode{
+		Name:       spec.Name,
+		Endpoint:   spec.Endpoint,
+		Address:    address,
+		PrivateKey: privateKey,
+		PublicKey:  publicKey,
+	})
+	fmt.Printf("✅ Node %s added to the mesh %s with the address %s\n", spec.Name, mesh.Name, address)
+	return nil
+}
+
+// allocateMeshAddress returns the first address of the network not used by a node.
+func allocateMeshAddress(network *net.IPNet, used map[string]bool) (string, error) {
+	ones, bits := network.Mask.Size()
+	count := new(big.Int).Lsh(big.NewInt(1), uint(bits-ones))
+	// Skip the network address, and the broadcast address of IPv4
+	last := new(big.Int).Sub(count, big.NewInt(1))
+	if bits == 8*net.IPv4len {
+		last.Sub(last, big.NewInt(1))
+	}
+	for i := big.NewInt(1); i.Cmp(last) <= 0; i.Add(i, big.NewInt(1)) {
+		ip := offsetIP(network.IP, i)
+		if ip4 := ip.To4(); ip4 != nil {
+			ip = ip4
+		}
+		if !used[ip.String()] {
+			return ip.String(), nil
+		}
+	}
+	return "", fmt.Errorf("no free address left in %s", network)
+}
+
+// renderMeshConfig renders the configuration of a node, with all the other nodes as peers.
+func renderMeshConfig(mesh *tracker.MeshSettings, node *tracker.MeshNode) (MeshConfTplData, []byte, error) {
+	_, network, err := net.ParseCIDR(mesh.Network)
+	if err != nil {
+		return MeshConfTplData{}, nil, fmt.Errorf("invalid mesh network %s: %w", mesh.Network, err)
+	}
+	ones, bits := network.Mask.Size()
+
+	data := MeshConfTplData{
+		MeshName:   mesh.Name,
+		NodeName:   node.Name,
+		PrivateKey: node.PrivateKey,
+		Address:    fmt.Sprintf("%s/%d", node.Address, ones),
+		ListenPort: meshNodePort(mesh, node),
+		MTU:        mesh.MTU,
+	}
+	for _, peer := range mesh.Nodes {
+		if peer == node {
+			continue
+		}
+		peerData := MeshPeerTplData{
+			Name:       peer.Name,
+			PublicKey:  peer.PublicKey,
+			AllowedIPs: fmt.Sprintf("%s/%d", peer.Address, bits),
+		}
+		if peer.Endpoint != "" {
+			host, _, err := ParseEndpoint(peer.Endpoint)
+			if err != nil {
+				return data, nil, err
+			}
+			peerData.Endpoint = net.JoinHostPort(host, strconv.Itoa(meshNodePort(mesh, peer)))
+		}
+		data.Peers = append(data.Peers, peerData)
+	}
+
+	tmpl, err := template.New("meshConfig").Parse(templates.MeshConfTpl)
+	if err != nil {
+		return data, nil, fmt.Errorf("failed to parse mesh config template: %w", err)
+	}
+	var buffer bytes.Buffer
+	if err := tmpl.Execute(&buffer, data); err != nil {
+		return data, nil, fmt.Errorf("failed to render mesh config template: %w", err)
+	}
+	return data, buffer.Bytes(), nil
+}
+
+// meshNodePort returns the port the node listens on: the one of its endpoint, or the one of the mesh.
+func meshNodePort(mesh *tracker.MeshSettings, node *tracker.MeshNode) int {
+	if node.Endpoint != "" {
+		if _, port, err := ParseEndpoint(node.Endpoint); err == nil && port != 0 {
+			return port
+		}
+	}
+	return mesh.ListenPort
+}
+
+/*
+writeMeshConfigs writes the configuration of every node into the directory of the mesh.
+
+Only the files whose content changed are written, and the files of removed nodes are deleted,
+so the nodes to update are the ones reported.
+*/
+func writeMeshConfigs(mesh *tracker.MeshSettings) error {
+	meshDir := filepath.Join(meshConfigDir, mesh.Name)
+	if err := os.MkdirAll(meshDir, 0700); err != nil {
+		return fmt.Errorf("failed to create %s: %w", meshDir, err)
+	}
+
+	// 1. Write the configurations which changed
+	current := make(map[string]bool)
+	unchanged := 0
+	for _, node := range mesh.Nodes {
+		_, content, err := renderMeshConfig(mesh, node)
+		if err != nil {
+			return err
+		}
+		fileName := fmt.Sprintf("%s.conf", node.Name)
+		current[fileName] = true
+		configPath := filepath.Join(meshDir, fileName)
+		if original, err := os.ReadFile(configPath); err == nil && bytes.Equal(original, content) {
+			unchanged++
+			continue
+		}
+		if err := os.WriteFile(configPath, content, 0600); err != nil {
+			return fmt.Errorf("failed to write config file to %s: %w", configPath, err)
+		}
+		fmt.Printf("✅ Configuration of %s written to %s\n", node.Name, configPath)
+	}
+
+	// 2. Remove the configurations of the nodes which left the mesh
+	entries, err := os.ReadDir(meshDir)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", meshDir, err)
+	}
+	for _, entry := range entries {
+		if strings.HasSuffix(entry.Name(), ".conf") && !current[entry.Name()] {
+			if err := os.Remove(filepath.Join(meshDir, entry.Name())); err != nil {
+				return fmt.Errorf("failed to remove %s: %w", entry.Name(), err)
+			}
+			fmt.Printf("✅ Configuration %s removed.\n", entry.Name())
+		}
+	}
+	if unchanged > 0 {
+		fmt.Printf("%d configurations unchanged.\n", unchanged)
+	}
+	return nil
+}
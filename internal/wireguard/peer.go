@@ -0,0 +1,395 @@
+package wireguard
+
+import (
+	"fast-wireguard/internal/system"
+	"fast-wireguard/internal/tracker"
+	"fast-wireguard/pkg/utils"
+	"fmt"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+type PeerOptions struct {
+	PeerName            string
+	IPAdressLocalClient string
+	PubKeyClient        string
+	PriKeyClient        string
+	DNS                 string
+	DNSSearch           string
+	Subnets             string
+	Routes              string
+	Owner               string
+}
+
+const (
+	// RouteFull sends all the traffic of the peer through the tunnel
+	RouteFull = "full"
+	// RouteSplit only sends the networks behind the server through the tunnel
+	RouteSplit = "split"
+)
+
+/*
+AddPeer adds a peer to the given interface and records its settings.
+
+If no public key is given, a new key pair is generated for the peer.
+A peer with subnets is a site-to-site router, the server routes the subnets to it.
+Returns the client configuration string of the peer.
+*/
+func AddPeer(interfaceName string, opts *PeerOptions) (string, error) {
+	// 1. Load the settings of the interface, the server public key and the endpoint
+	settings, err := loadInterfaceSettings(interfaceName)
+	if err != nil {
+		return "", err
+	}
+	pubKeyServer, err := ReadServerPublicKey(interfaceName)
+	if err != nil {
+		return "", err
+	}
+	endpointHost, endpointPort, err := clientEndpoint(settings)
+	if err != nil {
+		return "", err
+	}
+
+	// 2. Keep the addresses of the families carried by the tunnel
+	family := system.Family(settings.Family)
+	addresses := family.FilterAddresses(opts.IPAdressLocalClient)
+	if addresses == "" {
+		return "", fmt.Errorf("no %s address in %q", family, opts.IPAdressLocalClient)
+	}
+	if isRoutedIPv6(settings) {
+		peers, err := parseWGPeerConfig(interfaceName)
+		if err != nil {
+			return "", err
+		}
+		if addresses, err = allocateRoutedIPv6(addresses, settings, peers); err != nil {
+			return "", err
+		}
+	}
+	subnets, err := parseSubnets(utils.SplitList(opts.Subnets), settings, opts.PubKeyClient)
+	if err != nil {
+		return "", err
+	}
+	allowedIPs := strings.Join(append([]string{addresses}, subnets...), ", ")
+	routes, err := ParseRouteProfile(opts.Routes)
+	if err != nil {
+		return "", err
+	}
+
+	// 3. Generate the key pair of the peer if necessary
+	pubKeyClient, priKeyClient := opts.PubKeyClient, opts.PriKeyClient
+	if pubKeyClient == "" {
+		priKeyClient, pubKeyClient, err = GenerateWGKeyPair()
+		if err != nil {
+			return "", err
+		}
+		fmt.Println("✅ Generated a new key pair for the peer.")
+	}
+
+	// 4. Record the settings of the peer
+	peerSettings := &tracker.PeerSettings{
+		Name:         opts.PeerName,
+		PriKeyClient: priKeyClient,
+		Subnets:      subnets,
+		Routes:       routes,
+		Owner:        opts.Owner,
+	}
+	if opts.DNS != "" || opts.DNSSearch != "" {
+		peerSettings.DNS = &tracker.DNSSettings{
+			Servers:       utils.SplitList(opts.DNS),
+			SearchDomains: utils.SplitList(opts.DNSSearch),
+		}
+	}
+	if settings.Peers == nil {
+		settings.Peers = make(map[string]*tracker.PeerSettings)
+	}
+	settings.Peers[pubKeyClient] = peerSettings
+
+	// 5. Add the peer configuration
+	if priKeyClient == "" {
+		priKeyClient = "<your_client_private_key>"
+	}
+	clientMTU := settings.ClientMTU
+	if clientMTU == 0 {
+		clientMTU = settings.MTU
+	}
+	if _, err := AddWGPeerConfig(
+		interfaceName,
+		endpointHost,
+		endpointPort,
+		clientMTU,
+		pubKeyServer,
+		opts.PeerName,
+		allowedIPs,
+		pubKeyClient,
+		priKeyClient,
+		clientRoutedIPs(family),
+		clientDNS(settings, peerSettings)); err != nil {
+		return "", err
+	}
+
+	if err := tracker.SaveInterfaceSettings(interfaceName, settings); err != nil {
+		return "", err
+	}
+
+	// 6. Answer the neighbor solicitations for the global address of the peer
+	if settings.IPv6.NDPProxy {
+		if err := RefreshWGConfig(interfaceName); err != nil {
+			return "", err
+		}
+		if err := applyNDPProxy(interfaceName, settings, ndpProxyIPs(settings, []PeerConfTplData{{AllowedIPs: addresses}}), "add"); err != nil {
+			return "", err
+		}
+	}
+
+	// 7. Route the subnets of a site-to-site peer
+	if len(subnets) > 0 {
+		if err := applySiteRoutes(interfaceName, subnets, "replace"); err != nil {
+			return "", err
+		}
+		fmt.Printf("✅ Subnets %s routed to %s.\n", strings.Join(subnets, ", "), opts.PeerName)
+		if siteCount(settings) > 1 && peerToPeerAllowed(settings) {
+			fmt.Println("The other site-to-site peers need their configuration again (fwg peer config) to reach the new subnets.")
+		}
+	}
+
+	// 8. Build the client configuration from the recorded peer
+	data, err := BuildClientConfig(interfaceName, pubKeyClient)
+	if err != nil {
+		return "", err
+	}
+	return RenderClientConfig(data)
+}
+
+/*
+BuildClientConfig collects the client configuration of a peer (given by name or public key) from
+the settings of the interface and its configuration file.
+
+A site-to-site peer, or a peer with the split route profile, only routes the networks behind the server
+and the other sites through the tunnel, the other peers route all their traffic.
+*/
+func BuildClientConfig(interfaceName string, peerName string) (*ClientConfTplData, error) {
+	peer, err := FindPeer(interfaceName, peerName)
+	if err != nil {
+		return nil, err
+	}
+	settings, err := loadInterfaceSettings(interfaceName)
+	if err != nil {
+		return nil, err
+	}
+	pubKeyServer, err := ReadServerPublicKey(interfaceName)
+	if err != nil {
+		return nil, err
+	}
+	detectEndpoint := settings.Endpoint == ""
+	endpointHost, endpointPort, err := clientEndpoint(settings)
+	if err != nil {
+		return nil, err
+	}
+	if detectEndpoint {
+		if err := tracker.SaveInterfaceSettings(interfaceName, settings); err != nil {
+			return nil, err
+		}
+	}
+
+	peerSettings := settings.Peers[peer.PubKeyClient]
+	data := &ClientConfTplData{
+		PriKeyClient: "<your_client_private_key>",
+		PubKeyServer: pubKeyServer,
+		Endpoint:     net.JoinHostPort(endpointHost, strconv.Itoa(endpointPort)),
+		MTU:          settings.ClientMTU,
+	}
+	if data.MTU == 0 {
+		data.MTU = settings.MTU
+	}
+	var subnets []string
+	routes := RouteFull
+	if peerSettings != nil {
+		if peerSettings.PriKeyClient != "" {
+			data.PriKeyClient = peerSettings.PriKeyClient
+		}
+		subnets = peerSettings.Subnets
+		if peerSettings.Routes != "" {
+			routes = peerSettings.Routes
+		}
+	}
+
+	// The subnets behind the peer are routed to it, they are not addresses of its interface
+	var addresses []string
+	for _, part := range utils.SplitList(peer.AllowedIPs) {
+		if !slices.Contains(subnets, part) {
+			addresses = append(addresses, part)
+		}
+	}
+	data.AllowedIPs = clientAddresses(strings.Join(addresses, ", "))
+	if len(subnets) > 0 {
+		data.Subnets = strings.Join(subnets, ", ")
+		data.SiteIPv6 = strings.Contains(data.Subnets, ":")
+		data.RoutedIPs = siteRoutedIPs(settings, peer.PubKeyClient)
+	} else if routes == RouteSplit {
+		data.RoutedIPs = siteRoutedIPs(settings, peer.PubKeyClient)
+		data.DNS = clientDNS(settings, peerSettings)
+	} else {
+		data.RoutedIPs = clientRoutedIPs(system.Family(settings.Family))
+		data.DNS = clientDNS(settings, peerSettings)
+	}
+	return data, nil
+}
+
+/*
+loadInterfaceSettings reads the settings of the given interface.
+
+The fields missing from the settings file (e.g. for interfaces created by older versions)
+are filled from the [Interface] section of the configuration file.
+*/
+func loadInterfaceSettings(interfaceName string) (*tracker.InterfaceSettings, error) {
+	settings, err := tracker.LoadInterfaceSettings(interfaceName)
+	if err != nil {
+		return nil, err
+	}
+	if settings.ListenPort != 0 && settings.Address != "" {
+		return settings, nil
+	}
+
+	data, err := parseWGInterfaceConfig(interfaceName)
+	if err != nil {
+		return nil, err
+	}
+	if settings.ListenPort == 0 {
+		settings.ListenPort = data.ListenPort
+	}
+	if settings.Address == "" {
+		settings.Address = data.Address
+	}
+	if settings.MTU == 0 {
+		settings.MTU = data.MTU
+	}
+	if settings.PhysicalInterface == "" {
+		settings.PhysicalInterface = data.PhysicalInterface
+	}
+	return settings, nil
+}
+
+/*
+DeletePeer removes the peer (given by name or public key) from the interface and forgets its settings.
+*/
+func DeletePeer(interfaceName string, peerName string) error {
+	peer, err := FindPeer(interfaceName, peerName)
+	if err != nil {
+		return err
+	}
+
+	// 1. Remove the port forwarding rules of the peer while its addresses are still known
+	settings, err := loadInterfaceSettings(interfaceName)
+	if err != nil {
+		return err
+	}
+	forwards, err := forwardRules(interfaceName, settings)
+	if err != nil {
+		return err
+	}
+	var kept []tracker.PortForward
+	for i, forward := range settings.Forwards {
+		if forward.PubKeyClient != peer.PubKeyClient {
+			kept = append(kept, forward)
+			continue
+		}
+		if err := applyForwardRule(interfaceName, forwards[i], "-D"); err != nil {
+			fmt.Printf("Warning: failed to remove the port forwarding rules of port %d: %v\n", forward.PublicPort, err)
+		}
+	}
+	removedForwards := len(kept) != len(settings.Forwards)
+	settings.Forwards = kept
+	if peerSettings := settings.Peers[peer.PubKeyClient]; peerSettings != nil {
+		if err := applySiteRoutes(interfaceName, peerSettings.Subnets, "del"); err != nil {
+			fmt.Printf("Warning: %v\n", err)
+		}
+	}
+	if err := applyNDPProxy(interfaceName, settings, ndpProxyIPs(settings, []PeerConfTplData{peer}), "del"); err != nil {
+		fmt.Printf("Warning: %v\n", err)
+	}
+
+	// 2. Remove the peer and its settings
+	if err := DeleteWGPeerConfig(interfaceName, peer.PubKeyClient, false); err != nil {
+		return err
+	}
+	delete(settings.Peers, peer.PubKeyClient)
+	if err := tracker.SaveInterfaceSettings(interfaceName, settings); err != nil {
+		return err
+	}
+	if removedForwards || settings.IPv6.NDPProxy {
+		return RefreshWGConfig(interfaceName)
+	}
+	return nil
+}
+
+// hostIPs returns the host addresses (/32 and /128) in AllowedIPs, not the subnets routed through the peer.
+func hostIPs(allowedIPs string) []net.IP {
+	var ips []net.IP
+	for _, part := range utils.SplitList(allowedIPs) {
+		ip, ipNet, err := net.ParseCIDR(part)
+		if err != nil {
+			continue
+		}
+		if ones, bits := ipNet.Mask.Size(); ones == bits {
+			ips = append(ips, ip)
+		}
+	}
+	return ips
+}
+
+/*
+FindPeer looks up a peer of the interface by its name or its public key.
+
+Returns an error if no peer or several peers match.
+*/
+func FindPeer(interfaceName string, peerName string) (PeerConfTplData, error) {
+	peers, err := parseWGPeerConfig(interfaceName)
+	if err != nil {
+		return PeerConfTplData{}, err
+	}
+
+	var matches []PeerConfTplData
+	for _, peer := range peers {
+		if peer.PubKeyClient == peerName {
+			return peer, nil
+		}
+		if peer.PeerName == peerName {
+			matches = append(matches, peer)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return PeerConfTplData{}, fmt.Errorf("no peer named %s in %s", peerName, interfaceName)
+	case 1:
+		return matches[0], nil
+	default:
+		return PeerConfTplData{}, fmt.Errorf("several peers are named %s in %s, use the public key instead", peerName, interfaceName)
+	}
+}
+
+// clientRoutedIPs returns the default routes of the families carried by the tunnel, sent through it by the clients.
+func clientRoutedIPs(family system.Family) string {
+	var routes []string
+	if family.HasIPv4() {
+		routes = append(routes, "0.0.0.0/0")
+	}
+	if family.HasIPv6() {
+		routes = append(routes, "::/0")
+	}
+	return strings.Join(routes, ", ")
+}
+
+/*
+ParseRouteProfile parses the route profile of a peer: full (the default) or split.
+*/
+func ParseRouteProfile(routes string) (string, error) {
+	switch strings.ToLower(routes) {
+	case "", RouteFull:
+		return RouteFull, nil
+	case RouteSplit:
+		return RouteSplit, nil
+	}
+	return "", fmt.Errorf("invalid route profile %s, use full or split", routes)
+}
@@ -0,0 +1,136 @@
+package wireguard
+
+import (
+	"fast-wireguard/internal/system"
+	"fast-wireguard/internal/tracker"
+	"fast-wireguard/pkg/utils"
+	"fmt"
+	"net"
+)
+
+const (
+	PeerToPeerAllow = "allow"
+	PeerToPeerDeny  = "deny"
+)
+
+/*
+ParsePeerToPeer validates the policy of the traffic between the peers of an interface: "allow" or "deny".
+*/
+func ParsePeerToPeer(policy string) (string, error) {
+	switch policy {
+	case PeerToPeerAllow, PeerToPeerDeny:
+		return policy, nil
+	case "":
+		return PeerToPeerAllow, nil
+	}
+	return "", fmt.Errorf("invalid peer-to-peer policy %s, use allow or deny", policy)
+}
+
+// peerToPeerAllowed reports whether the peers may reach each other, the interfaces created before the setting allow it.
+func peerToPeerAllowed(settings *tracker.InterfaceSettings) bool {
+	return settings.PeerToPeer != PeerToPeerDeny
+}
+
+// peerToPeerTarget returns the iptables target of the traffic entering and leaving the interface.
+func peerToPeerTarget(allowed bool) string {
+	if allowed {
+		return "ACCEPT"
+	}
+	return "DROP"
+}
+
+/*
+GetPeerToPeer returns the peer-to-peer policy of the given interface.
+*/
+func GetPeerToPeer(interfaceName string) (string, error) {
+	settings, err := loadInterfaceSettings(interfaceName)
+	if err != nil {
+		return "", err
+	}
+	return ParsePeerToPeer(settings.PeerToPeer)
+}
+
+/*
+SetPeerToPeer changes whether the peers of the interface can reach each other through the server.
+
+The forwarding rule of the running interface is replaced, the client configurations generated afterwards
+route the tunnel network (allow) or only the addresses of the server (deny).
+*/
+func SetPeerToPeer(interfaceName string, policy string) error {
+	policy, err := ParsePeerToPeer(policy)
+	if err != nil {
+		return err
+	}
+	settings, err := loadInterfaceSettings(interfaceName)
+	if err != nil {
+		return err
+	}
+	previous := peerToPeerAllowed(settings)
+
+	// 1. Record the policy and render the forwarding rules again
+	settings.PeerToPeer = policy
+	if err := tracker.SaveInterfaceSettings(interfaceName, settings); err != nil {
+		return err
+	}
+	if err := RefreshWGConfig(interfaceName); err != nil {
+		return err
+	}
+
+	// 2. Swap the rule of the running interface
+	if previous != peerToPeerAllowed(settings) {
+		if err := applyPeerToPeerRule(interfaceName, settings, peerToPeerTarget(previous), "-D"); err != nil {
+			fmt.Printf("Warning: %v\n", err)
+		}
+		if err := applyPeerToPeerRule(interfaceName, settings, peerToPeerTarget(!previous), "-I"); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("✅ Peer-to-peer traffic on %s: %s.\n", interfaceName, policy)
+	switch sites, splits := siteCount(settings), splitCount(settings); {
+	case sites > 0 && splits > 0:
+		fmt.Println("The site-to-site peers and the peers with the split route profile need their configuration again (fwg peer config) to follow the new routes.")
+	case sites > 0:
+		fmt.Println("The site-to-site peers need their configuration again (fwg peer config) to follow the new routes.")
+	case splits > 0:
+		fmt.Println("The peers with the split route profile need their configuration again (fwg peer config) to follow the new routes.")
+	}
+	return nil
+}
+
+// applyPeerToPeerRule inserts ("-I") or deletes ("-D") the rule of the traffic between the peers if the interface is up.
+func applyPeerToPeerRule(interfaceName string, settings *tracker.InterfaceSettings, target string, action string) error {
+	if !IsServiceActive(interfaceName) {
+		return nil
+	}
+	family := system.Family(settings.Family)
+	var commands []string
+	if family.HasIPv4() {
+		commands = append(commands, "iptables")
+	}
+	if family.HasIPv6() {
+		commands = append(commands, "ip6tables")
+	}
+	for _, command := range commands {
+		if err := utils.RunAsRootSilent(command, action, "FORWARD", "-i", interfaceName, "-o", interfaceName, "-j", target); err != nil {
+			return fmt.Errorf("failed to update the peer-to-peer rule of %s: %w", interfaceName, err)
+		}
+	}
+	return nil
+}
+
+// serverHostIPs returns the addresses of the server in the tunnel as /32 and /128 routes.
+func serverHostIPs(address string) []string {
+	var routes []string
+	for _, part := range utils.SplitList(address) {
+		ip, _, err := net.ParseCIDR(part)
+		if err != nil {
+			continue
+		}
+		if ip.To4() != nil {
+			routes = append(routes, ip.String()+"/32")
+		} else {
+			routes = append(routes, ip.String()+"/128")
+		}
+	}
+	return routes
+}
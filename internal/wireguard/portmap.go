@@ -0,0 +1,279 @@
+package wireguard
+
+import (
+	"fast-wireguard/internal/portmap"
+	"fast-wireguard/internal/system"
+	"fast-wireguard/internal/templates"
+	"fast-wireguard/internal/tracker"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+const (
+	// portMappingLifetime is the lease requested on the gateway, renewed at half of it
+	portMappingLifetime = 2 * time.Hour
+	// portMappingRetry is the delay before mapping the port again after a first failure, doubled after each
+	// following one up to portMappingMaxRetry
+	portMappingRetry    = time.Minute
+	portMappingMaxRetry = time.Hour
+)
+
+var (
+	// Shared address space of carrier-grade NATs (RFC 6598)
+	_, cgnatNetwork, _ = net.ParseCIDR("100.64.0.0/10")
+)
+
+/*
+NATStatus describes whether the server reaches the Internet through a NAT gateway.
+
+PublicIP is nil if it could not be discovered.
+*/
+type NATStatus struct {
+	BehindNAT bool
+	LocalIP   net.IP
+	PublicIP  net.IP
+	Gateway   net.IP
+}
+
+/*
+DetectNAT compares the public IPv4 of the server with the address of its physical interface.
+
+The public IP is cached like the endpoint of the interface. If it cannot be discovered (e.g. offline),
+a private local address means a NAT.
+*/
+func DetectNAT(interfaceName string) (*NATStatus, error) {
+	settings, err := loadInterfaceSettings(interfaceName)
+	if err != nil {
+		return nil, err
+	}
+	if settings.PhysicalInterface == "" {
+		return nil, fmt.Errorf("the physical interface of %s is unknown", interfaceName)
+	}
+
+	status := &NATStatus{}
+	status.LocalIP, err = system.GetInterfaceIP(settings.PhysicalInterface, false)
+	if err != nil {
+		return nil, err
+	}
+	if status.LocalIP.To4() == nil {
+		// IPv6 only, there is no NAT to traverse
+		return status, nil
+	}
+	for _, route := range system.GetDefaultRoutes() {
+		if !route.IPv6 && route.Interface == settings.PhysicalInterface {
+			status.Gateway = route.Gateway
+			break
+		}
+	}
+
+	opts := discoveryOptions(settings)
+	if !opts.Offline {
+		status.PublicIP, _, _ = system.LookupPublicIP(opts, "ip4")
+	}
+	if status.PublicIP != nil {
+		status.BehindNAT = !status.PublicIP.Equal(status.LocalIP)
+	} else {
+		status.BehindNAT = status.LocalIP.IsPrivate() || cgnatNetwork.Contains(status.LocalIP)
+	}
+	return status, nil
+}
+
+/*
+EnablePortMapping maps the listen port on the gateway if the server is behind NAT, and starts the
+service renewing the mapping.
+
+If no protocol is supported by the gateway, it explains how to forward the port manually.
+*/
+func EnablePortMapping(interfaceName string) error {
+	// 1. Check whether a mapping is needed at all
+	status, err := DetectNAT(interfaceName)
+	if err != nil {
+		return err
+	}
+	if !status.BehindNAT {
+		fmt.Printf("✅ No NAT detected, the port of %s is reachable directly.\n", interfaceName)
+		return nil
+	}
+	settings, err := loadInterfaceSettings(interfaceName)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("✅ The server is behind NAT (local IP %s, public IP %s).\n", status.LocalIP, formatIP(status.PublicIP))
+
+	// 2. Map the port with the first protocol supported by the gateway
+	mapping, _, err := portmap.MapPort(portmap.Mappers(status.Gateway, status.LocalIP), settings.ListenPort, portMappingLifetime)
+	if err != nil {
+		printManualForwarding(settings.ListenPort, status, err)
+		return nil
+	}
+	fmt.Printf("✅ Port udp/%d mapped on the gateway via %s.\n", mapping.ExternalPort, mapping.Method)
+	if status.PublicIP != nil && mapping.ExternalIP != nil && !mapping.ExternalIP.IsUnspecified() && !mapping.ExternalIP.Equal(status.PublicIP) {
+		fmt.Printf("Warning: the gateway has the address %s, another NAT in front of it may still block the clients.\n", mapping.ExternalIP)
+	}
+
+	// 3. Point the clients to the external port if the gateway picked another one
+	changed, err := pointEndpointToMapping(settings, mapping.ExternalPort)
+	if err != nil {
+		return err
+	}
+	if changed {
+		fmt.Printf("✅ Endpoint of %s changed to %s, the existing clients need to be updated.\n", interfaceName, settings.Endpoint)
+	}
+
+	// 4. Renew the mapping in the background
+	settings.PortMapping = true
+	if err := tracker.SaveInterfaceSettings(interfaceName, settings); err != nil {
+		return err
+	}
+	return enableUnit("fwg-portmap", templates.PortMapServiceTpl, interfaceName)
+}
+
+/*
+DisablePortMapping stops the renewal service, which removes the mapping from the gateway.
+*/
+func DisablePortMapping(interfaceName string) error {
+	settings, err := loadInterfaceSettings(interfaceName)
+	if err != nil {
+		return err
+	}
+	settings.PortMapping = false
+	if err := tracker.SaveInterfaceSettings(interfaceName, settings); err != nil {
+		return err
+	}
+	return disableUnit("fwg-portmap", interfaceName, false)
+}
+
+/*
+RunPortMapping keeps the listen port mapped on the gateway, renewing the lease at half of its lifetime.
+
+The gateway and the local address are detected again after every failure, as they may change
+(e.g. DHCP), with an exponential backoff. It stops if the server is not behind NAT.
+If the gateway maps another external port, the endpoint of the clients is changed to it.
+The mapping is deleted when the process is stopped.
+*/
+func RunPortMapping(interfaceName string) error {
+	settings, err := loadInterfaceSettings(interfaceName)
+	if err != nil {
+		return err
+	}
+	if !settings.PortMapping {
+		return fmt.Errorf("port mapping is not enabled for %s", interfaceName)
+	}
+	port := settings.ListenPort
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+
+	var mapper portmap.Mapper
+	var mapping *portmap.Mapping
+	retry := portMappingRetry
+	mappedPort := 0
+	for {
+		// 1. Renew the current mapping or look for a protocol supported by the gateway
+		if mapper != nil {
+			mapping, err = mapper.Map(port, mapping.ExternalPort, portMappingLifetime)
+			if err != nil {
+				log.Printf("Failed to renew the mapping of udp/%d via %s: %v", port, mapper.Name(), err)
+				mapper = nil
+			}
+		}
+		if mapper == nil {
+			if status, err := DetectNAT(interfaceName); err != nil {
+				log.Printf("Failed to detect the NAT: %v", err)
+			} else if !status.BehindNAT {
+				log.Printf("No NAT detected, nothing to map, run \"fwg portmap enable %s\" again if the network changes", interfaceName)
+				return nil
+			} else if mapping, mapper, err = portmap.MapPort(portmap.Mappers(status.Gateway, status.LocalIP), port, portMappingLifetime); err != nil {
+				log.Printf("Failed to map udp/%d on the gateway %s, forward it manually to %s:%d: %v", port, status.Gateway, status.LocalIP, port, err)
+			} else {
+				log.Printf("Mapped udp/%d to udp/%d via %s", mapping.ExternalPort, port, mapper.Name())
+			}
+		}
+
+		// 2. Point the clients to the external port if the gateway picked another one
+		if mapper != nil && mapping.ExternalPort != mappedPort {
+			if err := updateMappedEndpoint(interfaceName, mapping.ExternalPort); err != nil {
+				log.Printf("Failed to change the endpoint of %s to the port udp/%d: %v", interfaceName, mapping.ExternalPort, err)
+			} else {
+				mappedPort = mapping.ExternalPort
+			}
+		}
+
+		// 3. Wait for the renewal, or for the next attempt after a failure
+		delay := retry
+		if mapper != nil {
+			delay, retry = max(portMappingRetry, mapping.Lifetime/2), portMappingRetry
+		} else {
+			retry = min(2*retry, portMappingMaxRetry)
+		}
+		select {
+		case <-time.After(delay):
+		case <-stop:
+			// Delete the mapping when stopped
+			if mapper != nil {
+				if _, err := mapper.Map(port, mapping.ExternalPort, 0); err != nil {
+					log.Printf("Failed to delete the mapping of udp/%d: %v", port, err)
+				} else {
+					log.Printf("Deleted the mapping of udp/%d via %s", mapping.ExternalPort, mapper.Name())
+				}
+			}
+			return nil
+		}
+	}
+}
+
+/*
+pointEndpointToMapping changes the port of the endpoint of the clients to the external port of the mapping.
+
+It reports whether the endpoint changed, the caller has to save the settings then.
+*/
+func pointEndpointToMapping(settings *tracker.InterfaceSettings, externalPort int) (bool, error) {
+	host, port, err := clientEndpoint(settings)
+	if err != nil {
+		return false, err
+	}
+	if port == externalPort {
+		return false, nil
+	}
+	settings.Endpoint = net.JoinHostPort(host, strconv.Itoa(externalPort))
+	return true, nil
+}
+
+// updateMappedEndpoint records the external port of the mapping in the endpoint of the interface, reloading
+// the settings as they may have changed since the service started.
+func updateMappedEndpoint(interfaceName string, externalPort int) error {
+	settings, err := loadInterfaceSettings(interfaceName)
+	if err != nil {
+		return err
+	}
+	changed, err := pointEndpointToMapping(settings, externalPort)
+	if err != nil || !changed {
+		return err
+	}
+	if err := tracker.SaveInterfaceSettings(interfaceName, settings); err != nil {
+		return err
+	}
+	log.Printf("Endpoint of %s changed to %s, the existing clients need to be updated", interfaceName, settings.Endpoint)
+	return nil
+}
+
+// printManualForwarding explains which port has to be forwarded on the router.
+func printManualForwarding(port int, status *NATStatus, err error) {
+	fmt.Printf("Warning: the gateway %s could not map the port automatically:\n%v\n", formatIP(status.Gateway), err)
+	fmt.Printf("Forward the UDP port %d of your router to %s:%d manually, otherwise the clients cannot connect.\n",
+		port, status.LocalIP, port)
+}
+
+// formatIP prints an address which may be unknown.
+func formatIP(ip net.IP) string {
+	if ip == nil {
+		return "unknown"
+	}
+	return ip.String()
+}
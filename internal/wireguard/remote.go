@@ -0,0 +1,286 @@
+package wireguard
+
+import (
+	"encoding/base64"
+	"fast-wireguard/internal/tracker"
+	"fast-wireguard/pkg/utils"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+/*
+RemoteServerOptions describes an fwg server of another host, as shown by "fwg peer config" there.
+*/
+type RemoteServerOptions struct {
+	Name      string
+	PublicKey string
+	Endpoint  string
+	Interface string
+	Pool      string
+	DNS       string
+	MTU       int
+}
+
+/*
+BundleOptions selects the remote servers of a bundle and whether the user has one key pair for all of them.
+*/
+type BundleOptions struct {
+	User      string
+	Servers   []string
+	SharedKey bool
+	OutDir    string
+}
+
+/*
+AddRemoteServer registers (or replaces) a remote fwg server.
+
+The clients already given access to a replaced server are kept.
+*/
+func AddRemoteServer(opts *RemoteServerOptions) error {
+	// 1. Validate the server, its name is the interface name of the client configuration
+	if !meshNamePattern.MatchString(opts.Name) {
+		return fmt.Errorf("invalid server name %q, use at most 15 letters, digits or _=+.-", opts.Name)
+	}
+	if !isValidKey(opts.PublicKey) {
+		return fmt.Errorf("invalid public key %q", opts.PublicKey)
+	}
+	host, port, err := ParseEndpoint(opts.Endpoint)
+	if err != nil {
+		return err
+	}
+	if port == 0 {
+		port = 51820
+	}
+	var pool []string
+	for _, part := range utils.SplitList(opts.Pool) {
+		_, ipNet, err := net.ParseCIDR(part)
+		if err != nil {
+			return fmt.Errorf("invalid address pool %s: %w", part, err)
+		}
+		pool = append(pool, ipNet.String())
+	}
+	if len(pool) == 0 {
+		return fmt.Errorf("the remote server %s needs an address pool", opts.Name)
+	}
+
+	// 2. Record it, keeping the clients of the previous registration
+	servers, err := tracker.LoadRemoteServers()
+	if err != nil {
+		return err
+	}
+	server := &tracker.RemoteServer{
+		Name:      opts.Name,
+		PublicKey: opts.PublicKey,
+		Endpoint:  net.JoinHostPort(host, strconv.Itoa(port)),
+		Interface: opts.Interface,
+		Pool:      pool,
+		DNS:       utils.SplitList(opts.DNS),
+		MTU:       opts.MTU,
+	}
+	replaced := false
+	for i, existing := range servers {
+		if existing.Name == opts.Name {
+			server.Clients = existing.Clients
+			servers[i] = server
+			replaced = true
+		}
+	}
+	if !replaced {
+		servers = append(servers, server)
+	}
+	if err := tracker.SaveRemoteServers(servers); err != nil {
+		return err
+	}
+	fmt.Printf("✅ Remote server %s (%s) registered.\n", server.Name, server.Endpoint)
+	return nil
+}
+
+/*
+RemoveRemoteServer forgets a remote server and the clients given access to it.
+*/
+func RemoveRemoteServer(name string) error {
+	servers, err := tracker.LoadRemoteServers()
+	if err != nil {
+		return err
+	}
+	for i, server := range servers {
+		if server.Name == name {
+			if err := tracker.SaveRemoteServers(append(servers[:i], servers[i+1:]...)); err != nil {
+				return err
+			}
+			fmt.Printf("✅ Remote server %s removed.\n", name)
+			if len(server.Clients) > 0 {
+				fmt.Printf("Remove its %d clients on the server itself (fwg peer delete).\n", len(server.Clients))
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("no remote server named %s", name)
+}
+
+/*
+ListRemoteServers returns the registered remote servers.
+*/
+func ListRemoteServers() ([]*tracker.RemoteServer, error) {
+	return tracker.LoadRemoteServers()
+}
+
+/*
+GenerateBundle writes one client configuration per remote server for the user into "<out>/<user>/<server>.conf",
+and the commands adding the user as a peer on each server into "<out>/<user>/servers.txt".
+
+The keys and addresses of the user are recorded, so the bundle can be generated again.
+*/
+func GenerateBundle(opts *BundleOptions) error {
+	if !nodeNamePattern.MatchString(opts.User) {
+		return fmt.Errorf("invalid user name %q, use letters, digits or _.-", opts.User)
+	}
+	servers, err := tracker.LoadRemoteServers()
+	if err != nil {
+		return err
+	}
+
+	// 1. Select the servers of the bundle
+	var selected []*tracker.RemoteServer
+	for _, server := range servers {
+		if len(opts.Servers) == 0 || slices.Contains(opts.Servers, server.Name) {
+			selected = append(selected, server)
+		}
+	}
+	for _, name := range opts.Servers {
+		if !slices.ContainsFunc(selected, func(server *tracker.RemoteServer) bool { return server.Name == name }) {
+			return fmt.Errorf("no remote server named %s", name)
+		}
+	}
+	if len(selected) == 0 {
+		return fmt.Errorf("no remote server registered, add one with fwg remote add")
+	}
+
+	// 2. Give the user access to the servers, reusing the keys and addresses already recorded
+	var sharedPriKey, sharedPubKey string
+	if opts.SharedKey {
+		for _, server := range selected {
+			if client := server.Clients[opts.User]; client != nil {
+				sharedPriKey, sharedPubKey = client.PrivateKey, client.PublicKey
+				break
+			}
+		}
+	}
+	for _, server := range selected {
+		if server.Clients[opts.User] != nil {
+			continue
+		}
+		addresses, err := allocateRemoteAddresses(server)
+		if err != nil {
+			return fmt.Errorf("remote server %s: %w", server.Name, err)
+		}
+		priKey, pubKey := sharedPriKey, sharedPubKey
+		if priKey == "" {
+			if priKey, pubKey, err = GenerateWGKeyPair(); err != nil {
+				return err
+			}
+			if opts.SharedKey {
+				sharedPriKey, sharedPubKey = priKey, pubKey
+			}
+		}
+		if server.Clients == nil {
+			server.Clients = make(map[string]*tracker.RemoteClient)
+		}
+		server.Clients[opts.User] = &tracker.RemoteClient{
+			Addresses:  addresses,
+			PrivateKey: priKey,
+			PublicKey:  pubKey,
+		}
+	}
+	if err := tracker.SaveRemoteServers(servers); err != nil {
+		return err
+	}
+
+	// 3. Write the configurations and the commands to run on the servers
+	userDir := filepath.Join(opts.OutDir, opts.User)
+	if err := os.MkdirAll(userDir, 0700); err != nil {
+		return fmt.Errorf("failed to create %s: %w", userDir, err)
+	}
+	var commands strings.Builder
+	commands.WriteString(fmt.Sprintf("# Add %s as a peer on each server of the bundle\n", opts.User))
+	for _, server := range selected {
+		client := server.Clients[opts.User]
+		content, err := RenderClientConfig(remoteClientConfig(server, client))
+		if err != nil {
+			return err
+		}
+		confPath := filepath.Join(userDir, fmt.Sprintf("%s.conf", server.Name))
+		if err := os.WriteFile(confPath, []byte(content), 0600); err != nil {
+			return fmt.Errorf("failed to write config file to %s: %w", confPath, err)
+		}
+		commands.WriteString(fmt.Sprintf("\n# %s (%s)\n", server.Name, server.Endpoint))
+		commands.WriteString(fmt.Sprintf("fwg peer add %s -n %s --public-key %s -c \"%s\"\n",
+			server.Interface, opts.User, client.PublicKey, strings.Join(client.Addresses, ", ")))
+	}
+	commandsPath := filepath.Join(userDir, "servers.txt")
+	if err := os.WriteFile(commandsPath, []byte(commands.String()), 0600); err != nil {
+		return fmt.Errorf("failed to write %s: %w", commandsPath, err)
+	}
+
+	fmt.Printf("✅ Bundle of %s with %d servers written to %s\n", opts.User, len(selected), userDir)
+	fmt.Printf("Add the user on the servers with the commands of %s:\n%s", commandsPath, commands.String())
+	return nil
+}
+
+// remoteClientConfig returns the client configuration of the user of a remote server.
+func remoteClientConfig(server *tracker.RemoteServer, client *tracker.RemoteClient) *ClientConfTplData {
+	var routes []string
+	for _, address := range client.Addresses {
+		if strings.Contains(address, ":") {
+			routes = append(routes, "::/0")
+		} else {
+			routes = append(routes, "0.0.0.0/0")
+		}
+	}
+	return &ClientConfTplData{
+		PriKeyClient: client.PrivateKey,
+		AllowedIPs:   strings.Join(client.Addresses, ", "),
+		PubKeyServer: server.PublicKey,
+		RoutedIPs:    strings.Join(routes, ", "),
+		Endpoint:     server.Endpoint,
+		MTU:          server.MTU,
+		DNS:          strings.Join(server.DNS, ", "),
+	}
+}
+
+// allocateRemoteAddresses returns the first free host address of each pool of the remote server.
+func allocateRemoteAddresses(server *tracker.RemoteServer) ([]string, error) {
+	used := make(map[string]bool)
+	for _, client := range server.Clients {
+		for _, address := range client.Addresses {
+			if ip, _, err := net.ParseCIDR(address); err == nil {
+				used[ip.String()] = true
+			}
+		}
+	}
+	var addresses []string
+	for _, pool := range server.Pool {
+		_, network, err := net.ParseCIDR(pool)
+		if err != nil {
+			return nil, fmt.Errorf("invalid address pool %s: %w", pool, err)
+		}
+		ip, err := allocateMeshAddress(network, used)
+		if err != nil {
+			return nil, err
+		}
+		_, bits := network.Mask.Size()
+		addresses = append(addresses, fmt.Sprintf("%s/%d", ip, bits))
+	}
+	return addresses, nil
+}
+
+// isValidKey reports whether the key is a base64 WireGuard key of 32 bytes.
+func isValidKey(key string) bool {
+	decoded, err := base64.StdEncoding.DecodeString(key)
+	return err == nil && len(decoded) == 32
+}
@@ -0,0 +1,160 @@
+package wireguard
+
+import (
+	"fast-wireguard/internal/tracker"
+	"fast-wireguard/pkg/utils"
+	"fmt"
+	"net"
+	"strings"
+)
+
+/*
+parseSubnets validates the LAN subnets of a site-to-site peer and normalizes them to their network address.
+
+They must not overlap the tunnel, the LANs of the server or the subnets of the other peers.
+*/
+func parseSubnets(subnets []string, settings *tracker.InterfaceSettings, pubKeyClient string) ([]string, error) {
+	// 1. Collect the networks already reachable through the server
+	taken := map[string]string{}
+	for _, network := range tunnelNetworks(settings.Address) {
+		taken[network] = "the tunnel"
+	}
+	for _, lan := range settings.LANs {
+		taken[lan] = "the LAN of the server"
+	}
+	for key, peer := range settings.Peers {
+		if key == pubKeyClient {
+			continue
+		}
+		for _, subnet := range peer.Subnets {
+			taken[subnet] = "the peer " + peer.Name
+		}
+	}
+
+	// 2. Check every subnet against them
+	var result []string
+	for _, subnet := range subnets {
+		_, ipNet, err := net.ParseCIDR(subnet)
+		if err != nil {
+			return nil, fmt.Errorf("invalid subnet %s: %w", subnet, err)
+		}
+		for network, owner := range taken {
+			if _, other, err := net.ParseCIDR(network); err == nil && (other.Contains(ipNet.IP) || ipNet.Contains(other.IP)) {
+				return nil, fmt.Errorf("the subnet %s overlaps %s (%s)", ipNet, owner, network)
+			}
+		}
+		taken[ipNet.String()] = "another subnet of the peer"
+		result = append(result, ipNet.String())
+	}
+	return result, nil
+}
+
+/*
+parseLANs returns the LANs of the server given as a comma-separated list of networks.
+
+"auto" detects them from the physical interface, "none" disables them.
+*/
+func parseLANs(lans string, physicalInterface string) ([]string, error) {
+	switch lans {
+	case "none":
+		return nil, nil
+	case "auto", "":
+		return detectLANs(physicalInterface), nil
+	}
+	var result []string
+	for _, lan := range utils.SplitList(lans) {
+		_, ipNet, err := net.ParseCIDR(lan)
+		if err != nil {
+			return nil, fmt.Errorf("invalid LAN %s: %w", lan, err)
+		}
+		result = append(result, ipNet.String())
+	}
+	return result, nil
+}
+
+// detectLANs returns the private IPv4 networks of the physical interface, i.e. the LAN the server sits in.
+func detectLANs(physicalInterface string) []string {
+	iface, err := net.InterfaceByName(physicalInterface)
+	if err != nil {
+		return nil
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return nil
+	}
+	var lans []string
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.To4() != nil && ipNet.IP.IsPrivate() {
+			lans = append(lans, (&net.IPNet{IP: ipNet.IP.Mask(ipNet.Mask), Mask: ipNet.Mask}).String())
+		}
+	}
+	return lans
+}
+
+// tunnelNetworks returns the networks of the addresses of the server (e.g. "10.0.0.0/24").
+func tunnelNetworks(address string) []string {
+	var networks []string
+	for _, part := range utils.SplitList(address) {
+		if _, ipNet, err := net.ParseCIDR(part); err == nil {
+			networks = append(networks, ipNet.String())
+		}
+	}
+	return networks
+}
+
+/*
+siteRoutedIPs returns the networks a site-to-site peer (or a peer with the split route profile) sends through
+the tunnel: the tunnel itself, the LANs of the server and the subnets of the other sites.
+
+A router keeps its own Internet access, so the default routes are not included. If the peers
+cannot reach each other, only the addresses of the server and its LANs are routed.
+*/
+func siteRoutedIPs(settings *tracker.InterfaceSettings, pubKeyClient string) string {
+	if !peerToPeerAllowed(settings) {
+		return strings.Join(append(serverHostIPs(settings.Address), settings.LANs...), ", ")
+	}
+	routes := append(tunnelNetworks(settings.Address), settings.LANs...)
+	for key, peer := range settings.Peers {
+		if key != pubKeyClient {
+			routes = append(routes, peer.Subnets...)
+		}
+	}
+	return strings.Join(routes, ", ")
+}
+
+// siteCount returns the number of site-to-site peers of the interface.
+func siteCount(settings *tracker.InterfaceSettings) int {
+	count := 0
+	for _, peer := range settings.Peers {
+		if len(peer.Subnets) > 0 {
+			count++
+		}
+	}
+	return count
+}
+
+// splitCount returns the number of peers with the split route profile which are not site-to-site peers.
+func splitCount(settings *tracker.InterfaceSettings) int {
+	count := 0
+	for _, peer := range settings.Peers {
+		if len(peer.Subnets) == 0 && peer.Routes == RouteSplit {
+			count++
+		}
+	}
+	return count
+}
+
+// applySiteRoutes adds ("replace") or deletes ("del") the routes of the subnets through the interface if it is up.
+//
+// wg-quick only routes the AllowedIPs when the interface comes up, not when the configuration is reloaded.
+func applySiteRoutes(interfaceName string, subnets []string, action string) error {
+	if !IsServiceActive(interfaceName) {
+		return nil
+	}
+	for _, subnet := range subnets {
+		if err := utils.RunAsRootSilent("ip", "route", action, subnet, "dev", interfaceName); err != nil {
+			return fmt.Errorf("failed to %s the route of %s: %w", action, subnet, err)
+		}
+	}
+	return nil
+}
@@ -2,8 +2,14 @@ package wireguard
 
 import (
 	"fast-wireguard/internal/system"
+	"fast-wireguard/internal/tracker"
 	"fast-wireguard/pkg/utils"
 	"fmt"
+	"net"
+	"os/exec"
+	"time"
+
+	"rsc.io/qr"
 )
 
 type ServerOptions struct {
@@ -11,9 +17,31 @@ type ServerOptions struct {
 	ListenPort          int
 	IPAdressLocalServer string
 	IPAdressLocalClient string
+	Family              system.Family
+	IPv6Prefix          string
+	IPv6Delegate        int
+	NDPProxy            string
 	PeerName            string
 	MTU                 int
 	Force               bool
+	DNS                 string
+	DNSSearch           string
+	DNSForwarder        bool
+	DNSUpstream         string
+	DNSZone             string
+	OutInterface        string
+	LANs                string
+	PeerToPeer          string
+	MTUProbe            string
+	ClientMTU           int
+	Endpoint            string
+	PreferIPv6          bool
+	STUNServers         string
+	IPProviders         string
+	IPConsensus         int
+	Offline             bool
+	IPCacheTTL          time.Duration
+	PortMapping         bool
 }
 
 /*
@@ -54,6 +82,14 @@ func RestartService(interfaceName string) error {
 	return nil
 }
 
+/*
+IsServiceActive reports whether the WireGuard service for the given interface is running.
+*/
+func IsServiceActive(interfaceName string) bool {
+	serviceName := fmt.Sprintf("wg-quick@%s", interfaceName)
+	return exec.Command("systemctl", "is-active", "--quiet", serviceName).Run() == nil
+}
+
 /*
 EnableServiceAutoStart allows the service for the given interface to start automatically on boot.
 */
@@ -67,7 +103,21 @@ func EnableServiceAutoStart(interfaceName string) error {
 }
 
 /*
-EnableServiceAutoStart disables the service for the given interface to start automatically on boot.
+ReloadService applies the changes of the configuration file to the running WireGuard interface.
+
+It does nothing if the service is not running.
+*/
+func ReloadService(interfaceName string) error {
+	serviceName := fmt.Sprintf("wg-quick@%s", interfaceName)
+	if err := utils.RunAsRootSilent("systemctl", "try-reload-or-restart", serviceName); err != nil {
+		return err
+	}
+	fmt.Printf("✅ Service %s reloaded.\n", interfaceName)
+	return nil
+}
+
+/*
+DisableServiceAutoStart disables the service for the given interface to start automatically on boot.
 */
 func DisableServiceAutoStart(interfaceName string, silent bool) error {
 	serviceName := fmt.Sprintf("wg-quick@%s", interfaceName)
@@ -83,40 +133,145 @@ func DisableServiceAutoStart(interfaceName string, silent bool) error {
 /*
 SetupServer setup the WireGuard server with the following steps:
 
-  - generate Wireguard key pair
   - get the physical interface
-  - get the ip adress
+  - get the endpoint (given or the public ip adress)
+  - generate Wireguard key pair, once every option is valid
   - generate the WireGuard server configuration file
+  - map the listen port on the gateway if the server is behind NAT
 */
 func CreateServer(interfaceName string, opts *ServerOptions) error {
-	// 1. Generate WireGuard key pair
-	priKeyServer, pubKeyServer, err := GenerateWGKeys(interfaceName)
+	// 1. Make sure the user agreed to replace an existing interface
+	if err := confirmOverwrite(interfaceName, opts.Force); err != nil {
+		return err
+	}
+	// 2. Check the addresses against the family, and get the physical interface and IP address
+	var err error
+	if opts.Family.FilterAddresses(opts.IPAdressLocalServer) != system.FamilyDual.FilterAddresses(opts.IPAdressLocalServer) {
+		return fmt.Errorf("the address %s does not match the family %s", opts.IPAdressLocalServer, opts.Family)
+	}
+	PhysicalInterface := opts.OutInterface
+	if PhysicalInterface == "" {
+		PhysicalInterface, err = system.GetPhysicalInterface()
+		if err != nil {
+			return err
+		}
+	} else if _, err := net.InterfaceByName(PhysicalInterface); err != nil {
+		return fmt.Errorf("invalid outbound interface %s: %w", PhysicalInterface, err)
+	}
+	discovery := tracker.DiscoverySettings{
+		STUNServers:     utils.SplitList(opts.STUNServers),
+		HTTPProviders:   utils.SplitList(opts.IPProviders),
+		Consensus:       opts.IPConsensus,
+		Offline:         opts.Offline,
+		CacheTTLSeconds: int(opts.IPCacheTTL.Seconds()),
+	}
+	endpoint := opts.Endpoint
+	if endpoint == "" {
+		endpoint, err = system.GetPublicIP(discoveryOptions(&tracker.InterfaceSettings{
+			PreferIPv6: opts.PreferIPv6,
+			Discovery:  discovery,
+		}))
+		if err != nil {
+			return err
+		}
+	} else if _, _, err := ParseEndpoint(endpoint); err != nil {
+		return err
+	}
+
+	// Give the peers global addresses of the routed prefix instead of NAT66
+	var ipv6 tracker.IPv6Settings
+	if opts.IPv6Prefix != "" {
+		if !opts.Family.HasIPv6() {
+			return fmt.Errorf("a routed IPv6 prefix requires the family ipv6 or dual")
+		}
+		ipv6, err = newRoutedIPv6Settings(opts.IPv6Prefix, opts.IPv6Delegate, opts.NDPProxy, PhysicalInterface)
+		if err != nil {
+			return err
+		}
+		opts.IPAdressLocalServer = routedServerAddress(opts.IPAdressLocalServer, ipv6, PhysicalInterface)
+		if ipv6.NDPProxy {
+			fmt.Printf("✅ Routing %s without NAT66, the addresses of the peers are proxied on %s.\n", ipv6.Prefix, PhysicalInterface)
+		} else {
+			fmt.Printf("✅ Routing %s without NAT66, make sure the upstream router routes it to this server.\n", ipv6.Prefix)
+		}
+	}
+
+	// The LANs of the server are routed to the site-to-site peers
+	lans, err := parseLANs(opts.LANs, PhysicalInterface)
 	if err != nil {
 		return err
 	}
-	// 2. Get the physical interface and IP address
-	PhysicalInterface, err := system.GetPhysicalInterface()
+
+	peerToPeer, err := ParsePeerToPeer(opts.PeerToPeer)
 	if err != nil {
 		return err
 	}
-	serverPublicIP, err := system.GetPublicIP()
+
+	// 3. Compute the MTU of the tunnel and the one recommended to the clients
+	mtu := opts.MTU
+	if mtu == 0 {
+		mtu = detectMTU(PhysicalInterface, opts.MTUProbe)
+	}
+	clientMTU := opts.ClientMTU
+	if clientMTU == 0 {
+		clientMTU = system.GetClientMTU(mtu)
+	}
+
+	// 4. Generate WireGuard key pair, only now so a failed check keeps the keys of an existing interface
+	priKeyServer, _, err := GenerateWGKeys(interfaceName)
 	if err != nil {
 		return err
 	}
 
-	// 3. Generate the WireGuard configuration file
+	// 5. Generate the WireGuard configuration file
 	if err := GenerateWGConfig(
 		interfaceName,
 		opts.ListenPort,
 		priKeyServer,
-		opts.MTU,
+		mtu,
 		opts.IPAdressLocalServer,
 		PhysicalInterface,
-		opts.Force); err != nil {
+		opts.Family,
+		ipv6,
+		peerToPeer == PeerToPeerAllow,
+		true); err != nil {
 		return err
 	}
 
-	// 4. Collect peer information and add peer configuration
+	// 6. Record the settings of the interface
+	settings := &tracker.InterfaceSettings{
+		ListenPort:        opts.ListenPort,
+		Address:           opts.IPAdressLocalServer,
+		Family:            string(opts.Family),
+		IPv6:              ipv6,
+		MTU:               mtu,
+		ClientMTU:         clientMTU,
+		PhysicalInterface: PhysicalInterface,
+		LANs:              lans,
+		PeerToPeer:        peerToPeer,
+		Endpoint:          endpoint,
+		PreferIPv6:        opts.PreferIPv6,
+		Discovery:         discovery,
+		DNS: tracker.DNSSettings{
+			Servers:       utils.SplitList(opts.DNS),
+			SearchDomains: utils.SplitList(opts.DNSSearch),
+			Forwarder:     opts.DNSForwarder,
+			Upstreams:     utils.SplitList(opts.DNSUpstream),
+			Zone:          opts.DNSZone,
+		},
+	}
+	if err := tracker.SaveInterfaceSettings(interfaceName, settings); err != nil {
+		return err
+	}
+
+	// 7. Map the listen port on the gateway if the server is behind NAT
+	if opts.PortMapping {
+		if err := EnablePortMapping(interfaceName); err != nil {
+			fmt.Printf("Warning: failed to set up the port mapping: %v\n", err)
+		}
+	}
+
+	// 8. Collect peer information and add peer configuration
 	pubKeyClient := utils.PromptInput("Input the public key for the peer (Enter to skip):", "", false)
 	if pubKeyClient == "" {
 		fmt.Println("Skipping peer configuration addition.")
@@ -124,19 +279,12 @@ func CreateServer(interfaceName string, opts *ServerOptions) error {
 		return nil
 	}
 	priKeyClient := utils.PromptInput("Input the private key for the peer (Not necessary, Enter to skip):", "", false)
-	if priKeyClient == "" {
-		priKeyClient = "<your_client_private_key>"
-	}
-	clientConfString, err := AddWGPeerConfig(
-		interfaceName,
-		serverPublicIP,
-		opts.ListenPort,
-		opts.MTU,
-		pubKeyServer,
-		opts.PeerName,
-		opts.IPAdressLocalClient,
-		pubKeyClient,
-		priKeyClient)
+	clientConfString, err := AddPeer(interfaceName, &PeerOptions{
+		PeerName:            opts.PeerName,
+		IPAdressLocalClient: opts.IPAdressLocalClient,
+		PubKeyClient:        pubKeyClient,
+		PriKeyClient:        priKeyClient,
+	})
 	if err != nil {
 		return err
 	}
@@ -149,7 +297,33 @@ func CreateServer(interfaceName string, opts *ServerOptions) error {
 		fmt.Println(clientConfString)
 		fmt.Println("------------------------------------------------")
 		// Print the client configuration QR code
-		// utils.PrintQRCode("You can also scan this QR code with your WireGuard App:\n", clientConfString)
+		utils.PrintQRCode("You can also scan this QR code with your WireGuard App:\n", clientConfString, qr.L)
 	}
 	return nil
 }
+
+/*
+detectMTU computes the MTU of the tunnel from the physical interface and optionally the path MTU towards probeHost.
+
+Falls back to 1420 if the MTU of the physical interface cannot be read.
+*/
+func detectMTU(physicalInterface string, probeHost string) int {
+	pathMTU := 0
+	if probeHost != "" {
+		var err error
+		pathMTU, err = system.ProbePathMTU(probeHost)
+		if err != nil {
+			fmt.Printf("Warning: failed to probe the path MTU towards %s: %v\n", probeHost, err)
+		} else {
+			fmt.Printf("✅ Get the path MTU towards %s: %d\n", probeHost, pathMTU)
+		}
+	}
+
+	mtu, err := system.GetTunnelMTU(physicalInterface, pathMTU)
+	if err != nil {
+		fmt.Printf("Warning: %v, using the MTU 1420.\n", err)
+		return 1420
+	}
+	fmt.Printf("✅ Get the MTU of the tunnel: %d\n", mtu)
+	return mtu
+}
@@ -0,0 +1,111 @@
+package wireguard
+
+import (
+	"bytes"
+	"fast-wireguard/pkg/utils"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/template"
+)
+
+var (
+	systemdUnitDir = "/etc/systemd/system"
+	// fwgUnits lists the template units installed by fwg, run for each interface
+	fwgUnits = []string{"fwg-dns", "fwg-ddns", "fwg-portmap"}
+)
+
+type UnitTplData struct {
+	Executable string
+}
+
+// unitPath returns the path of the template unit (e.g. "/etc/systemd/system/fwg-dns@.service").
+func unitPath(unitName string) string {
+	return filepath.Join(systemdUnitDir, fmt.Sprintf("%s@.service", unitName))
+}
+
+/*
+enableUnit installs the template unit of an fwg service and (re)starts its instance for the given interface.
+*/
+func enableUnit(unitName string, unitTpl string, interfaceName string) error {
+	// 1. Render the unit with the path of the current executable
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to get executable path: %w", err)
+	}
+	tmpl, err := template.New(unitName).Parse(unitTpl)
+	if err != nil {
+		return fmt.Errorf("failed to parse %s unit template: %w", unitName, err)
+	}
+	var buffer bytes.Buffer
+	if err := tmpl.Execute(&buffer, UnitTplData{Executable: exe}); err != nil {
+		return fmt.Errorf("failed to render %s unit template: %w", unitName, err)
+	}
+	if err := os.WriteFile(unitPath(unitName), buffer.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write unit to %s: %w", unitPath(unitName), err)
+	}
+
+	// 2. Reload systemd and (re)start the service
+	serviceName := fmt.Sprintf("%s@%s", unitName, interfaceName)
+	if err := utils.RunAsRootSilent("systemctl", "daemon-reload"); err != nil {
+		return err
+	}
+	if err := utils.RunAsRootSilent("systemctl", "enable", serviceName); err != nil {
+		return err
+	}
+	if err := utils.RunAsRoot("systemctl", "restart", serviceName); err != nil {
+		return err
+	}
+	fmt.Printf("✅ Service %s started.\n", serviceName)
+	return nil
+}
+
+/*
+disableUnit stops the instance of an fwg service for the given interface and disables it.
+*/
+func disableUnit(unitName string, interfaceName string, silent bool) error {
+	if _, err := os.Stat(unitPath(unitName)); os.IsNotExist(err) {
+		// The unit has never been installed
+		return nil
+	}
+	serviceName := fmt.Sprintf("%s@%s", unitName, interfaceName)
+	if err := utils.RunAsRootSilent("systemctl", "disable", "--now", serviceName); err != nil {
+		return err
+	}
+	if !silent {
+		fmt.Printf("✅ Service %s stopped.\n", serviceName)
+	}
+	return nil
+}
+
+/*
+disableAllUnits stops every fwg service of the given interface.
+*/
+func disableAllUnits(interfaceName string) error {
+	for _, unitName := range fwgUnits {
+		if err := disableUnit(unitName, interfaceName, true); err != nil {
+			return fmt.Errorf("failed to disable %s: %w", unitName, err)
+		}
+	}
+	return nil
+}
+
+/*
+RemoveServiceUnits removes the template units of all the fwg services.
+*/
+func RemoveServiceUnits() error {
+	removed := false
+	for _, unitName := range fwgUnits {
+		if err := os.Remove(unitPath(unitName)); err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return fmt.Errorf("failed to remove unit %s: %w", unitPath(unitName), err)
+		}
+		removed = true
+	}
+	if !removed {
+		return nil
+	}
+	return utils.RunAsRootSilent("systemctl", "daemon-reload")
+}
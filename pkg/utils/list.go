@@ -0,0 +1,18 @@
+package utils
+
+import (
+	"strings"
+)
+
+/*
+SplitList splits a comma separated list (e.g. "10.0.0.1/24, fd00::1/64") and drops the empty items.
+*/
+func SplitList(list string) []string {
+	var items []string
+	for item := range strings.SplitSeq(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
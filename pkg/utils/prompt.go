@@ -73,3 +73,19 @@ func PromptInput(promptMsg string, defaultVal string, required bool) string {
 
 	return strings.TrimSpace(result)
 }
+
+/*
+PromptPassword asks for a secret without echoing it (e.g. the passphrase of an archive).
+
+Returns an empty string if cancelled.
+*/
+func PromptPassword(promptMsg string) string {
+	var result string
+	prompt := &survey.Password{
+		Message: promptMsg,
+	}
+	if err := survey.AskOne(prompt, &result, survey.WithValidator(survey.Required)); err != nil {
+		return ""
+	}
+	return result
+}
@@ -3,19 +3,57 @@ package utils
 import (
 	"fmt"
 	"os"
+	"strings"
 	"github.com/mdp/qrterminal/v3"
+	"rsc.io/qr"
 )
 
+var (
+	// Bytes of text a QR code of the largest version (40) holds, by error correction level
+	qrCapacity = map[qr.Level]int{qr.L: 2953, qr.M: 2331, qr.Q: 1663, qr.H: 1273}
+)
+
+/*
+ParseQRLevel parses an error correction level: L, M, Q or H (from the least to the most tolerant of errors).
+*/
+func ParseQRLevel(level string) (qr.Level, error) {
+	switch strings.ToUpper(level) {
+	case "L":
+		return qr.L, nil
+	case "M":
+		return qr.M, nil
+	case "Q":
+		return qr.Q, nil
+	case "H":
+		return qr.H, nil
+	}
+	return qr.L, fmt.Errorf("invalid error correction level %s, use L, M, Q or H", level)
+}
+
+/*
+CheckQRCapacity returns an error if the content does not fit in a QR code at the given level.
+*/
+func CheckQRCapacity(content string, level qr.Level) error {
+	if capacity := qrCapacity[level]; len(content) > capacity {
+		return fmt.Errorf("the content (%d bytes) exceeds the capacity of a QR code at this error correction level (%d bytes), use a lower level", len(content), capacity)
+	}
+	return nil
+}
+
 /*
 PrintQRCode prints a QR code to the terminal for the given string content.
 */
-func PrintQRCode(prompt string, content string) {
+func PrintQRCode(prompt string, content string, level qr.Level) {
+	if err := CheckQRCapacity(content, level); err != nil {
+		fmt.Printf("Warning: cannot print the QR code: %v\n", err)
+		return
+	}
 	fmt.Printf("\n%s", prompt)
 	fmt.Println("------------------------------------------------")
 
 	// 配置二维码输出格式
 	config := qrterminal.Config{
-		Level:     qrterminal.L,
+		Level:     level,
 		Writer:    os.Stdout,
 		BlackChar: qrterminal.WHITE,
 		WhiteChar: qrterminal.BLACK,
@@ -27,3 +65,76 @@ func PrintQRCode(prompt string, content string) {
 	fmt.Println("------------------------------------------------")
 	fmt.Println("End of QR Code")
 }
+
+/*
+WriteQRCodePNG writes a QR code of the content to a PNG file about size pixels wide.
+
+The modules are whole pixels, so the image is rounded down to a multiple of the width of the code.
+*/
+func WriteQRCodePNG(path string, content string, level qr.Level, size int) error {
+	png, err := QRCodePNG(content, level, size)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, png, 0600); err != nil {
+		return fmt.Errorf("failed to write QR code to %s: %w", path, err)
+	}
+	return nil
+}
+
+/*
+QRCodePNG returns a PNG image of a QR code of the content, about size pixels wide.
+*/
+func QRCodePNG(content string, level qr.Level, size int) ([]byte, error) {
+	code, err := encodeQRCode(content, level)
+	if err != nil {
+		return nil, err
+	}
+	// The image has a quiet zone of 4 modules on each side
+	code.Scale = max(1, size/(code.Size+8))
+	return code.PNG(), nil
+}
+
+/*
+WriteQRCodeSVG writes a QR code of the content to an SVG file of size pixels wide.
+*/
+func WriteQRCodeSVG(path string, content string, level qr.Level, size int) error {
+	code, err := encodeQRCode(content, level)
+	if err != nil {
+		return err
+	}
+
+	// One path of unit squares in a viewBox with a quiet zone of 4 modules
+	var svg strings.Builder
+	width := code.Size + 8
+	fmt.Fprintf(&svg, `<?xml version="1.0" encoding="UTF-8"?>`+"\n")
+	fmt.Fprintf(&svg, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`+"\n",
+		size, size, width, width)
+	fmt.Fprintf(&svg, `<rect width="%d" height="%d" fill="#ffffff"/>`+"\n", width, width)
+	svg.WriteString(`<path fill="#000000" d="`)
+	for y := 0; y < code.Size; y++ {
+		for x := 0; x < code.Size; x++ {
+			if code.Black(x, y) {
+				fmt.Fprintf(&svg, "M%d %dh1v1h-1z", x+4, y+4)
+			}
+		}
+	}
+	svg.WriteString("\"/>\n</svg>\n")
+
+	if err := os.WriteFile(path, []byte(svg.String()), 0600); err != nil {
+		return fmt.Errorf("failed to write QR code to %s: %w", path, err)
+	}
+	return nil
+}
+
+// encodeQRCode checks the capacity and encodes the content.
+func encodeQRCode(content string, level qr.Level) (*qr.Code, error) {
+	if err := CheckQRCapacity(content, level); err != nil {
+		return nil, err
+	}
+	code, err := qr.Encode(content, level)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode QR code: %w", err)
+	}
+	return code, nil
+}
@@ -0,0 +1,130 @@
+package utils
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"crypto/rand"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"time"
+)
+
+/*
+ZipWriter writes a ZIP archive whose files are encrypted with the passphrase if one is given.
+
+The encryption is the traditional PKWARE one (ZipCrypto), the only one most archivers and file
+managers open natively. It is weak against a determined attacker: use it against casual access
+and send the passphrase through another channel.
+*/
+type ZipWriter struct {
+	writer     *zip.Writer
+	passphrase string
+}
+
+/*
+NewZipWriter returns a ZipWriter writing to w, without encryption if the passphrase is empty.
+*/
+func NewZipWriter(w io.Writer, passphrase string) *ZipWriter {
+	return &ZipWriter{writer: zip.NewWriter(w), passphrase: passphrase}
+}
+
+/*
+AddFile compresses the content into the archive under the given name.
+*/
+func (z *ZipWriter) AddFile(name string, content []byte, mode os.FileMode) error {
+	header := &zip.FileHeader{
+		Name:     name,
+		Method:   zip.Deflate,
+		Modified: time.Now(),
+	}
+	header.SetMode(mode)
+	if z.passphrase == "" {
+		file, err := z.writer.CreateHeader(header)
+		if err != nil {
+			return fmt.Errorf("failed to add %s to the archive: %w", name, err)
+		}
+		_, err = file.Write(content)
+		return err
+	}
+
+	// 1. Compress the content, the encryption applies to the compressed data
+	var compressed bytes.Buffer
+	compressor, err := flate.NewWriter(&compressed, flate.DefaultCompression)
+	if err != nil {
+		return err
+	}
+	if _, err := compressor.Write(content); err != nil {
+		return err
+	}
+	if err := compressor.Close(); err != nil {
+		return err
+	}
+
+	// 2. Encrypt the header of 12 bytes followed by the compressed data
+	crc := crc32.ChecksumIEEE(content)
+	encryptionHeader := make([]byte, 12)
+	if _, err := rand.Read(encryptionHeader[:11]); err != nil {
+		return err
+	}
+	// The last byte of the header lets the archivers check the passphrase
+	encryptionHeader[11] = byte(crc >> 24)
+	keys := newZipCryptoKeys(z.passphrase)
+	encrypted := keys.encrypt(append(encryptionHeader, compressed.Bytes()...))
+
+	// 3. Write the encrypted data as is, with the sizes and the checksum in the header
+	header.Flags |= 0x1
+	header.CRC32 = crc
+	header.CompressedSize64 = uint64(len(encrypted))
+	header.UncompressedSize64 = uint64(len(content))
+	file, err := z.writer.CreateRaw(header)
+	if err != nil {
+		return fmt.Errorf("failed to add %s to the archive: %w", name, err)
+	}
+	_, err = file.Write(encrypted)
+	return err
+}
+
+/*
+Close writes the central directory of the archive.
+*/
+func (z *ZipWriter) Close() error {
+	return z.writer.Close()
+}
+
+// zipCryptoKeys is the state of the traditional PKWARE encryption.
+type zipCryptoKeys [3]uint32
+
+// newZipCryptoKeys initializes the keys with the passphrase.
+func newZipCryptoKeys(passphrase string) *zipCryptoKeys {
+	keys := &zipCryptoKeys{0x12345678, 0x23456789, 0x34567890}
+	for i := 0; i < len(passphrase); i++ {
+		keys.update(passphrase[i])
+	}
+	return keys
+}
+
+// update mixes a byte of plain text into the keys.
+func (k *zipCryptoKeys) update(b byte) {
+	k[0] = crc32Byte(k[0], b)
+	k[1] = (k[1]+k[0]&0xff)*134775813 + 1
+	k[2] = crc32Byte(k[2], byte(k[1]>>24))
+}
+
+// encrypt returns the encrypted data and advances the keys.
+func (k *zipCryptoKeys) encrypt(data []byte) []byte {
+	result := make([]byte, len(data))
+	for i, b := range data {
+		temp := k[2] | 2
+		result[i] = b ^ byte((temp*(temp^1))>>8)
+		k.update(b)
+	}
+	return result
+}
+
+// crc32Byte advances a CRC-32 register by one byte, without the inversions of crc32.Update.
+func crc32Byte(crc uint32, b byte) uint32 {
+	return crc32.IEEETable[byte(crc)^b] ^ crc>>8
+}
@@ -0,0 +1,128 @@
+package utils
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"encoding/hex"
+	"hash/crc32"
+	"io"
+	"testing"
+)
+
+// Encrypted with the reference implementation of the traditional PKWARE encryption (Python zipfile)
+const zipCryptoVector = "42ca9b369be5b64caf13f0b86410f5362a3e2ca14cb7bce7309e63f7"
+
+func TestZipCryptoKnownAnswer(t *testing.T) {
+	plain := append([]byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 0x3a}, "hello, wireguard"...)
+	got := newZipCryptoKeys("fwg passphrase").encrypt(plain)
+	if hex.EncodeToString(got) != zipCryptoVector {
+		t.Errorf("encrypt() = %x, want %s", got, zipCryptoVector)
+	}
+}
+
+// zipCryptoDecrypt decrypts the data of an encrypted entry, the keys advance with the decrypted bytes.
+func zipCryptoDecrypt(passphrase string, data []byte) []byte {
+	keys := newZipCryptoKeys(passphrase)
+	result := make([]byte, len(data))
+	for i, b := range data {
+		temp := keys[2] | 2
+		result[i] = b ^ byte((temp*(temp^1))>>8)
+		keys.update(result[i])
+	}
+	return result
+}
+
+// readEncryptedEntry decrypts and inflates an entry of the archive, checking the check byte and the CRC.
+func readEncryptedEntry(t *testing.T, file *zip.File, passphrase string) ([]byte, bool) {
+	t.Helper()
+	raw, err := file.OpenRaw()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) < 12 {
+		t.Fatalf("%s has %d encrypted bytes, less than the encryption header", file.Name, len(data))
+	}
+	plain := zipCryptoDecrypt(passphrase, data)
+	if plain[11] != byte(file.CRC32>>24) {
+		return nil, false
+	}
+	content, err := io.ReadAll(flate.NewReader(bytes.NewReader(plain[12:])))
+	if err != nil || crc32.ChecksumIEEE(content) != file.CRC32 {
+		return nil, false
+	}
+	return content, true
+}
+
+func TestZipWriterEncrypted(t *testing.T) {
+	files := map[string][]byte{
+		"laptop.conf": []byte("[Interface]\nPrivateKey = cHJpS2V5Q2xpZW50cHJpS2V5Q2xpZW50cHJpS2V5Q2w=\n"),
+		"README.txt":  bytes.Repeat([]byte("WireGuard client configurations. "), 100),
+	}
+	var archive bytes.Buffer
+	writer := NewZipWriter(&archive, "fwg passphrase")
+	for _, name := range []string{"laptop.conf", "README.txt"} {
+		if err := writer.AddFile(name, files[name], 0600); err != nil {
+			t.Fatalf("AddFile() error = %v", err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	reader, err := zip.NewReader(bytes.NewReader(archive.Bytes()), int64(archive.Len()))
+	if err != nil {
+		t.Fatalf("the archive cannot be read: %v", err)
+	}
+	if len(reader.File) != len(files) {
+		t.Fatalf("the archive has %d files, want %d", len(reader.File), len(files))
+	}
+	for _, file := range reader.File {
+		if file.Flags&0x1 == 0 || file.Method != zip.Deflate {
+			t.Errorf("%s is not flagged as encrypted and deflated (flags %#x, method %d)", file.Name, file.Flags, file.Method)
+		}
+		if file.Mode().Perm() != 0600 || file.UncompressedSize64 != uint64(len(files[file.Name])) {
+			t.Errorf("%s has the mode %s and the size %d", file.Name, file.Mode(), file.UncompressedSize64)
+		}
+		content, ok := readEncryptedEntry(t, file, "fwg passphrase")
+		if !ok || !bytes.Equal(content, files[file.Name]) {
+			t.Errorf("%s decrypts to %q, want %q", file.Name, content, files[file.Name])
+		}
+		if _, ok := readEncryptedEntry(t, file, "wrong passphrase"); ok {
+			t.Errorf("%s decrypts with a wrong passphrase", file.Name)
+		}
+	}
+}
+
+func TestZipWriterPlain(t *testing.T) {
+	content := []byte("[Interface]\nAddress = 10.0.0.2/32\n")
+	var archive bytes.Buffer
+	writer := NewZipWriter(&archive, "")
+	if err := writer.AddFile("laptop.conf", content, 0600); err != nil {
+		t.Fatalf("AddFile() error = %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	reader, err := zip.NewReader(bytes.NewReader(archive.Bytes()), int64(archive.Len()))
+	if err != nil {
+		t.Fatalf("the archive cannot be read: %v", err)
+	}
+	if len(reader.File) != 1 || reader.File[0].Flags&0x1 != 0 {
+		t.Fatalf("the archive has %d files, want one unencrypted file", len(reader.File))
+	}
+	file, err := reader.File[0].Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	got, err := io.ReadAll(file)
+	if err != nil || !bytes.Equal(got, content) {
+		t.Errorf("laptop.conf = %q (%v), want %q", got, err, content)
+	}
+}